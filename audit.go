@@ -0,0 +1,96 @@
+package security
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// AuditHandler is an optional interface for session channel backends. Backends implementing it are notified of every
+// request permitted under ExecutionPolicyAudit so they can record it in their audit log, and of the termination of the
+// session through SessionTracker as a terminate request. SSH connection backends may also implement it to be notified
+// of audited global requests.
+type AuditHandler interface {
+	// OnAuditedRequest is called before the request is passed to the backend. If an error is returned the request is
+	// rejected.
+	OnAuditedRequest(request AuditedRequest) error
+}
+
+// AuditSchemaVersion is the version of the audit and decision event schema defined in proto/security/v1/events.proto.
+// It is increased when the meaning of existing fields changes. Adding fields doesn't change the version.
+const AuditSchemaVersion = 1
+
+// AuditedRequest contains the details of a request permitted under ExecutionPolicyAudit. The schema is defined in
+// proto/security/v1/events.proto.
+type AuditedRequest struct {
+	// SchemaVersion is the AuditSchemaVersion the record was created with.
+	SchemaVersion int `json:"schemaVersion"`
+	// RequestID is the ID of the request within the session channel or, for global requests, the connection.
+	RequestID uint64 `json:"requestId"`
+	// Username is the name of the authenticated user.
+	Username string `json:"username"`
+	// RequestType is the type of the request, e.g. env, pty, exec, shell, subsystem, signal or terminate.
+	RequestType string `json:"requestType"`
+	// Payload contains the request parameters, e.g. the name and value of an environment variable.
+	Payload map[string]string `json:"payload"`
+	// Sampled is true if the request was not in ExecutionPolicyAudit mode, but was selected by Config.Sampling.
+	Sampled bool `json:"sampled"`
+}
+
+// audit reports the request to the backend if mode is ExecutionPolicyAudit.
+func (s *sessionHandler) audit(
+	mode ExecutionPolicy,
+	requestID uint64,
+	requestType string,
+	payload map[string]string,
+) error {
+	if mode != ExecutionPolicyAudit {
+		s.sshConnection.sample(s.backend, requestID, requestType, payload)
+		return nil
+	}
+	auditHandler, ok := s.backend.(AuditHandler)
+	if !ok {
+		return fmt.Errorf("auditing not supported by backend")
+	}
+	return auditHandler.OnAuditedRequest(AuditedRequest{
+		SchemaVersion: AuditSchemaVersion,
+		RequestID:     requestID,
+		Username:      s.sshConnection.username,
+		RequestType:   requestType,
+		Payload:       payload,
+	})
+}
+
+// audit reports the global request to the backend if mode is ExecutionPolicyAudit.
+func (s *sshConnectionHandler) audit(
+	mode ExecutionPolicy,
+	requestID uint64,
+	requestType string,
+	payload []byte,
+) error {
+	return s.auditPayload(mode, requestID, requestType, map[string]string{"payload": hex.EncodeToString(payload)})
+}
+
+// auditPayload reports the connection-level request with an already decoded payload to the backend if mode is
+// ExecutionPolicyAudit.
+func (s *sshConnectionHandler) auditPayload(
+	mode ExecutionPolicy,
+	requestID uint64,
+	requestType string,
+	payload map[string]string,
+) error {
+	if mode != ExecutionPolicyAudit {
+		s.sample(s.backend, requestID, requestType, payload)
+		return nil
+	}
+	auditHandler, ok := s.backend.(AuditHandler)
+	if !ok {
+		return fmt.Errorf("auditing not supported by backend")
+	}
+	return auditHandler.OnAuditedRequest(AuditedRequest{
+		SchemaVersion: AuditSchemaVersion,
+		RequestID:     requestID,
+		Username:      s.username,
+		RequestType:   requestType,
+		Payload:       payload,
+	})
+}
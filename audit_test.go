@@ -0,0 +1,70 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditPolicy(t *testing.T) {
+	session := &sessionHandler{
+		config: Config{
+			DefaultMode: ExecutionPolicyAudit,
+			Env: EnvConfig{
+				Deny: []string{"LD_PRELOAD"},
+			},
+		},
+		backend: &dummyBackend{},
+		sshConnection: &sshConnectionHandler{
+			username: "foo",
+			lock:     &sync.Mutex{},
+		},
+	}
+	assert.Error(t, session.OnExecRequest(1, "/bin/bash"))
+
+	backend := &auditBackend{}
+	session.backend = backend
+	assert.NoError(t, session.OnEnvRequest(1, "LANG", "C"))
+	assert.Error(t, session.OnEnvRequest(2, "LD_PRELOAD", "/tmp/evil.so"))
+	assert.NoError(t, session.OnExecRequest(3, "/bin/bash"))
+	assert.NoError(t, session.OnSubsystem(4, "sftp"))
+	assert.Equal(t, []AuditedRequest{
+		{
+			SchemaVersion: AuditSchemaVersion,
+			RequestID:     1,
+			Username:      "foo",
+			RequestType:   "env",
+			Payload:       map[string]string{"name": "LANG", "value": "C"},
+		},
+		{
+			SchemaVersion: AuditSchemaVersion,
+			RequestID:     3,
+			Username:      "foo",
+			RequestType:   "exec",
+			Payload:       map[string]string{"command": "/bin/bash", "commandHash": CommandHash("/bin/bash")},
+		},
+		{
+			SchemaVersion: AuditSchemaVersion,
+			RequestID:     4,
+			Username:      "foo",
+			RequestType:   "subsystem",
+			Payload:       map[string]string{"subsystem": "sftp"},
+		},
+	}, backend.requests)
+
+	session.config.DefaultMode = ExecutionPolicyEnable
+	backend.requests = nil
+	assert.NoError(t, session.OnExecRequest(5, "/bin/bash"))
+	assert.Empty(t, backend.requests)
+}
+
+type auditBackend struct {
+	dummyBackend
+	requests []AuditedRequest
+}
+
+func (a *auditBackend) OnAuditedRequest(request AuditedRequest) error {
+	a.requests = append(a.requests, request)
+	return nil
+}
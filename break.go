@@ -0,0 +1,67 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// breakRequestPayload is the payload of a break request as described in RFC 4335 section 3.
+type breakRequestPayload struct {
+	// BreakLength is the length of the break in milliseconds.
+	BreakLength uint32
+}
+
+// EvaluateBreak evaluates a break request for a break of the specified length in milliseconds.
+func (e *Evaluator) EvaluateBreak(length uint32) Decision {
+	payload := map[string]string{
+		"length": strconv.FormatUint(uint64(length), 10),
+	}
+	return e.decide("break", payload, e.config.Break.Mode, func(mode ExecutionPolicy) Decision {
+		return e.evaluateBreak(mode, length)
+	})
+}
+
+func (e *Evaluator) evaluateBreak(mode ExecutionPolicy, length uint32) Decision {
+	if mode != ExecutionPolicyDisable && e.config.Break.MaxLength > 0 && length > e.config.Break.MaxLength {
+		return deny(
+			mode,
+			DecisionReasonBreakTooLong,
+			fmt.Sprintf("breaks longer than %d milliseconds are not allowed", e.config.Break.MaxLength),
+		)
+	}
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "break requests are disabled")
+	case ExecutionPolicyFilter:
+		fallthrough
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		return allow(mode, DecisionReasonModeEnabled, "break requests are enabled")
+	}
+}
+
+// allowBreak decides whether a break request is passed to the backend. Requests with a malformed payload are
+// rejected. The request is audited if either requestMode, the policy from Requests, or Break.Mode is
+// ExecutionPolicyAudit.
+func (s *sessionHandler) allowBreak(requestID uint64, requestMode ExecutionPolicy, payload []byte) bool {
+	request := breakRequestPayload{}
+	if err := ssh.Unmarshal(payload, &request); err != nil {
+		return false
+	}
+	decision := s.evaluator().EvaluateBreak(request.BreakLength)
+	if !decision.Allowed {
+		return false
+	}
+	auditMode := decision.Mode
+	if requestMode == ExecutionPolicyAudit {
+		auditMode = ExecutionPolicyAudit
+	}
+	return s.audit(auditMode, requestID, "break", map[string]string{
+		"length": strconv.FormatUint(uint64(request.BreakLength), 10),
+	}) == nil
+}
@@ -0,0 +1,62 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestBreak(t *testing.T) {
+	backend := &requestBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Break: BreakConfig{
+				Mode:      ExecutionPolicyEnable,
+				MaxLength: 500,
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.config.Validate())
+
+	request := func(requestID uint64, length uint32) {
+		session.OnUnsupportedChannelRequest(requestID, "break", ssh.Marshal(breakRequestPayload{BreakLength: length}))
+	}
+	request(1, 250)
+	request(2, 500)
+	request(3, 501)
+	session.OnUnsupportedChannelRequest(4, "break", []byte{})
+	assert.Equal(t, []string{"break", "break"}, backend.requests)
+
+	session.config.Requests = map[string]RequestConfig{"break": {Mode: ExecutionPolicyDisable}}
+	request(5, 100)
+	assert.Len(t, backend.requests, 2)
+
+	auditor := &auditBackend{}
+	session.backend = auditor
+	session.config.Requests = nil
+	session.config.Break = BreakConfig{Mode: ExecutionPolicyAudit}
+	request(6, 3000)
+	assert.Equal(t, []AuditedRequest{
+		{
+			SchemaVersion: AuditSchemaVersion,
+			RequestID:     6,
+			RequestType:   "break",
+			Payload:       map[string]string{"length": "3000"},
+		},
+	}, auditor.requests)
+
+	evaluator, err := NewEvaluator(Config{Break: BreakConfig{Mode: ExecutionPolicyDisable}})
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionReasonModeDisabled, evaluator.EvaluateBreak(0).Reason)
+	evaluator, err = NewEvaluator(Config{Break: BreakConfig{MaxLength: 1000}})
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionReasonBreakTooLong, evaluator.EvaluateBreak(1001).Reason)
+
+	assert.Error(t, BreakConfig{Mode: ExecutionPolicyPrompt}.Validate())
+}
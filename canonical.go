@@ -0,0 +1,98 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UserCanonicalizer maps usernames onto a canonical form, so the same user is not given different policies depending
+// on how they spelled their username, e.g. Alice@CORP and alice. The canonical username is used for the policies in
+// Users and Groups, the group lookup, rate limits, quarantine, recertification and the audit records. The backend still
+// receives the username as sent by the client.
+type UserCanonicalizer interface {
+	// Canonicalize returns the canonical form of the username. If an error is returned the connection is rejected.
+	Canonicalize(username string) (string, error)
+}
+
+// LowercaseCanonicalizer converts usernames to lowercase.
+type LowercaseCanonicalizer struct{}
+
+// Canonicalize returns the username in lowercase.
+func (l LowercaseCanonicalizer) Canonicalize(username string) (string, error) {
+	return strings.ToLower(username), nil
+}
+
+// DomainStrippingCanonicalizer removes the domain from usernames in the user@domain and DOMAIN\user forms.
+type DomainStrippingCanonicalizer struct{}
+
+// Canonicalize returns the username without the domain.
+func (d DomainStrippingCanonicalizer) Canonicalize(username string) (string, error) {
+	if i := strings.LastIndex(username, "@"); i >= 0 {
+		username = username[:i]
+	}
+	if i := strings.Index(username, `\`); i >= 0 {
+		username = username[i+1:]
+	}
+	return username, nil
+}
+
+// AliasCanonicalizer maps aliases to usernames. Usernames not in the map are returned unchanged.
+type AliasCanonicalizer map[string]string
+
+// Canonicalize returns the username the alias maps to.
+func (a AliasCanonicalizer) Canonicalize(username string) (string, error) {
+	if canonical, ok := a[username]; ok {
+		return canonical, nil
+	}
+	return username, nil
+}
+
+// CanonicalizerChain applies canonicalizers in order, e.g. domain stripping followed by lowercasing and aliases.
+type CanonicalizerChain []UserCanonicalizer
+
+// Canonicalize passes the username through all canonicalizers of the chain.
+func (c CanonicalizerChain) Canonicalize(username string) (string, error) {
+	var err error
+	for _, canonicalizer := range c {
+		if username, err = canonicalizer.Canonicalize(username); err != nil {
+			return "", err
+		}
+	}
+	return username, nil
+}
+
+// ErrCanonicalizationFailed indicates that the canonical form of the username could not be determined.
+type ErrCanonicalizationFailed struct {
+	Cause error
+}
+
+// Error contains the error for the logs.
+func (e *ErrCanonicalizationFailed) Error() string {
+	return fmt.Sprintf("failed to canonicalize username (%v)", e.Cause)
+}
+
+// Unwrap returns the error returned by the UserCanonicalizer.
+func (e *ErrCanonicalizationFailed) Unwrap() error {
+	return e.Cause
+}
+
+// canonicalize returns the canonical form of the username, or the username itself if no canonicalizer is set.
+func canonicalize(canonicalizer UserCanonicalizer, username string) (string, error) {
+	if canonicalizer == nil {
+		return username, nil
+	}
+	canonical, err := canonicalizer.Canonicalize(username)
+	if err != nil {
+		return "", &ErrCanonicalizationFailed{Cause: err}
+	}
+	if canonical == "" {
+		return "", &ErrCanonicalizationFailed{Cause: fmt.Errorf("empty username")}
+	}
+	return canonical, nil
+}
+
+func (h *handler) SetUserCanonicalizer(canonicalizer UserCanonicalizer) {
+	h.configLock.Lock()
+	defer h.configLock.Unlock()
+	h.canonicalizer = canonicalizer
+}
@@ -0,0 +1,57 @@
+package security
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserCanonicalizer(t *testing.T) {
+	canonicalizer := CanonicalizerChain{
+		DomainStrippingCanonicalizer{},
+		LowercaseCanonicalizer{},
+		AliasCanonicalizer{"ali": "alice"},
+	}
+	for username, expected := range map[string]string{
+		"Alice@CORP":     "alice",
+		`CORP\Alice`:     "alice",
+		"alice":          "alice",
+		"ALI@corp.local": "alice",
+		"bob":            "bob",
+	} {
+		canonical, err := canonicalizer.Canonicalize(username)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, canonical, username)
+	}
+
+	h, err := NewHandler(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: -1,
+		Users: map[string]Config{
+			"alice": {Shell: ShellConfig{Mode: ExecutionPolicyDisable}},
+		},
+	}, &dummyHandler{})
+	assert.NoError(t, err)
+	h.SetUserCanonicalizer(canonicalizer)
+	client := net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+	network, err := h.OnNetworkConnection(client, "1")
+	assert.NoError(t, err)
+	connection, err := network.OnHandshakeSuccess("Alice@CORP")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", connection.(*sshConnectionHandler).username)
+	assert.Equal(t, ExecutionPolicyDisable, connection.(*sshConnectionHandler).config.Shell.Mode)
+
+	h.SetUserCanonicalizer(CanonicalizerChain{failingCanonicalizer{}})
+	network, err = h.OnNetworkConnection(client, "2")
+	assert.NoError(t, err)
+	_, err = network.OnHandshakeSuccess("alice")
+	assert.IsType(t, &ErrCanonicalizationFailed{}, err)
+}
+
+type failingCanonicalizer struct{}
+
+func (f failingCanonicalizer) Canonicalize(_ string) (string, error) {
+	return "", errors.New("directory unavailable")
+}
@@ -0,0 +1,67 @@
+package security
+
+import (
+	"io"
+	"sync"
+
+	"github.com/containerssh/sshserver"
+)
+
+// backendChannel is passed to the session backend in place of the session channel. It tracks when the program exits
+// and lets the backend report what it started using SpawnVerifier.
+type backendChannel struct {
+	sshserver.SessionChannel
+	session *sessionHandler
+	exited  chan struct{}
+	once    *sync.Once
+	// stdin and stdout replace the streams of the session channel if set, e.g. by the SFTP filter.
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func newBackendChannel(channel sshserver.SessionChannel) *backendChannel {
+	return &backendChannel{
+		SessionChannel: channel,
+		exited:         make(chan struct{}),
+		once:           &sync.Once{},
+	}
+}
+
+func (b *backendChannel) exit() {
+	b.once.Do(func() {
+		close(b.exited)
+	})
+}
+
+func (b *backendChannel) Stdin() io.Reader {
+	if b.stdin != nil {
+		return b.stdin
+	}
+	return b.SessionChannel.Stdin()
+}
+
+func (b *backendChannel) Stdout() io.Writer {
+	if b.stdout != nil {
+		return b.stdout
+	}
+	return b.SessionChannel.Stdout()
+}
+
+func (b *backendChannel) ExitStatus(code uint32) {
+	b.exit()
+	b.SessionChannel.ExitStatus(code)
+}
+
+func (b *backendChannel) ExitSignal(signal string, coreDumped bool, errorMessage string, languageTag string) {
+	b.exit()
+	b.SessionChannel.ExitSignal(signal, coreDumped, errorMessage, languageTag)
+}
+
+func (b *backendChannel) Close() error {
+	b.exit()
+	return b.SessionChannel.Close()
+}
+
+func (b *backendChannel) VerifySpawn(program string, env map[string]string) error {
+	return b.session.verifySpawn(program, env)
+}
@@ -0,0 +1,122 @@
+package security
+
+import (
+	"github.com/containerssh/sshserver"
+	"golang.org/x/crypto/ssh"
+)
+
+// Validate validates a channel configuration.
+func (c ChannelConfig) Validate() error {
+	v := &validator{}
+	c.validate(v, "")
+	return v.result()
+}
+
+func (c ChannelConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), c.Mode, false)
+	if c.Max < 0 {
+		v.fail(field(path, "max"), ValidationCodeOutOfRange, "invalid max setting: %d", c.Max)
+	}
+	if c.PerMinute < 0 {
+		v.fail(field(path, "perMinute"), ValidationCodeOutOfRange, "invalid perMinute setting: %d", c.PerMinute)
+	}
+	if c.Burst < 0 {
+		v.fail(field(path, "burst"), ValidationCodeOutOfRange, "invalid burst setting: %d", c.Burst)
+	}
+	c.Throttle.validate(v, field(path, "throttle"))
+}
+
+// openChannel checks if a channel of channelType may be opened and records it. The caller must not hold the lock, as
+// throttled channels wait for the rate limit.
+func (s *sshConnectionHandler) openChannel(channelType string) sshserver.ChannelRejection {
+	config, ok := s.config.Channels[channelType]
+	if !ok {
+		if channelType == "session" {
+			return nil
+		}
+		config = ChannelConfig{}
+	}
+	switch s.config.effectivePolicy(config.Mode, s.config.Defaults.Channels) {
+	case ExecutionPolicyDisable:
+		fallthrough
+	case ExecutionPolicyFilter:
+		return &ErrChannelRejected{}
+	default:
+	}
+	if config.PerMinute > 0 && !s.channelLimiter(channelType, config).allow(channelType) {
+		return &ErrTooManyChannels{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if config.Max > 0 && s.channelCounts[channelType] >= uint(config.Max) {
+		return &ErrTooManyChannels{}
+	}
+	if s.channelCounts == nil {
+		s.channelCounts = map[string]uint{}
+	}
+	s.channelCounts[channelType]++
+	return nil
+}
+
+// releaseChannelLocked undoes the count recorded by openChannel when the channel is rejected after all. The caller
+// must hold the lock.
+func (s *sshConnectionHandler) releaseChannelLocked(channelType string) {
+	if s.channelCounts[channelType] > 0 {
+		s.channelCounts[channelType]--
+	}
+}
+
+func (s *sshConnectionHandler) channelLimiter(channelType string, config ChannelConfig) *rateLimiter {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.channelRates == nil {
+		s.channelRates = map[string]*rateLimiter{}
+	}
+	limiter, ok := s.channelRates[channelType]
+	if !ok {
+		limiter = newRateLimiter(
+			RateLimitConfig{Burst: config.Burst, Throttle: config.Throttle},
+			config.PerMinute,
+		)
+		s.channelRates[channelType] = limiter
+	}
+	return limiter
+}
+
+// ErrChannelRejected indicates that the channel type may not be opened.
+type ErrChannelRejected struct {
+}
+
+// Error contains the error for the logs.
+func (e *ErrChannelRejected) Error() string {
+	return "channel type rejected"
+}
+
+// Message contains a message intended for the user.
+func (e *ErrChannelRejected) Message() string {
+	return "channel type rejected"
+}
+
+// Reason contains the rejection code.
+func (e *ErrChannelRejected) Reason() ssh.RejectionReason {
+	return ssh.Prohibited
+}
+
+// ErrTooManyChannels indicates that too many channels of the same type were opened in the same connection.
+type ErrTooManyChannels struct {
+}
+
+// Error contains the error for the logs.
+func (e *ErrTooManyChannels) Error() string {
+	return "too many channels"
+}
+
+// Message contains a message intended for the user.
+func (e *ErrTooManyChannels) Message() string {
+	return "too many channels"
+}
+
+// Reason contains the rejection code.
+func (e *ErrTooManyChannels) Reason() ssh.RejectionReason {
+	return ssh.ResourceShortage
+}
@@ -0,0 +1,114 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestChannels(t *testing.T) {
+	backend := &channelBackend{
+		dummySSHBackend: dummySSHBackend{
+			exitChannel: make(chan struct{}),
+		},
+	}
+	connection := &sshConnectionHandler{
+		config: Config{
+			MaxSessions: -1,
+			Channels: map[string]ChannelConfig{
+				"session": {
+					Max: 2,
+				},
+				"x11": {
+					Mode: ExecutionPolicyDisable,
+				},
+				"direct-tcpip": {
+					PerMinute: 1,
+					Burst:     1,
+				},
+			},
+		},
+		backend: backend,
+		lock:    &sync.Mutex{},
+	}
+	assert.NoError(t, connection.config.Validate())
+
+	for i := 0; i < 2; i++ {
+		_, err := connection.OnSessionChannel(uint64(i), []byte{}, &sessionChannel{})
+		assert.Nil(t, err)
+	}
+	_, err := connection.OnSessionChannel(2, []byte{}, &sessionChannel{})
+	assert.IsType(t, &ErrTooManyChannels{}, err)
+
+	connection.OnUnsupportedChannel(3, "x11", []byte{})
+	forward := ssh.Marshal(directTCPIPPayload{Host: "127.0.0.1", Port: 80})
+	connection.OnUnsupportedChannel(4, "direct-tcpip", forward)
+	connection.OnUnsupportedChannel(5, "direct-tcpip", forward)
+	connection.OnUnsupportedChannel(6, "custom@example.com", []byte{})
+	assert.Equal(t, []string{"direct-tcpip", "custom@example.com"}, backend.channels)
+
+	connection.config.DefaultMode = ExecutionPolicyDisable
+	connection.OnUnsupportedChannel(7, "custom@example.com", []byte{})
+	assert.Equal(t, []string{"direct-tcpip", "custom@example.com"}, backend.channels)
+}
+
+type channelBackend struct {
+	dummySSHBackend
+	channels []string
+}
+
+func (c *channelBackend) OnUnsupportedChannel(_ uint64, channelType string, _ []byte) {
+	c.channels = append(c.channels, channelType)
+}
+
+func TestRejectedSessionReleasesChannel(t *testing.T) {
+	connection := &sshConnectionHandler{
+		config: Config{
+			MaxSessions: 1,
+			Channels: map[string]ChannelConfig{
+				"session": {
+					Max: 2,
+				},
+			},
+		},
+		backend: &dummySSHBackend{exitChannel: make(chan struct{})},
+		lock:    &sync.Mutex{},
+	}
+	assert.NoError(t, connection.config.Validate())
+
+	_, err := connection.OnSessionChannel(0, []byte{}, &sessionChannel{})
+	assert.Nil(t, err)
+	for i := 1; i < 4; i++ {
+		_, err = connection.OnSessionChannel(uint64(i), []byte{}, &sessionChannel{})
+		assert.IsType(t, &ErrTooManySessions{}, err)
+	}
+	assert.Equal(t, uint(1), connection.channelCounts["session"])
+}
+
+func TestChannelMaxUnset(t *testing.T) {
+	backend := &channelBackend{
+		dummySSHBackend: dummySSHBackend{
+			exitChannel: make(chan struct{}),
+		},
+	}
+	connection := &sshConnectionHandler{
+		config: Config{
+			MaxSessions: -1,
+			Channels: map[string]ChannelConfig{
+				"custom@example.com": {
+					Mode: ExecutionPolicyEnable,
+				},
+			},
+		},
+		backend: backend,
+		lock:    &sync.Mutex{},
+	}
+	assert.NoError(t, connection.config.Validate())
+
+	for i := 0; i < 3; i++ {
+		connection.OnUnsupportedChannel(uint64(i), "custom@example.com", []byte{})
+	}
+	assert.Len(t, backend.channels, 3)
+}
@@ -0,0 +1,169 @@
+// Package chaostest injects delays and failures into the policy infrastructure used by security.Handler, such as the
+// state store, the group resolver and reloads. It is intended for integration tests of servers embedding the security
+// layer, verifying that they behave correctly when the policy infrastructure is unstable. It must not be used in
+// production.
+package chaostest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/containerssh/security"
+)
+
+// ErrInjected is returned by the calls failed by Chaos.
+var ErrInjected = errors.New("injected failure")
+
+// Chaos randomly delays and fails calls. The same seed produces the same sequence of delays and failures for the same
+// sequence of calls.
+type Chaos struct {
+	// FailureRate is the probability between 0 and 1 of a call failing with ErrInjected.
+	FailureRate float64
+	// MaxDelay is the maximum delay added to each call. The delay is chosen uniformly between 0 and MaxDelay.
+	MaxDelay time.Duration
+
+	lock   *sync.Mutex
+	random *rand.Rand
+}
+
+// New creates a Chaos with the specified seed, failure rate and maximum delay.
+func New(seed int64, failureRate float64, maxDelay time.Duration) *Chaos {
+	return &Chaos{
+		FailureRate: failureRate,
+		MaxDelay:    maxDelay,
+		lock:        &sync.Mutex{},
+		random:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// inject delays the call and returns ErrInjected if the call should fail.
+func (c *Chaos) inject() error {
+	c.lock.Lock()
+	var delay time.Duration
+	if c.MaxDelay > 0 {
+		delay = time.Duration(c.random.Int63n(int64(c.MaxDelay) + 1))
+	}
+	fail := c.random.Float64() < c.FailureRate
+	c.lock.Unlock()
+	time.Sleep(delay)
+	if fail {
+		return ErrInjected
+	}
+	return nil
+}
+
+// StateStore wraps a state store, injecting delays and failures into all calls.
+func (c *Chaos) StateStore(store security.StateStore) security.StateStore {
+	return &stateStore{chaos: c, backend: store}
+}
+
+// GroupResolver wraps a group resolver, injecting delays and failures into all calls.
+func (c *Chaos) GroupResolver(resolver security.GroupResolver) security.GroupResolver {
+	return &groupResolver{chaos: c, backend: resolver}
+}
+
+// UserCanonicalizer wraps a username canonicalizer, injecting delays and failures into all calls.
+func (c *Chaos) UserCanonicalizer(canonicalizer security.UserCanonicalizer) security.UserCanonicalizer {
+	return &userCanonicalizer{chaos: c, backend: canonicalizer}
+}
+
+// Register registers a before decision hook injecting delays and failures into the evaluation of requests, simulating
+// an unstable external decision service. Failed calls deny the request.
+func (c *Chaos) Register(hooks security.DecisionHooks) {
+	hooks.OnBeforeDecision(func(_ security.DecisionRequest) error {
+		return c.inject()
+	})
+}
+
+// ReloadChurn reloads the handler with the configurations in turn at the specified interval until the context is
+// canceled. Errors returned by Reload are passed to onError if it is not nil.
+func ReloadChurn(
+	ctx context.Context,
+	handler security.Handler,
+	configs []security.Config,
+	interval time.Duration,
+	onError func(err error),
+) {
+	if len(configs) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := handler.Reload(configs[i%len(configs)]); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+type stateStore struct {
+	chaos   *Chaos
+	backend security.StateStore
+}
+
+func (s *stateStore) Increment(key string, delta int64, max int64) (int64, bool, error) {
+	if err := s.chaos.inject(); err != nil {
+		return 0, false, err
+	}
+	return s.backend.Increment(key, delta, max)
+}
+
+func (s *stateStore) Get(key string) ([]byte, bool, error) {
+	if err := s.chaos.inject(); err != nil {
+		return nil, false, err
+	}
+	return s.backend.Get(key)
+}
+
+func (s *stateStore) Set(key string, value []byte, ttl time.Duration) error {
+	if err := s.chaos.inject(); err != nil {
+		return err
+	}
+	return s.backend.Set(key, value, ttl)
+}
+
+func (s *stateStore) SetIfAbsent(key string, value []byte, ttl time.Duration) ([]byte, bool, error) {
+	if err := s.chaos.inject(); err != nil {
+		return nil, false, err
+	}
+	return s.backend.SetIfAbsent(key, value, ttl)
+}
+
+func (s *stateStore) Delete(key string) error {
+	if err := s.chaos.inject(); err != nil {
+		return err
+	}
+	return s.backend.Delete(key)
+}
+
+type groupResolver struct {
+	chaos   *Chaos
+	backend security.GroupResolver
+}
+
+func (g *groupResolver) Groups(username string) ([]string, error) {
+	if err := g.chaos.inject(); err != nil {
+		return nil, err
+	}
+	return g.backend.Groups(username)
+}
+
+type userCanonicalizer struct {
+	chaos   *Chaos
+	backend security.UserCanonicalizer
+}
+
+func (u *userCanonicalizer) Canonicalize(username string) (string, error) {
+	if err := u.chaos.inject(); err != nil {
+		return "", err
+	}
+	return u.backend.Canonicalize(username)
+}
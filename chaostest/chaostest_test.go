@@ -0,0 +1,36 @@
+package chaostest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containerssh/security"
+	"github.com/containerssh/security/chaostest"
+)
+
+func TestChaos(t *testing.T) {
+	store := chaostest.New(1, 1, 0).StateStore(security.NewMemoryStateStore())
+	if err := store.Set("key", []byte("value"), time.Minute); !errors.Is(err, chaostest.ErrInjected) {
+		t.Fatalf("expected an injected failure, got %v", err)
+	}
+
+	store = chaostest.New(1, 0, time.Millisecond).StateStore(security.NewMemoryStateStore())
+	if err := store.Set("key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+
+	resolver := chaostest.New(1, 1, 0).GroupResolver(security.StaticGroupResolver{})
+	if _, err := resolver.Groups("alice"); !errors.Is(err, chaostest.ErrInjected) {
+		t.Fatalf("expected an injected failure, got %v", err)
+	}
+
+	evaluator, err := security.NewEvaluator(security.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chaostest.New(1, 1, 0).Register(evaluator)
+	if decision := evaluator.EvaluateShell(); decision.Allowed || decision.Reason != security.DecisionReasonVetoed {
+		t.Fatalf("expected the request to be vetoed, got %v", decision)
+	}
+}
@@ -0,0 +1,112 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+)
+
+// Exit codes returned by CheckResult.ExitCode.
+const (
+	// CheckExitOK means all policy files were checked and no problems were found.
+	CheckExitOK = 0
+	// CheckExitFindings means at least one policy file contains problems.
+	CheckExitFindings = 1
+	// CheckExitError means at least one policy file could not be read or decoded.
+	CheckExitError = 2
+)
+
+// Check finding codes in addition to the ValidationCode constants.
+const (
+	// CheckCodeUnreadable indicates a policy file that could not be read.
+	CheckCodeUnreadable = "unreadable"
+	// CheckCodeInvalidDocument indicates a policy file that is not a valid JSON document or contains unknown fields.
+	CheckCodeInvalidDocument = "invalid_document"
+	// CheckCodeListFile indicates a list file referenced by the policy that could not be loaded.
+	CheckCodeListFile = "list_file"
+)
+
+// CheckOptions configures CheckPolicyFiles.
+type CheckOptions struct {
+	// AllowUnknownFields accepts fields not known to this version of the library instead of reporting them.
+	AllowUnknownFields bool
+}
+
+// PolicyFinding is a single problem found by CheckPolicyFiles.
+type PolicyFinding struct {
+	// File is the path of the policy file.
+	File string `json:"file"`
+	// Path is the JSON path of the offending field, if known.
+	Path string `json:"path,omitempty"`
+	// Code is a machine-readable identifier of the problem, see the ValidationCode and CheckCode constants.
+	Code string `json:"code"`
+	// Message is a human-readable description of the problem.
+	Message string `json:"message"`
+}
+
+// CheckResult contains the findings of CheckPolicyFiles.
+type CheckResult struct {
+	// Files is the number of policy files checked.
+	Files int `json:"files"`
+	// Findings contains the problems found in all files.
+	Findings []PolicyFinding `json:"findings"`
+}
+
+// ExitCode returns the exit code a CI job checking the files should terminate with, see the CheckExit constants.
+func (c CheckResult) ExitCode() int {
+	exitCode := CheckExitOK
+	for _, finding := range c.Findings {
+		switch finding.Code {
+		case CheckCodeUnreadable:
+			fallthrough
+		case CheckCodeInvalidDocument:
+			return CheckExitError
+		default:
+			exitCode = CheckExitFindings
+		}
+	}
+	return exitCode
+}
+
+// CheckPolicyFiles checks JSON policy files containing a Config each, as done by a CI job before deploying them. The
+// files are decoded strictly, validated and the list files they reference are loaded. All problems are collected
+// rather than stopping at the first one.
+//goland:noinspection GoUnusedExportedFunction
+func CheckPolicyFiles(paths []string, options CheckOptions) CheckResult {
+	result := CheckResult{Files: len(paths), Findings: []PolicyFinding{}}
+	for _, p := range paths {
+		result.Findings = append(result.Findings, checkPolicyFile(p, options)...)
+	}
+	return result
+}
+
+func checkPolicyFile(path string, options CheckOptions) []PolicyFinding {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []PolicyFinding{{File: path, Code: CheckCodeUnreadable, Message: err.Error()}}
+	}
+	config := Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if !options.AllowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&config); err != nil {
+		return []PolicyFinding{{File: path, Code: CheckCodeInvalidDocument, Message: err.Error()}}
+	}
+	var findings []PolicyFinding
+	if err := config.Validate(); err != nil {
+		for _, validationError := range err.(ValidationErrors) {
+			findings = append(findings, PolicyFinding{
+				File:    path,
+				Path:    validationError.Path,
+				Code:    validationError.Code,
+				Message: validationError.Message,
+			})
+		}
+		return findings
+	}
+	if _, err := config.loadListFiles(); err != nil {
+		findings = append(findings, PolicyFinding{File: path, Code: CheckCodeListFile, Message: err.Error()})
+	}
+	return findings
+}
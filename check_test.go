@@ -0,0 +1,53 @@
+package security
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPolicyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "security")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	files := map[string]string{
+		"valid.json":    `{"defaultMode": "disable", "shell": {"mode": "enable"}}`,
+		"invalid.json":  `{"shell": {"mode": "sometimes"}, "tty": {"mode": "never"}}`,
+		"unknown.json":  `{"shel": {"mode": "enable"}}`,
+		"listfile.json": `{"command": {"allowFiles": [{"path": "` + filepath.Join(dir, "missing.csv") + `"}]}}`,
+	}
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	check := func(options CheckOptions, names ...string) CheckResult {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(dir, name)
+		}
+		return CheckPolicyFiles(paths, options)
+	}
+
+	result := check(CheckOptions{}, "valid.json")
+	assert.Equal(t, CheckResult{Files: 1, Findings: []PolicyFinding{}}, result)
+	assert.Equal(t, CheckExitOK, result.ExitCode())
+
+	result = check(CheckOptions{}, "valid.json", "invalid.json", "listfile.json")
+	assert.Equal(t, 3, result.Files)
+	assert.Len(t, result.Findings, 3)
+	assert.Equal(t, "shell.mode", result.Findings[0].Path)
+	assert.Equal(t, ValidationCodeInvalidMode, result.Findings[0].Code)
+	assert.Equal(t, CheckCodeListFile, result.Findings[2].Code)
+	assert.Equal(t, CheckExitFindings, result.ExitCode())
+
+	result = check(CheckOptions{}, "unknown.json", "missing.json")
+	assert.Equal(t, CheckCodeInvalidDocument, result.Findings[0].Code)
+	assert.Equal(t, CheckCodeUnreadable, result.Findings[1].Code)
+	assert.Equal(t, CheckExitError, result.ExitCode())
+
+	assert.Equal(t, CheckExitOK, check(CheckOptions{AllowUnknownFields: true}, "unknown.json").ExitCode())
+}
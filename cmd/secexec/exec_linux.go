@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/containerssh/security"
+	"golang.org/x/sys/unix"
+)
+
+// run applies the constraints of the security context and replaces the current process with the program.
+func run(context security.SecurityContext, args []string) error {
+	process := context.Process
+	if process.Umask != "" {
+		umask, err := process.ParseUmask()
+		if err != nil {
+			return err
+		}
+		syscall.Umask(int(umask))
+	}
+	if process.WorkingDirectory != "" {
+		if err := os.Chdir(process.WorkingDirectory); err != nil {
+			return err
+		}
+	}
+	for _, limit := range []struct {
+		resource int
+		value    int64
+	}{
+		{syscall.RLIMIT_NOFILE, process.MaxOpenFiles},
+		{unix.RLIMIT_NPROC, process.MaxProcesses},
+		{syscall.RLIMIT_FSIZE, process.MaxFileSize},
+		{syscall.RLIMIT_CPU, process.MaxCPUTime},
+		{syscall.RLIMIT_AS, process.MaxMemory},
+	} {
+		if limit.value <= 0 {
+			continue
+		}
+		rlimit := &syscall.Rlimit{Cur: uint64(limit.value), Max: uint64(limit.value)}
+		if err := syscall.Setrlimit(limit.resource, rlimit); err != nil {
+			return fmt.Errorf("failed to set resource limit %d (%w)", limit.resource, err)
+		}
+	}
+	environment := context.SanitizeEnvironment(os.Environ())
+	program := args[0]
+	if !strings.Contains(program, "/") {
+		for _, entry := range environment {
+			if strings.HasPrefix(entry, "PATH=") {
+				if err := os.Setenv("PATH", strings.TrimPrefix(entry, "PATH=")); err != nil {
+					return err
+				}
+			}
+		}
+		var err error
+		if program, err = exec.LookPath(program); err != nil {
+			return err
+		}
+	}
+	return syscall.Exec(program, args, environment)
+}
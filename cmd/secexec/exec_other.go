@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/containerssh/security"
+)
+
+// run is not supported outside of Linux since the resource limits can't be applied faithfully.
+func run(_ security.SecurityContext, _ []string) error {
+	return fmt.Errorf("secexec is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,62 @@
+// Command secexec starts a program with the constraints of a security context. It is meant for backends that are not
+// written in Go and for ForceCommand setups: the backend serializes the SecurityContext received through
+// security.SecurityContextHandler as JSON and starts the approved program through secexec, which checks that the
+// program is the one approved in the context and applies the umask, working directory, resource limits and
+// environment restrictions of the context before replacing itself with the program.
+//
+// Usage:
+//
+//	secexec -context /run/session/context.json -- /usr/bin/program arg1 arg2
+//
+// The context file may be - to read the context from the standard input.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/containerssh/security"
+)
+
+func main() {
+	contextFile := flag.String("context", "", "JSON file containing the security context, - for the standard input")
+	flag.Parse()
+	if *contextFile == "" || flag.NArg() == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: secexec -context FILE -- PROGRAM [ARGS...]")
+		os.Exit(2)
+	}
+	context, err := readContext(*contextFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read security context (%v)\n", err)
+		os.Exit(1)
+	}
+	if !context.Permits(flag.Args()) {
+		_, _ = fmt.Fprintln(os.Stderr, "the program was not approved by the security policy")
+		os.Exit(1)
+	}
+	if err := run(context, flag.Args()); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to start program (%v)\n", err)
+		os.Exit(1)
+	}
+}
+
+func readContext(file string) (security.SecurityContext, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return security.SecurityContext{}, err
+	}
+	context := security.SecurityContext{}
+	if err := json.Unmarshal(data, &context); err != nil {
+		return security.SecurityContext{}, err
+	}
+	return context, nil
+}
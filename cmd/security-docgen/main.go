@@ -0,0 +1,326 @@
+// Command security-docgen generates the configuration reference from the doc comments and struct tags of the
+// security configuration structures.
+//
+// Usage:
+//
+//	go run ./cmd/security-docgen -dir . -o docs/configuration.md
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "Directory of the security package")
+	root := flag.String("root", "Config", "Name of the root configuration structure")
+	output := flag.String("o", "", "Output file (default: standard output)")
+	flag.Parse()
+
+	pkg, err := parsePackage(*dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to parse package (%v)\n", err)
+		os.Exit(1)
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		fh, err := os.Create(*output)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to open output file (%v)\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			_ = fh.Close()
+		}()
+		writer = fh
+	}
+	if err := pkg.writeMarkdown(writer, *root); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to write documentation (%v)\n", err)
+		os.Exit(1)
+	}
+}
+
+// docPackage contains the documented types of the package.
+type docPackage struct {
+	structs map[string]*docStruct
+	enums   map[string][]string
+}
+
+type docStruct struct {
+	name   string
+	doc    string
+	fields []docField
+}
+
+type docField struct {
+	name         string
+	key          string
+	typeName     string
+	elementType  string
+	defaultValue string
+	doc          string
+}
+
+func parsePackage(dir string) (*docPackage, error) {
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(
+		fileSet,
+		dir,
+		func(info os.FileInfo) bool {
+			return !strings.HasSuffix(info.Name(), "_test.go")
+		},
+		parser.ParseComments,
+	)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &docPackage{
+		structs: map[string]*docStruct{},
+		enums:   map[string][]string{},
+	}
+	for _, p := range packages {
+		for _, file := range p.Files {
+			for _, decl := range file.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok {
+					continue
+				}
+				switch genDecl.Tok {
+				case token.TYPE:
+					pkg.addTypes(genDecl)
+				case token.CONST:
+					pkg.addEnumValues(genDecl)
+				}
+			}
+		}
+	}
+	return pkg, nil
+}
+
+func (p *docPackage) addTypes(genDecl *ast.GenDecl) {
+	for _, spec := range genDecl.Specs {
+		typeSpec := spec.(*ast.TypeSpec)
+		structType, ok := typeSpec.Type.(*ast.StructType)
+		if !ok || !typeSpec.Name.IsExported() {
+			continue
+		}
+		s := &docStruct{
+			name: typeSpec.Name.Name,
+			doc:  genDecl.Doc.Text(),
+		}
+		for _, astField := range structType.Fields.List {
+			if len(astField.Names) == 0 || !astField.Names[0].IsExported() {
+				continue
+			}
+			s.fields = append(s.fields, newDocField(astField))
+		}
+		p.structs[s.name] = s
+	}
+}
+
+func newDocField(astField *ast.Field) docField {
+	f := docField{
+		name:        astField.Names[0].Name,
+		key:         strings.ToLower(astField.Names[0].Name),
+		typeName:    typeString(astField.Type),
+		elementType: elementType(astField.Type),
+		doc:         strings.Join(strings.Fields(astField.Doc.Text()), " "),
+	}
+	if astField.Tag != nil {
+		tag, err := strconv.Unquote(astField.Tag.Value)
+		if err == nil {
+			structTag := reflect.StructTag(tag)
+			if yamlName := strings.Split(structTag.Get("yaml"), ",")[0]; yamlName != "" {
+				f.key = yamlName
+			}
+			f.defaultValue = structTag.Get("default")
+		}
+	}
+	return f
+}
+
+func (p *docPackage) addEnumValues(genDecl *ast.GenDecl) {
+	for _, spec := range genDecl.Specs {
+		valueSpec := spec.(*ast.ValueSpec)
+		ident, ok := valueSpec.Type.(*ast.Ident)
+		if !ok || len(valueSpec.Values) != 1 {
+			continue
+		}
+		literal, ok := valueSpec.Values[0].(*ast.BasicLit)
+		if !ok || literal.Kind != token.STRING {
+			continue
+		}
+		value, err := strconv.Unquote(literal.Value)
+		if err != nil || value == "" {
+			continue
+		}
+		p.enums[ident.Name] = append(p.enums[ident.Name], value)
+	}
+}
+
+// typeString returns the Go type expression as written in the source.
+func typeString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return typeString(t.X)
+	case *ast.ArrayType:
+		return "[]" + typeString(t.Elt)
+	case *ast.MapType:
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
+	case *ast.SelectorExpr:
+		return typeString(t.X) + "." + t.Sel.Name
+	default:
+		return "?"
+	}
+}
+
+// elementType returns the named type contained in a pointer, list or map, or the type itself.
+func elementType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return elementType(t.X)
+	case *ast.ArrayType:
+		return elementType(t.Elt)
+	case *ast.MapType:
+		return elementType(t.Value)
+	default:
+		return ""
+	}
+}
+
+// writeMarkdown writes the reference for the root structure and all structures reachable from it.
+func (p *docPackage) writeMarkdown(writer io.Writer, root string) error {
+	if _, ok := p.structs[root]; !ok {
+		return fmt.Errorf("structure %s not found", root)
+	}
+	var order []string
+	seen := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		order = append(order, name)
+		for _, f := range p.structs[name].fields {
+			if _, ok := p.structs[f.elementType]; ok {
+				visit(f.elementType)
+			}
+		}
+	}
+	visit(root)
+
+	var builder strings.Builder
+	builder.WriteString("# Configuration reference\n\n")
+	builder.WriteString("<!-- This file is generated by cmd/security-docgen. Do not edit. -->\n")
+	for _, name := range order {
+		s := p.structs[name]
+		builder.WriteString("\n## " + name + "\n\n")
+		if s.doc != "" {
+			builder.WriteString(strings.TrimSpace(s.doc) + "\n\n")
+		}
+		builder.WriteString("| Key | Type | Default | Description |\n")
+		builder.WriteString("|-----|------|---------|-------------|\n")
+		for _, f := range s.fields {
+			typeName := f.typeName
+			if _, ok := p.structs[f.elementType]; ok {
+				typeName = strings.Replace(
+					typeName,
+					f.elementType,
+					"["+f.elementType+"](#"+strings.ToLower(f.elementType)+")",
+					1,
+				)
+			}
+			description := f.doc
+			if values, ok := p.enums[f.elementType]; ok {
+				description += " Possible values: `" + strings.Join(values, "`, `") + "`."
+			}
+			_, _ = fmt.Fprintf(
+				&builder,
+				"| `%s` | %s | %s | %s |\n",
+				f.key,
+				escape(typeName),
+				formatDefault(f.defaultValue),
+				escape(strings.TrimSpace(description)),
+			)
+		}
+	}
+	builder.WriteString("\n## Example\n\n```yaml\n")
+	p.writeExample(&builder, root, 0, map[string]bool{})
+	builder.WriteString("```\n")
+
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// writeExample writes an example YAML document containing the default values. Scalar fields without a default and
+// structures without any fields left are omitted.
+func (p *docPackage) writeExample(builder *strings.Builder, name string, indent int, parents map[string]bool) {
+	parents[name] = true
+	defer delete(parents, name)
+	prefix := strings.Repeat("  ", indent)
+	for _, f := range p.structs[name].fields {
+		_, isStruct := p.structs[f.elementType]
+		switch {
+		case isStruct && f.typeName == f.elementType && !parents[f.elementType]:
+			var nested strings.Builder
+			p.writeExample(&nested, f.elementType, indent+1, parents)
+			if nested.Len() > 0 {
+				builder.WriteString(prefix + f.key + ":\n" + nested.String())
+			}
+		case strings.HasPrefix(f.typeName, "[]"):
+			builder.WriteString(prefix + f.key + ": []\n")
+		case strings.HasPrefix(f.typeName, "map["):
+			builder.WriteString(prefix + f.key + ": {}\n")
+		case isStruct:
+			builder.WriteString(prefix + f.key + ": null\n")
+		default:
+			if value := exampleValue(f); value != "" {
+				builder.WriteString(prefix + f.key + ": " + value + "\n")
+			}
+		}
+	}
+}
+
+// exampleValue returns the default of a scalar field as a YAML literal of the type of the field, or an empty string
+// if the field has no default. Booleans default to false.
+func exampleValue(f docField) string {
+	switch f.typeName {
+	case "bool":
+		if f.defaultValue == "" {
+			return "false"
+		}
+		return f.defaultValue
+	case "int", "int32", "int64", "uint", "uint32", "uint64", "float64", "time.Duration":
+		return f.defaultValue
+	default:
+		if f.defaultValue == "" {
+			return ""
+		}
+		return strconv.Quote(f.defaultValue)
+	}
+}
+
+func formatDefault(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "`" + escape(value) + "`"
+}
+
+func escape(text string) string {
+	return strings.ReplaceAll(text, "|", "\\|")
+}
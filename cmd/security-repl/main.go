@@ -0,0 +1,56 @@
+// Command security-repl loads a security policy and evaluates pseudo-requests typed on the standard input, printing
+// the decision for each of them. It is meant for debugging policies without setting up an SSH server. See
+// security.RunREPL for the format of the requests.
+//
+// Usage:
+//
+//	security-repl -config policy.json
+//	> exec "rsync -a /src host:/dst" as user=backup
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/containerssh/security"
+)
+
+func main() {
+	configFile := flag.String("config", "", "JSON file containing the security policy")
+	flag.Parse()
+	if *configFile == "" || flag.NArg() != 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: security-repl -config FILE")
+		os.Exit(2)
+	}
+	evaluator, err := loadEvaluator(*configFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to load security policy (%v)\n", err)
+		os.Exit(1)
+	}
+	prompt := ""
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		prompt = "> "
+	}
+	if err := security.RunREPL(evaluator, os.Stdin, os.Stdout, prompt); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read requests (%v)\n", err)
+		os.Exit(1)
+	}
+}
+
+func loadEvaluator(file string) (*security.Evaluator, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := security.Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, err
+	}
+	return security.NewEvaluator(config)
+}
@@ -0,0 +1,61 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// CommandHash returns the hash of a command in the form sha256:<hex>, as included in the audit records of exec
+// requests and matched against Command.DenyHashes. The command is normalized before hashing: leading and trailing
+// whitespace is removed and runs of whitespace outside of quotes are collapsed into a single space, so reformatted
+// copies of the same command share a hash.
+func CommandHash(command string) string {
+	sum := sha256.Sum256([]byte(normalizeCommand(command)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// normalizeCommand collapses whitespace outside of single and double quotes.
+func normalizeCommand(command string) string {
+	builder := strings.Builder{}
+	var quote rune
+	escaped := false
+	space := false
+	for _, c := range strings.TrimSpace(command) {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		}
+		if space {
+			builder.WriteRune(' ')
+			space = false
+		}
+		builder.WriteRune(c)
+	}
+	return builder.String()
+}
+
+// matchCommandHash returns true if the hash of the command is one of the specified hashes.
+func matchCommandHash(hashes []string, command string) bool {
+	if len(hashes) == 0 {
+		return false
+	}
+	hash := CommandHash(command)
+	for _, candidate := range hashes {
+		if strings.EqualFold(candidate, hash) {
+			return true
+		}
+	}
+	return false
+}
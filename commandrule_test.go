@@ -0,0 +1,67 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandRules(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		Command: CommandConfig{
+			Mode: ExecutionPolicyFilter,
+			Rules: []CommandRule{
+				{
+					Program: "kubectl",
+					Args: []ArgumentPattern{
+						{Value: "logs"},
+						{Match: ArgumentMatchAny},
+					},
+					MoreArgs: true,
+				},
+				{
+					Program: "git",
+					Args: []ArgumentPattern{
+						{Match: ArgumentMatchRegex, Value: "fetch|pull"},
+						{Match: ArgumentMatchGlob, Value: "origin*"},
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	for command, allowed := range map[string]bool{
+		"kubectl logs web-0":                 true,
+		"kubectl logs 'web 0' --follow":      true,
+		`kubectl   logs "web-1"`:             true,
+		"kubectl logs":                       false,
+		"kubectl exec web-0 -- sh":           false,
+		"kubectl logs web-0; id":             false,
+		"kubectl logs $(id)":                 false,
+		`kubectl logs "$HOME"`:               false,
+		"kubectl logs *":                     false,
+		"git pull origin":                    true,
+		"git fetch origin-mirror":            true,
+		"git push origin":                    false,
+		"git pull origin main":               false,
+		"/usr/bin/kubectl logs web-0":        false,
+		"kubectl logs 'unterminated":         false,
+		`kubectl logs web\ 0 --since="1h"`:   true,
+		"kubectl logs ~/pods":                false,
+		"kubectl logs web-0 # --follow":      false,
+		"kubectl logs web#0":                 true,
+		"kubectl logs web-0 > /tmp/leak.txt": false,
+	} {
+		assert.Equal(t, allowed, evaluator.EvaluateExec(command).Allowed, command)
+	}
+
+	assert.Error(t, CommandConfig{Rules: []CommandRule{{}}}.Validate())
+	assert.Error(t, CommandConfig{Rules: []CommandRule{{
+		Program: "git",
+		Args:    []ArgumentPattern{{Match: ArgumentMatchRegex, Value: "("}},
+	}}}.Validate())
+	assert.Error(t, CommandConfig{Rules: []CommandRule{{
+		Program: "git",
+		Args:    []ArgumentPattern{{Match: "fuzzy"}},
+	}}}.Validate())
+}
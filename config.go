@@ -1,30 +1,78 @@
 package security
 
+//go:generate go run ./cmd/security-docgen -o docs/configuration.md
+
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
 // Config is the configuration structure for security settings.
+//
+// The SSH server rejects X11 forwarding, break requests, local and remote port forwarding and Unix domain socket
+// forwarding towards the client regardless of the policy. The handlers created by New and NewHandler therefore only
+// accept X11, Break, Forwarding, ReverseForwarding and StreamLocalForwarding with their mode unset or set to disable.
+// Evaluator accepts every mode for servers that support these requests.
 type Config struct {
 	// DefaultMode sets the default execution policy for all other commands. It is recommended to set this to "disable"
 	// if for restricted setups to avoid accidentally allowing new features coming in with version upgrades.
 	DefaultMode ExecutionPolicy `json:"defaultMode" yaml:"defaultMode"`
 
+	// Defaults overrides DefaultMode for categories of requests, giving finer control over how request types not
+	// configured explicitly are treated.
+	Defaults DefaultsConfig `json:"defaults" yaml:"defaults"`
+
+	// DryRun evaluates the policy as configured, but allows the requests it would deny. Hooks registered with
+	// OnAfterDecision receive the real decision, the decision returned carries DecisionReasonDryRun. This allows
+	// introducing a policy to an existing setup and reviewing its effects before enforcing it. DryRun only applies to
+	// decisions made by the Evaluator. Requests vetoed by OnBeforeDecision hooks, the policies in Channels, Requests
+	// and GlobalRequests, the rate and session limits and the SFTP filter are still enforced.
+	DryRun bool `json:"dryRun" yaml:"dryRun"`
+
 	// ForceCommand behaves similar to the OpenSSH ForceCommand option. When set this command overrides any command
 	// requested by the client and executes this command instead. The original command supplied by the client will be
 	// set in the `SSH_ORIGINAL_COMMAND` environment variable.
 	//
-	// Setting ForceCommand changes subsystem requests into exec requests for the backends.
+	// Setting ForceCommand changes subsystem requests into exec requests for the backends. The Command, Shell and
+	// Subsystem sections can configure their own forced command, which takes precedence over this one.
+	//
+	// ForceCommand is a template that may reference the connection metadata as {{ .Username }}, {{ .RemoteIP }},
+	// {{ .ConnectionID }} and {{ .SessionID }}. The values are inserted quoted for the shell, e.g. 'alice', so the
+	// placeholders must not be put in quotes or escaped, which is rejected during validation. A literal {{ must be
+	// written as {{ "{{" }}. The remote IP is only available when the security layer is created using NewHandler.
+	//
+	// Deprecated: use ForceCommandArgs, which avoids quoting issues.
 	ForceCommand string `json:"forceCommand" yaml:"forceCommand"`
 
+	// ForceCommandArgs behaves like ForceCommand, but contains the program and its arguments as separate entries that
+	// are not interpreted by a shell. Each entry is a template like ForceCommand, the values are inserted unquoted.
+	// Backends implementing ExecArgsHandler receive the arguments as they are, other backends receive a command line
+	// with each argument quoted for the shell. ForceCommand and ForceCommandArgs cannot be set at the same time.
+	ForceCommandArgs []string `json:"forceCommandArgs" yaml:"forceCommandArgs"`
+
+	// ConfineToHome rejects command requests whose arguments reference paths outside of the user's home directory,
+	// such as absolute paths or paths escaping via "..", as well as commands that can't be split into words without
+	// a shell, e.g. because they contain metacharacters or ~. The check is lexical, does not follow symlinks and is
+	// best-effort: programs may open paths that don't appear in their arguments. Shell requests are not confined. If
+	// SFTP.Root is not set, the SFTP filter confines SFTP subsystems to the home directory.
+	ConfineToHome bool `json:"confineToHome" yaml:"confineToHome"`
+	// HomeDirectory is a template for the user's home directory used by ConfineToHome. The template may reference
+	// {{ .Username }}.
+	HomeDirectory string `json:"homeDirectory" yaml:"homeDirectory" default:"/home/{{ .Username }}"`
+
 	// Env controls whether to allow or block setting environment variables.
 	Env EnvConfig `json:"env" yaml:"env"`
 	// Command controls whether to allow or block command ("exec") requests via SSh.
 	Command CommandConfig `json:"command" yaml:"command"`
+	// Rsync controls rsync transfers, which arrive as exec requests running rsync --server.
+	Rsync RsyncConfig `json:"rsync" yaml:"rsync"`
 	// Shell controls whether to allow or block shell requests via SSh.
 	Shell ShellConfig `json:"shell" yaml:"shell"`
 	// Subsystem controls whether to allow or block subsystem requests via SSH.
 	Subsystem SubsystemConfig `json:"subsystem" yaml:"subsystem"`
+	// SFTP restricts the operations available in the sftp subsystem once it has been allowed.
+	SFTP SFTPConfig `json:"sftp" yaml:"sftp"`
 
 	// TTY controls how to treat TTY/PTY requests by clients.
 	TTY TTYConfig `json:"tty" yaml:"tty"`
@@ -32,142 +80,1253 @@ type Config struct {
 	// Signal configures how to handle signal requests to running programs.
 	Signal SignalConfig `json:"signal" yaml:"signal"`
 
+	// X11 configures how to handle X11 forwarding requests (x11-req). The request policy for x11-req in Requests is
+	// applied first.
+	X11 X11Config `json:"x11" yaml:"x11"`
+
+	// Break configures how to handle break requests (RFC 4335), which some backends translate into a break on a
+	// serial line. The request policy for break in Requests is applied first.
+	Break BreakConfig `json:"break" yaml:"break"`
+
+	// Prompt configures the confirmation requested from the user for sections in ExecutionPolicyPrompt mode.
+	Prompt PromptConfig `json:"prompt" yaml:"prompt"`
+
+	// Channels contains policies for opening channels, keyed by the channel type (e.g. session, direct-tcpip or x11).
+	// Session channels not listed here are permitted, other channel types not listed here are treated according to
+	// Defaults.Channels or DefaultMode.
+	Channels map[string]ChannelConfig `json:"channels" yaml:"channels"`
+
+	// Requests contains policies for channel request types not covered by other sections, keyed by the request type
+	// (e.g. hostkeys-prove-00@openssh.com). Request types not listed here are treated according to
+	// Defaults.ChannelRequests or DefaultMode.
+	//
+	// These requests are not supported by the SSH server and are rejected towards the client. The policy controls if
+	// the backend is notified of them.
+	Requests map[string]RequestConfig `json:"requests" yaml:"requests"`
+
+	// GlobalRequests contains policies for connection-level request types, keyed by the request type (e.g.
+	// tcpip-forward, cancel-tcpip-forward or keepalive@openssh.com). Request types not listed here are treated
+	// according to Defaults.GlobalRequests or DefaultMode.
+	//
+	// These requests are not supported by the SSH server and are rejected towards the client. The policy controls if
+	// the backend is notified of them.
+	GlobalRequests map[string]RequestConfig `json:"globalRequests" yaml:"globalRequests"`
+
+	// Egress declares which network destinations programs started in a session may connect to.
+	Egress EgressConfig `json:"egress" yaml:"egress"`
+
+	// Process contains resource limits and other constraints for the programs started in a session. They are passed
+	// to backends in the SecurityContext.
+	Process ProcessConfig `json:"process" yaml:"process"`
+
+	// Forwarding configures which destinations clients may connect to using local port forwarding (direct-tcpip
+	// channels).
+	Forwarding ForwardingConfig `json:"forwarding" yaml:"forwarding"`
+
+	// ReverseForwarding configures which addresses and ports clients may bind on the server using remote port
+	// forwarding (tcpip-forward requests).
+	ReverseForwarding ReverseForwardingConfig `json:"reverseForwarding" yaml:"reverseForwarding"`
+
+	// StreamLocalForwarding configures which Unix domain sockets clients may connect to or create using
+	// direct-streamlocal@openssh.com channels and streamlocal-forward@openssh.com requests.
+	StreamLocalForwarding StreamLocalConfig `json:"streamLocalForwarding" yaml:"streamLocalForwarding"`
+
 	// MaxSessions drives how many session channels can be open at the same time for a single network connection.
 	// -1 means unlimited. It is strongly recommended to configure this to a sane value, e.g. 10.
 	MaxSessions int `json:"maxSessions" yaml:"maxSessions" default:"-1"`
+
+	// DenyDelay pads the response time of rejected channels, global requests and channel requests so clients can't
+	// use timing differences to map the allow and deny lists.
+	DenyDelay DenyDelayConfig `json:"denyDelay" yaml:"denyDelay"`
+
+	// MaxConnectionsPerIP drives how many network connections can be open at the same time from a single IP address.
+	// -1 means unlimited. This setting only takes effect when the security layer is created using NewHandler.
+	MaxConnectionsPerIP int `json:"maxConnectionsPerIP" yaml:"maxConnectionsPerIP" default:"-1"`
+	// IPv4Aggregation is the prefix length IPv4 addresses are aggregated to for per-IP limits. For example, 24 counts
+	// all connections from the same /24 network together. 0 means no aggregation.
+	IPv4Aggregation int `json:"ipv4Aggregation" yaml:"ipv4Aggregation" default:"0"`
+	// IPv6Aggregation is the prefix length IPv6 addresses are aggregated to for per-IP limits. Since clients usually
+	// have a whole /64 at their disposal, limiting individual IPv6 addresses is easy to bypass. 0 means no
+	// aggregation.
+	IPv6Aggregation int `json:"ipv6Aggregation" yaml:"ipv6Aggregation" default:"64"`
+
+	// Tenant identifies the tenant the policy belongs to in multi-tenant deployments. It is not evaluated, but passed
+	// to backends in the SecurityContext.
+	Tenant string `json:"tenant" yaml:"tenant"`
+
+	// Keys is reserved for policies for specific SSH public keys, keyed by their SHA256 fingerprint (e.g. SHA256:...).
+	// The SSH server also reports keys the client only offered without proving it holds them, and does not report
+	// which key was finally verified, so the key a connection authenticated with is not known. Validation rejects
+	// policies in Keys until the SSH server reports the verified key.
+	Keys map[string]Config `json:"keys" yaml:"keys"`
+
+	// Groups contains policy overrides for groups of users, keyed by the group name. The groups of a user are
+	// determined by the GroupResolver passed to Handler.SetGroupResolver, or passed to Evaluator.WithGroups. The
+	// overrides of the groups are merged onto this policy in the order of the groups, the same way as Users.
+	Groups map[string]Config `json:"groups" yaml:"groups"`
+
+	// Users contains policy overrides for specific users, keyed by the username. The override is merged onto this
+	// policy after the overrides in Groups: settings configured in the override take precedence, settings left at
+	// their zero value (e.g. an unconfigured mode, false or 0) fall through to this policy. Lists configured in the
+	// override replace the lists of this policy, map entries are added or replaced individually. Settings listed in
+	// Explicit take precedence even at their zero value. Keys, Groups and Users within an override are ignored.
+	Users map[string]Config `json:"users" yaml:"users"`
+
+	// Profiles contains named policy overlays referenced by Downgrade. They are merged onto the policy the same way as
+	// the overrides in Users.
+	Profiles map[string]Config `json:"profiles" yaml:"profiles"`
+
+	// Downgrade maps authentication methods (password, keyboard-interactive or publickey) to the name of the profile
+	// in Profiles applied to users who authenticated with them, e.g. to disable the shell and forwarding for password
+	// logins. The profile is merged onto the policy after the overrides in Groups and Users. This setting only
+	// takes effect when the security layer is created using NewHandler.
+	Downgrade map[AuthMethod]string `json:"downgrade" yaml:"downgrade"`
+
+	// Explicit lists settings of an override or overlay that take precedence even at their zero value, e.g. to turn
+	// off confineToHome or dryRun for a user. Settings are referenced by the path of their keys, e.g. confineToHome,
+	// maxSessions or env.hardenEnvironment. A section such as env replaces the whole section of the policy below. It
+	// has no effect in the top level policy.
+	Explicit []string `json:"explicit" yaml:"explicit"`
+
+	// Quarantine applies a restricted policy to users and public keys connecting for the first time. This setting only
+	// takes effect when the security layer is created using NewHandler.
+	Quarantine QuarantineConfig `json:"quarantine" yaml:"quarantine"`
+
+	// Recertification enforces periodic access reviews. Once the recertification date of a user has passed the
+	// configured action is applied until the date is refreshed.
+	Recertification RecertificationConfig `json:"recertification" yaml:"recertification"`
+
+	// Summary displays a summary of the effective security policy to users starting an interactive shell.
+	Summary SummaryConfig `json:"summary" yaml:"summary"`
+
+	// Sampling reports a share of the allowed requests with their full payload to the audit stream, even if they
+	// are not in ExecutionPolicyAudit mode. This setting is only read from the top level configuration, policies in
+	// Keys and Quarantine cannot override it.
+	Sampling SamplingConfig `json:"sampling" yaml:"sampling"`
+
+	// TerminationNotice is the message sent to the client when its session is terminated using the SessionTracker.
+	// The template may reference {{ .Reason }}.
+	TerminationNotice string `json:"terminationNotice" yaml:"terminationNotice" default:"Your session has been terminated: {{ .Reason }}"`
+
+	// ConnectionRate limits how many new connections can be opened per minute by a single IP address or user. This
+	// setting only takes effect when the security layer is created using NewHandler.
+	ConnectionRate RateLimitConfig `json:"connectionRate" yaml:"connectionRate"`
+
+	// patterns contains the compiled patterns of the configuration, see prepare. It is nil for configurations that
+	// have not been prepared, their patterns are compiled on every match.
+	patterns *patternSet
 }
 
-// Validate validates a shell configuration
+// Validate validates the configuration. If the configuration is invalid the returned error is ValidationErrors,
+// listing all problems found.
 func (c Config) Validate() error {
-	if err := c.DefaultMode.Validate(); err != nil {
-		return fmt.Errorf("invalid defaultMode configuration (%w)", err)
+	v := &validator{}
+	c.validate(v, "")
+	return v.result()
+}
+
+// prepare loads the list files referenced by the configuration, validates it and compiles its patterns. The compiled
+// patterns are stored with the returned configuration.
+func (c Config) prepare() (Config, error) {
+	config, err := c.loadListFiles()
+	if err != nil {
+		return c, fmt.Errorf("invalid security configuration (%w)", err)
+	}
+	v := &validator{patterns: newPatternSet()}
+	config.validate(v, "")
+	if err := v.result(); err != nil {
+		return c, fmt.Errorf("invalid security configuration (%w)", err)
 	}
-	if err := c.Env.Validate(); err != nil {
-		return fmt.Errorf("invalid env configuration (%w)", err)
+	config.patterns = v.patterns
+	return config, nil
+}
+
+// prepareForServer prepares the configuration like prepare for the handlers created by New and NewHandler, which
+// additionally reject policies for requests the SSH server doesn't support.
+func (c Config) prepareForServer() (Config, error) {
+	config, err := c.prepare()
+	if err != nil {
+		return c, err
+	}
+	v := &validator{}
+	config.validateServerSupport(v, "")
+	if err := v.result(); err != nil {
+		return c, fmt.Errorf("invalid security configuration (%w)", err)
+	}
+	return config, nil
+}
+
+// validateServerSupport rejects enabling the sections for requests the SSH server rejects towards the client
+// regardless of the policy. Their settings would look like they are enforced without having any effect.
+func (c Config) validateServerSupport(v *validator, path string) {
+	sections := []struct {
+		name string
+		mode ExecutionPolicy
+	}{
+		{"x11", c.X11.Mode},
+		{"break", c.Break.Mode},
+		{"forwarding", c.Forwarding.Mode},
+		{"reverseForwarding", c.ReverseForwarding.Mode},
+		{"streamLocalForwarding", c.StreamLocalForwarding.Mode},
 	}
-	if err := c.Command.Validate(); err != nil {
-		return fmt.Errorf("invalid command configuration (%w)", err)
+	for _, section := range sections {
+		if section.mode != ExecutionPolicyUnconfigured && section.mode != ExecutionPolicyDisable {
+			v.fail(
+				field(field(path, section.name), "mode"),
+				ValidationCodeUnsupported,
+				"%s is not supported by the SSH server, only %s is accepted",
+				section.name,
+				ExecutionPolicyDisable,
+			)
+		}
 	}
-	if err := c.Shell.Validate(); err != nil {
-		return fmt.Errorf("invalid shell configuration (%w)", err)
+	for group, groupConfig := range c.Groups {
+		groupConfig.validateServerSupport(v, key(field(path, "groups"), group))
 	}
-	if err := c.Subsystem.Validate(); err != nil {
-		return fmt.Errorf("invalid subsystem configuration (%w)", err)
+	for username, userConfig := range c.Users {
+		userConfig.validateServerSupport(v, key(field(path, "users"), username))
 	}
-	if err := c.TTY.Validate(); err != nil {
-		return fmt.Errorf("invalid TTY configuration (%w)", err)
+	for name, profileConfig := range c.Profiles {
+		profileConfig.validateServerSupport(v, key(field(path, "profiles"), name))
 	}
-	if err := c.Signal.Validate(); err != nil {
-		return fmt.Errorf("invalid signal configuration (%w)", err)
+	if c.Quarantine.Policy != nil {
+		c.Quarantine.Policy.validateServerSupport(v, field(field(path, "quarantine"), "policy"))
+	}
+}
+
+func (c Config) validate(v *validator, path string) {
+	validateMode(v, field(path, "defaultMode"), c.DefaultMode, false)
+	c.Defaults.validate(v, field(path, "defaults"))
+	c.Env.validate(v, field(path, "env"))
+	c.Command.validate(v, field(path, "command"))
+	c.Rsync.validate(v, field(path, "rsync"))
+	c.Shell.validate(v, field(path, "shell"))
+	c.Subsystem.validate(v, field(path, "subsystem"))
+	c.SFTP.validate(v, field(path, "sftp"))
+	c.TTY.validate(v, field(path, "tty"))
+	c.Signal.validate(v, field(path, "signal"))
+	c.X11.validate(v, field(path, "x11"))
+	c.Break.validate(v, field(path, "break"))
+	for channelType, channelConfig := range c.Channels {
+		channelConfig.validate(v, key(field(path, "channels"), channelType))
+	}
+	for requestType, requestConfig := range c.Requests {
+		requestConfig.validate(v, key(field(path, "requests"), requestType))
+	}
+	for requestType, requestConfig := range c.GlobalRequests {
+		requestConfig.validate(v, key(field(path, "globalRequests"), requestType))
+	}
+	c.Prompt.validate(v, field(path, "prompt"))
+	c.Egress.validate(v, field(path, "egress"))
+	c.Process.validate(v, field(path, "process"))
+	c.Forwarding.validate(v, field(path, "forwarding"))
+	c.Sampling.validate(v, field(path, "sampling"))
+	c.ReverseForwarding.validate(v, field(path, "reverseForwarding"))
+	c.StreamLocalForwarding.validate(v, field(path, "streamLocalForwarding"))
+	if _, err := parseHomeDirectory(c.HomeDirectory); err != nil {
+		v.check(field(path, "homeDirectory"), ValidationCodeInvalidTemplate, err)
+	}
+	validateForceCommand(v, path, c.ForceCommand, c.ForceCommandArgs)
+	if _, err := parseTerminationNotice(c.TerminationNotice); err != nil {
+		v.check(field(path, "terminationNotice"), ValidationCodeInvalidTemplate, err)
 	}
 	if c.MaxSessions < -1 {
-		return fmt.Errorf("invalid maxSessions setting: %d", c.MaxSessions)
+		v.fail(field(path, "maxSessions"), ValidationCodeOutOfRange, "invalid maxSessions setting: %d", c.MaxSessions)
+	}
+	if c.MaxConnectionsPerIP < -1 {
+		v.fail(
+			field(path, "maxConnectionsPerIP"),
+			ValidationCodeOutOfRange,
+			"invalid maxConnectionsPerIP setting: %d",
+			c.MaxConnectionsPerIP,
+		)
+	}
+	if c.IPv4Aggregation < 0 || c.IPv4Aggregation > 32 {
+		v.fail(
+			field(path, "ipv4Aggregation"),
+			ValidationCodeOutOfRange,
+			"invalid ipv4Aggregation setting: %d",
+			c.IPv4Aggregation,
+		)
+	}
+	if c.IPv6Aggregation < 0 || c.IPv6Aggregation > 128 {
+		v.fail(
+			field(path, "ipv6Aggregation"),
+			ValidationCodeOutOfRange,
+			"invalid ipv6Aggregation setting: %d",
+			c.IPv6Aggregation,
+		)
+	}
+	if len(c.Keys) > 0 {
+		v.fail(
+			field(path, "keys"),
+			ValidationCodeUnsupported,
+			"keys are not supported because the SSH server does not report the verified public key",
+		)
+	}
+	for keyFingerprint, keyConfig := range c.Keys {
+		keyPath := key(field(path, "keys"), keyFingerprint)
+		v.check(keyPath, ValidationCodeInvalidFormat, validateFingerprint(keyFingerprint))
+		keyConfig.validate(v, keyPath)
+	}
+	for group, groupConfig := range c.Groups {
+		groupConfig.validate(v, key(field(path, "groups"), group))
+	}
+	for username, userConfig := range c.Users {
+		userConfig.validate(v, key(field(path, "users"), username))
+	}
+	for name, profileConfig := range c.Profiles {
+		profileConfig.validate(v, key(field(path, "profiles"), name))
+	}
+	for method, profile := range c.Downgrade {
+		methodPath := key(field(path, "downgrade"), string(method))
+		v.check(methodPath, ValidationCodeInvalidFormat, method.Validate())
+		if _, ok := c.Profiles[profile]; !ok {
+			v.fail(methodPath, ValidationCodeInvalidFormat, "unknown profile: %s", profile)
+		}
+	}
+	validateExplicit(v, field(path, "explicit"), c.Explicit)
+	c.Quarantine.validate(v, field(path, "quarantine"))
+	c.Recertification.validate(v, field(path, "recertification"))
+	c.DenyDelay.validate(v, field(path, "denyDelay"))
+	c.ConnectionRate.validate(v, field(path, "connectionRate"))
+}
+
+// DefaultsConfig contains the default execution policies for categories of requests. Categories left unconfigured fall
+// back to DefaultMode.
+type DefaultsConfig struct {
+	// SessionRequests applies to the env, tty, command, shell, subsystem, signal, x11 and break sections.
+	SessionRequests ExecutionPolicy `json:"sessionRequests" yaml:"sessionRequests" default:""`
+	// ChannelRequests applies to channel request types not listed in Requests.
+	ChannelRequests ExecutionPolicy `json:"channelRequests" yaml:"channelRequests" default:""`
+	// GlobalRequests applies to global request types not listed in GlobalRequests.
+	GlobalRequests ExecutionPolicy `json:"globalRequests" yaml:"globalRequests" default:""`
+	// Channels applies to channel types other than session not listed in Channels.
+	Channels ExecutionPolicy `json:"channels" yaml:"channels" default:""`
+	// Forwarding applies to the forwarding, reverseForwarding and streamLocalForwarding sections.
+	Forwarding ExecutionPolicy `json:"forwarding" yaml:"forwarding" default:""`
+}
+
+// Validate validates the default execution policies.
+func (d DefaultsConfig) Validate() error {
+	v := &validator{}
+	d.validate(v, "")
+	return v.result()
+}
+
+func (d DefaultsConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "sessionRequests"), d.SessionRequests, false)
+	validateMode(v, field(path, "channelRequests"), d.ChannelRequests, false)
+	validateMode(v, field(path, "globalRequests"), d.GlobalRequests, false)
+	validateMode(v, field(path, "channels"), d.Channels, false)
+	validateMode(v, field(path, "forwarding"), d.Forwarding, false)
+}
+
+// forRequest returns the default execution policy for a request type handled by the Evaluator.
+func (d DefaultsConfig) forRequest(requestType string) ExecutionPolicy {
+	switch requestType {
+	case "direct-tcpip":
+		fallthrough
+	case "tcpip-forward":
+		fallthrough
+	case "direct-streamlocal@openssh.com":
+		fallthrough
+	case "streamlocal-forward@openssh.com":
+		return d.Forwarding
+	default:
+		return d.SessionRequests
 	}
-	return nil
 }
 
 // EnvConfig configures setting environment variables.
 type EnvConfig struct {
 	// Mode configures how to treat environment variable requests by SSH clients.
 	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// MatchMode configures how the Allow and Deny entries are matched. Entries may select a different match mode with
+	// a prefix, see MatchMode.
+	MatchMode MatchMode `json:"matchMode" yaml:"matchMode" default:"exact"`
 	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows the specified environment variables to be
 	// set.
 	Allow []string
+	// AllowFiles adds the entries of CSV or TSV files to Allow when the handler is created.
+	AllowFiles []ListFile `json:"allowFiles" yaml:"allowFiles"`
 	// Allow takes effect when Mode is not ExecutionPolicyDisable and disallows the specified environment variables to
 	// be set.
 	Deny []string
+	// HardenEnvironment forces a safe PATH, IFS and SHELLOPTS for all programs, clears BASH_ENV and ENV, and rejects
+	// any attempt by the client to set these variables regardless of Mode.
+	HardenEnvironment bool `json:"hardenEnvironment" yaml:"hardenEnvironment"`
+	// AllowDangerous allows setting variables that alter the behavior of the dynamic linker or interpreters, such as
+	// LD_PRELOAD, LD_LIBRARY_PATH, BASH_ENV or PYTHONSTARTUP. By default these are rejected unless Mode is
+	// ExecutionPolicyFilter, in which case only the variables in Allow can be set.
+	AllowDangerous bool `json:"allowDangerous" yaml:"allowDangerous"`
+	// Values constrains the values of environment variables, keyed by the variable name. The entry * applies to all
+	// variables without an entry of their own. Variables whose value violates the constraints are rejected.
+	Values map[string]EnvValueConfig `json:"values" yaml:"values"`
+	// Set contains environment variables passed to every program, e.g. SSH_POLICY_ID. The values are templates like
+	// ForceCommandArgs, e.g. {{ .SessionID }}. They override the variables set by the client and by HardenEnvironment.
+	Set map[string]string `json:"set" yaml:"set"`
+	// MaxCount is the maximum number of environment variable requests per session, including rejected ones. Further
+	// requests are rejected. 0 means unlimited.
+	MaxCount int `json:"maxCount" yaml:"maxCount" default:"0"`
+	// MaxValueLength is the maximum length in bytes of environment variable values. 0 means unlimited.
+	MaxValueLength int `json:"maxValueLength" yaml:"maxValueLength" default:"0"`
 }
 
 // Validate validates a shell configuration
 func (e EnvConfig) Validate() error {
-	if err := e.Mode.Validate(); err != nil {
-		return fmt.Errorf("invalid mode (%w)", err)
+	v := &validator{}
+	e.validate(v, "")
+	return v.result()
+}
+
+func (e EnvConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), e.Mode, false)
+	v.check(field(path, "matchMode"), ValidationCodeInvalidMode, e.MatchMode.Validate())
+	validatePatterns(v, field(path, "allow"), e.MatchMode, e.Allow)
+	validatePatterns(v, field(path, "deny"), e.MatchMode, e.Deny)
+	validateListFiles(v, field(path, "allowFiles"), e.AllowFiles)
+	for name, valueConfig := range e.Values {
+		valueConfig.validate(v, key(field(path, "values"), name))
+	}
+	if e.MaxCount < 0 {
+		v.fail(field(path, "maxCount"), ValidationCodeOutOfRange, "invalid maxCount setting: %d", e.MaxCount)
+	}
+	if e.MaxValueLength < 0 {
+		v.fail(
+			field(path, "maxValueLength"),
+			ValidationCodeOutOfRange,
+			"invalid maxValueLength setting: %d",
+			e.MaxValueLength,
+		)
+	}
+	for name, value := range e.Set {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			v.fail(key(field(path, "set"), name), ValidationCodeInvalidFormat, "invalid variable name: %s", name)
+		}
+		if _, err := parseForceCommand(value); err != nil {
+			v.check(key(field(path, "set"), name), ValidationCodeInvalidTemplate, err)
+		}
+	}
+}
+
+// EnvValueConfig constrains the value of an environment variable.
+type EnvValueConfig struct {
+	// MaxLength is the maximum length of the value in bytes. 0 means unlimited.
+	MaxLength int `json:"maxLength" yaml:"maxLength" default:"0"`
+	// Pattern is a regular expression in the Go syntax the whole value must match, e.g. [a-zA-Z_]+\.UTF-8 for LANG.
+	Pattern string `json:"pattern" yaml:"pattern"`
+	// ASCII restricts the value to printable ASCII characters.
+	ASCII bool `json:"ascii" yaml:"ascii"`
+	// AllowControlCharacters allows control characters such as newlines or escape sequences in the value. They are
+	// rejected by default.
+	AllowControlCharacters bool `json:"allowControlCharacters" yaml:"allowControlCharacters"`
+}
+
+// Validate validates the environment variable value constraints.
+func (e EnvValueConfig) Validate() error {
+	v := &validator{}
+	e.validate(v, "")
+	return v.result()
+}
+
+func (e EnvValueConfig) validate(v *validator, path string) {
+	if e.MaxLength < 0 {
+		v.fail(field(path, "maxLength"), ValidationCodeOutOfRange, "invalid maxLength setting: %d", e.MaxLength)
+	}
+	if e.Pattern != "" {
+		_, err := v.patterns.compile(MatchModeRegex, e.Pattern)
+		v.check(field(path, "pattern"), ValidationCodeInvalidPattern, err)
 	}
-	return nil
 }
 
 // CommandConfig controls command executions via SSH (exec requests).
 type CommandConfig struct {
 	// Mode configures how to treat command execution (exec) requests by SSH clients.
 	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// MatchMode configures how the Allow entries are matched. Entries may select a different match mode with a
+	// prefix, see MatchMode. Glob patterns should be written with care, a * also matches shell metacharacters such as
+	// ; or |.
+	MatchMode MatchMode `json:"matchMode" yaml:"matchMode" default:"exact"`
 	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows the specified commands to be
-	// executed. Note that the match an exact match is performed to avoid shell injections, etc.
+	// executed. Note that by default an exact match is performed to avoid shell injections, etc.
 	Allow []string
+	// AllowFiles adds the entries of CSV or TSV files to Allow when the handler is created.
+	AllowFiles []ListFile `json:"allowFiles" yaml:"allowFiles"`
+	// Rules takes effect when Mode is ExecutionPolicyFilter and allows the commands matching one of the rules in
+	// addition to the commands in Allow. Unlike Allow, rules match the program and each argument separately, so e.g.
+	// kubectl logs can be allowed with any pod name while kubectl exec is not.
+	Rules []CommandRule `json:"rules" yaml:"rules"`
+	// DenyHashes disallows the commands with the specified hashes in the form sha256:<hex> regardless of Mode, unless
+	// it is ExecutionPolicyDisable. The hash of a command is included in the audit records of exec requests and can
+	// be computed using CommandHash.
+	DenyHashes []string `json:"denyHashes" yaml:"denyHashes"`
+	// Rewrite maps program names to the programs executed instead, e.g. git-upload-pack to an audited wrapper. The
+	// command is split into words the way a POSIX shell would, the first word is replaced and the remaining words are
+	// passed on as single-quoted arguments. Commands that can't be split, e.g. because they contain unquoted shell
+	// metacharacters, are rejected while Rewrite is configured. Requests are evaluated against Allow and Deny using the
+	// original command.
+	Rewrite map[string]string `json:"rewrite" yaml:"rewrite"`
+	// ForceCommand replaces the program of exec requests, taking precedence over the global ForceCommand. The
+	// same template values are available.
+	ForceCommand string `json:"forceCommand" yaml:"forceCommand"`
+	// ForceCommandArgs replaces the program of exec requests like the global ForceCommandArgs, taking precedence
+	// over the global ForceCommand and ForceCommandArgs.
+	ForceCommandArgs []string `json:"forceCommandArgs" yaml:"forceCommandArgs"`
 }
 
 // Validate validates a shell configuration
 func (c CommandConfig) Validate() error {
-	if err := c.Mode.Validate(); err != nil {
-		return fmt.Errorf("invalid mode (%w)", err)
+	v := &validator{}
+	c.validate(v, "")
+	return v.result()
+}
+
+func (c CommandConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), c.Mode, true)
+	v.check(field(path, "matchMode"), ValidationCodeInvalidMode, c.MatchMode.Validate())
+	validatePatterns(v, field(path, "allow"), c.MatchMode, c.Allow)
+	validateListFiles(v, field(path, "allowFiles"), c.AllowFiles)
+	for i, rule := range c.Rules {
+		rule.validate(v, index(field(path, "rules"), i))
+	}
+	for i, hash := range c.DenyHashes {
+		_, err := parseChecksum(hash)
+		v.check(index(field(path, "denyHashes"), i), ValidationCodeInvalidFormat, err)
+	}
+	validateRewrite(v, field(path, "rewrite"), c.Rewrite)
+	validateForceCommand(v, path, c.ForceCommand, c.ForceCommandArgs)
+}
+
+// RsyncConfig controls rsync transfers over SSH. An rsync client starts the transfer by executing rsync --server on
+// the server side, the arguments of which contain the direction of the transfer and the paths on the server.
+//
+// If Mode is set, exec requests running rsync --server are evaluated according to this section instead of Command. If
+// Command is in ExecutionPolicyFilter mode, the command must additionally be allowed by Command. Rsync commands that
+// can't be parsed unambiguously, e.g. because they contain quotes or shell metacharacters, and rsync daemon mode,
+// which doesn't reveal the requested module in the command, are rejected. The values of server options taking a path,
+// such as --temp-dir or --log-file, are checked like the transfer paths.
+type RsyncConfig struct {
+	// Mode configures how to treat rsync transfers. ExecutionPolicyPrompt is not supported.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// Executables lists the absolute paths of rsync executables clients may run besides a plain rsync. Commands
+	// running any other program are not treated as rsync transfers.
+	Executables []string `json:"executables" yaml:"executables"`
+	// Direction only allows transfers in the specified direction. Empty allows both directions.
+	Direction RsyncDirection `json:"direction" yaml:"direction" default:""`
+	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows transfers to and from paths within the
+	// specified paths. Relative entries are relative to the working directory of the backend, "." allows all
+	// relative paths that don't leave it.
+	Allow []string `json:"allow" yaml:"allow"`
+	// Deny takes effect when Mode is not ExecutionPolicyFilter and rejects transfers to and from paths within the
+	// specified paths.
+	Deny []string `json:"deny" yaml:"deny"`
+	// MaxFileSize is the largest file size in bytes clients may push. It is enforced by passing --max-size to the
+	// rsync server, which skips larger files. It has no effect on pulls. 0 means unlimited.
+	MaxFileSize int64 `json:"maxFileSize" yaml:"maxFileSize" default:"0"`
+}
+
+// Validate validates the rsync configuration.
+func (r RsyncConfig) Validate() error {
+	v := &validator{}
+	r.validate(v, "")
+	return v.result()
+}
+
+func (r RsyncConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), r.Mode, false)
+	for i, executable := range r.Executables {
+		if !strings.HasPrefix(executable, "/") {
+			v.fail(index(field(path, "executables"), i), ValidationCodeInvalidFormat, "not an absolute path: %s", executable)
+		}
+	}
+	v.check(field(path, "direction"), ValidationCodeInvalidMode, r.Direction.Validate())
+	if r.MaxFileSize < 0 {
+		v.fail(field(path, "maxFileSize"), ValidationCodeOutOfRange, "invalid maxFileSize setting: %d", r.MaxFileSize)
 	}
-	return nil
 }
 
 // ShellConfig controls shell executions via SSH.
 type ShellConfig struct {
 	// Mode configures how to treat shell requests by SSH clients.
 	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// ForceCommand replaces the program of shell requests, taking precedence over the global ForceCommand. The
+	// same template values are available.
+	ForceCommand string `json:"forceCommand" yaml:"forceCommand"`
+	// ForceCommandArgs replaces the program of shell requests like the global ForceCommandArgs, taking precedence
+	// over the global ForceCommand and ForceCommandArgs.
+	ForceCommandArgs []string `json:"forceCommandArgs" yaml:"forceCommandArgs"`
 }
 
 // Validate validates a shell configuration
 func (s ShellConfig) Validate() error {
-	if err := s.Mode.Validate(); err != nil {
-		return fmt.Errorf("invalid mode (%w)", err)
+	v := &validator{}
+	s.validate(v, "")
+	return v.result()
+}
+
+func (s ShellConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), s.Mode, true)
+	validateForceCommand(v, path, s.ForceCommand, s.ForceCommandArgs)
+}
+
+// SFTPConfig restricts the operations of the sftp subsystem. The restrictions are enforced by inspecting the SFTP
+// packets sent by the client and answering forbidden operations with a permission denied status instead of passing
+// them to the backend. If any restriction is configured, extension requests not known to this library are rejected,
+// as are exec requests that refer to a known SFTP server program (sftp-server or gesftpserver). The latter check is
+// best-effort since the server may be started under a different name, so Command should only allow the required
+// commands.
+type SFTPConfig struct {
+	// ReadOnly rejects all operations that modify the file system.
+	ReadOnly bool `json:"readOnly" yaml:"readOnly"`
+	// NoDelete rejects removing files and directories, as well as the posix-rename@openssh.com extension, which
+	// replaces existing files. Plain renames are passed on since the SFTP protocol requires them to fail if the
+	// target exists.
+	NoDelete bool `json:"noDelete" yaml:"noDelete"`
+	// NoSymlink rejects creating symbolic and hard links.
+	NoSymlink bool `json:"noSymlink" yaml:"noSymlink"`
+	// NoSetstat rejects changing file attributes, such as permissions, ownership and timestamps.
+	NoSetstat bool `json:"noSetstat" yaml:"noSetstat"`
+	// Root is an absolute path all paths accessed must be within. Relative paths are accepted as long as they don't
+	// leave the working directory of the backend, which should therefore be within Root. Symbolic links are not
+	// resolved, so Root should be combined with NoSymlink. If Root is empty and ConfineToHome is enabled, the home
+	// directory of the user is used.
+	Root string `json:"root" yaml:"root"`
+	// MaxFileSize is the largest file size in bytes clients may write. 0 means unlimited.
+	MaxFileSize int64 `json:"maxFileSize" yaml:"maxFileSize" default:"0"`
+}
+
+// Validate validates the SFTP configuration.
+func (s SFTPConfig) Validate() error {
+	v := &validator{}
+	s.validate(v, "")
+	return v.result()
+}
+
+func (s SFTPConfig) validate(v *validator, path string) {
+	if s.Root != "" && !strings.HasPrefix(s.Root, "/") {
+		v.fail(field(path, "root"), ValidationCodeInvalidFormat, "the SFTP root must be an absolute path: %s", s.Root)
+	}
+	if s.MaxFileSize < 0 {
+		v.fail(field(path, "maxFileSize"), ValidationCodeOutOfRange, "invalid maxFileSize setting: %d", s.MaxFileSize)
 	}
-	return nil
 }
 
 // SubsystemConfig controls shell executions via SSH.
 type SubsystemConfig struct {
 	// Mode configures how to treat subsystem requests by SSH clients.
 	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// MatchMode configures how the Allow and Deny entries are matched. In exact mode entries may use namespace
+	// wildcards and versions as described below. Entries may select a different match mode with a prefix, see
+	// MatchMode.
+	MatchMode MatchMode `json:"matchMode" yaml:"matchMode" default:"exact"`
 	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows the specified subsystems to be
-	// executed.
+	// executed. Entries may use namespace wildcards (internal/*) and versions (sftp@3 or sftp@3+ for version 3 and
+	// newer).
 	Allow []string
+	// AllowFiles adds the entries of CSV or TSV files to Allow when the handler is created.
+	AllowFiles []ListFile `json:"allowFiles" yaml:"allowFiles"`
 	// Allow takes effect when Mode is not ExecutionPolicyDisable and disallows the specified subsystems to be executed.
+	// The same patterns as for Allow are supported.
 	Deny []string
+	// ForceCommand replaces the program of subsystem requests, taking precedence over the global ForceCommand. The
+	// same template values are available.
+	ForceCommand string `json:"forceCommand" yaml:"forceCommand"`
+	// ForceCommandArgs replaces the program of subsystem requests like the global ForceCommandArgs, taking precedence
+	// over the global ForceCommand and ForceCommandArgs.
+	ForceCommandArgs []string `json:"forceCommandArgs" yaml:"forceCommandArgs"`
 }
 
 // Validate validates a subsystem configuration
 func (s SubsystemConfig) Validate() error {
-	if err := s.Mode.Validate(); err != nil {
-		return fmt.Errorf("invalid mode (%w)", err)
+	v := &validator{}
+	s.validate(v, "")
+	return v.result()
+}
+
+func (s SubsystemConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), s.Mode, true)
+	v.check(field(path, "matchMode"), ValidationCodeInvalidMode, s.MatchMode.Validate())
+	validateListFiles(v, field(path, "allowFiles"), s.AllowFiles)
+	validateForceCommand(v, path, s.ForceCommand, s.ForceCommandArgs)
+	validatePatterns(v, field(path, "allow"), s.MatchMode, s.Allow)
+	validatePatterns(v, field(path, "deny"), s.MatchMode, s.Deny)
+	if s.MatchMode != "" && s.MatchMode != MatchModeExact {
+		return
+	}
+	for i, pattern := range s.Allow {
+		if _, _, ok := parseEntry(s.MatchMode, pattern); !ok {
+			_, err := parseSubsystemPattern(pattern)
+			v.check(index(field(path, "allow"), i), ValidationCodeInvalidPattern, err)
+		}
+	}
+	for i, pattern := range s.Deny {
+		if _, _, ok := parseEntry(s.MatchMode, pattern); !ok {
+			_, err := parseSubsystemPattern(pattern)
+			v.check(index(field(path, "deny"), i), ValidationCodeInvalidPattern, err)
+		}
 	}
-	return nil
 }
 
 // TTYConfig controls how to treat TTY/PTY requests by clients.
 type TTYConfig struct {
 	// Mode configures how to treat TTY/PTY requests by SSH clients.
 	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// MatchMode configures how the Allow and Deny entries are matched. Entries may select a different match mode with
+	// a prefix, see MatchMode.
+	MatchMode MatchMode `json:"matchMode" yaml:"matchMode" default:"exact"`
+	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows the specified terminal types (TERM), e.g.
+	// glob:xterm*, glob:screen* and glob:tmux*.
+	Allow []string `json:"allow" yaml:"allow"`
+	// Deny takes effect when Mode is ExecutionPolicyEnable or ExecutionPolicyAudit and rejects the specified terminal
+	// types.
+	Deny []string `json:"deny" yaml:"deny"`
+	// MaxTermLength rejects terminal types longer than the specified number of bytes. Oversized terminal types have
+	// been used to exploit terminfo parsers. 0 means unlimited.
+	MaxTermLength int `json:"maxTermLength" yaml:"maxTermLength" default:"0"`
 }
 
 // Validate validates the TTY configuration
 func (t TTYConfig) Validate() error {
-	if err := t.Mode.Validate(); err != nil {
-		return fmt.Errorf("invalid mode (%w)", err)
+	v := &validator{}
+	t.validate(v, "")
+	return v.result()
+}
+
+func (t TTYConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), t.Mode, false)
+	v.check(field(path, "matchMode"), ValidationCodeInvalidMode, t.MatchMode.Validate())
+	validatePatterns(v, field(path, "allow"), t.MatchMode, t.Allow)
+	validatePatterns(v, field(path, "deny"), t.MatchMode, t.Deny)
+	if t.MaxTermLength < 0 {
+		v.fail(field(path, "maxTermLength"), ValidationCodeOutOfRange, "invalid maxTermLength: %d", t.MaxTermLength)
 	}
-	return nil
 }
 
 // SignalConfig configures how signal forwarding requests are treated.
 type SignalConfig struct {
 	// Mode configures how to treat signal requests to running programs
 	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// MatchMode configures how the Allow and Deny entries are matched. Entries may select a different match mode with
+	// a prefix, see MatchMode.
+	MatchMode MatchMode `json:"matchMode" yaml:"matchMode" default:"exact"`
 	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows the specified signals to be forwarded.
+	// Signals sent by the client are normalized before they are matched: the SIG prefix is removed, the name is
+	// converted to upper case and signal numbers common to all POSIX systems are translated to names, e.g. SIGTERM,
+	// term and 15 all match TERM. Entries matched exactly are normalized the same way, glob and regex patterns must
+	// match the normalized names, e.g. USR*. The preset @safe expands to HUP, INT and TERM.
 	Allow []string
 	// Allow takes effect when Mode is not ExecutionPolicyDisable and disallows the specified signals to be forwarded.
+	// The signals are normalized and presets expanded as for Allow.
 	Deny []string
+	// Strict rejects signals that are not defined in RFC 4254 after normalization, e.g. WINCH, regardless of Mode.
+	Strict bool `json:"strict" yaml:"strict"`
+	// Translate maps signals sent by the client to the signal delivered to the program, e.g. TERM to INT. The
+	// translation is applied after the allow and deny lists have been checked against the normalized signal. The keys
+	// are normalized like the entries of Allow, the delivered signals must be names without the SIG prefix.
+	Translate map[string]string `json:"translate" yaml:"translate"`
+	// EscalateAfter sends KILL to the program if it is still running this long after a HUP, INT, QUIT or TERM signal
+	// was delivered. 0 disables the escalation. In ExecutionPolicyAudit mode the escalation is reported to the backend.
+	EscalateAfter time.Duration `json:"escalateAfter" yaml:"escalateAfter" default:"0s"`
 }
 
 // Validate validates the signal configuration
 func (s SignalConfig) Validate() error {
-	if err := s.Mode.Validate(); err != nil {
-		return fmt.Errorf("invalid mode (%w)", err)
+	v := &validator{}
+	s.validate(v, "")
+	return v.result()
+}
+
+func (s SignalConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), s.Mode, false)
+	v.check(field(path, "matchMode"), ValidationCodeInvalidMode, s.MatchMode.Validate())
+	validatePatterns(v, field(path, "allow"), s.MatchMode, s.Allow)
+	validatePatterns(v, field(path, "deny"), s.MatchMode, s.Deny)
+	validateSignalPresets(v, field(path, "allow"), s.Allow)
+	validateSignalPresets(v, field(path, "deny"), s.Deny)
+	if s.EscalateAfter < 0 {
+		v.fail(field(path, "escalateAfter"), ValidationCodeOutOfRange, "invalid escalateAfter setting: %s", s.EscalateAfter)
+	}
+	translated := map[string]string{}
+	for from, to := range s.Translate {
+		path := key(field(path, "translate"), from)
+		v.check(path, ValidationCodeInvalidFormat, validateSignal(to))
+		if other, ok := translated[normalizeSignal(from)]; ok {
+			v.fail(path, ValidationCodeInvalidFormat, "%s is the same signal as %s", from, other)
+		}
+		translated[normalizeSignal(from)] = from
+	}
+}
+
+// X11Config configures the policy for X11 forwarding requests.
+type X11Config struct {
+	// Mode configures how to treat X11 forwarding requests. ExecutionPolicyPrompt is not supported.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows the specified X11 authentication
+	// protocols, e.g. MIT-MAGIC-COOKIE-1.
+	Allow []string `json:"allow" yaml:"allow"`
+	// SingleConnection rejects requests that don't ask for only a single X11 connection to be forwarded.
+	SingleConnection bool `json:"singleConnection" yaml:"singleConnection"`
+	// Trust configures whether forwarded X11 clients are trusted. Since the client's choice between trusted and
+	// untrusted forwarding is not visible to the server, the setting is handed to session backends implementing
+	// X11PolicyHandler to apply when setting up the forwarding. If configured and the backend doesn't implement
+	// X11PolicyHandler X11 forwarding is rejected.
+	Trust X11Trust `json:"trust" yaml:"trust" default:""`
+}
+
+// Validate validates the X11 configuration.
+func (x X11Config) Validate() error {
+	v := &validator{}
+	x.validate(v, "")
+	return v.result()
+}
+
+func (x X11Config) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), x.Mode, false)
+	v.check(field(path, "trust"), ValidationCodeInvalidMode, x.Trust.Validate())
+}
+
+// BreakConfig configures the policy for break requests.
+type BreakConfig struct {
+	// Mode configures how to treat break requests. ExecutionPolicyPrompt is not supported.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// MaxLength rejects requests for breaks longer than the specified number of milliseconds. 0 means unlimited.
+	MaxLength uint32 `json:"maxLength" yaml:"maxLength" default:"0"`
+}
+
+// Validate validates the break configuration.
+func (b BreakConfig) Validate() error {
+	v := &validator{}
+	b.validate(v, "")
+	return v.result()
+}
+
+func (b BreakConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), b.Mode, false)
+}
+
+// SummaryConfig configures the security summary displayed to interactive users.
+type SummaryConfig struct {
+	// Enable displays the summary when a shell is started with a TTY.
+	Enable bool `json:"enable" yaml:"enable"`
+	// Notice is an additional text displayed below the summary, e.g. a session recording or legal notice.
+	Notice string `json:"notice" yaml:"notice"`
+	// Contact is displayed as the contact for questions about the policy.
+	Contact string `json:"contact" yaml:"contact"`
+}
+
+// SamplingConfig configures sampling of allowed requests into the audit stream. Sampled requests are passed to
+// backends implementing AuditHandler with AuditedRequest.Sampled set. Unlike audited requests they are reported on a
+// best effort basis: backends not implementing AuditHandler are skipped and errors don't reject the request.
+type SamplingConfig struct {
+	// Rate is the share of allowed requests to report, between 0 and 1. For example, 0.01 samples 1% of requests.
+	// 0 disables sampling.
+	Rate float64 `json:"rate" yaml:"rate" default:"0"`
+	// Rates overrides Rate for specific request types, e.g. exec, env, subsystem, direct-tcpip or tcpip-forward.
+	Rates map[string]float64 `json:"rates" yaml:"rates"`
+	// BytesPerMinute limits the payload size of the sampled requests reported per minute across all connections.
+	// Requests selected for sampling while the budget is exhausted are not reported. 0 means unlimited.
+	BytesPerMinute int `json:"bytesPerMinute" yaml:"bytesPerMinute" default:"0"`
+}
+
+// Validate validates the sampling configuration.
+func (s SamplingConfig) Validate() error {
+	v := &validator{}
+	s.validate(v, "")
+	return v.result()
+}
+
+func (s SamplingConfig) validate(v *validator, path string) {
+	if s.Rate < 0 || s.Rate > 1 {
+		v.fail(field(path, "rate"), ValidationCodeOutOfRange, "the sampling rate must be between 0 and 1: %v", s.Rate)
+	}
+	for requestType, rate := range s.Rates {
+		if rate < 0 || rate > 1 {
+			v.fail(
+				key(field(path, "rates"), requestType),
+				ValidationCodeOutOfRange,
+				"the sampling rate must be between 0 and 1: %v",
+				rate,
+			)
+		}
+	}
+	if s.BytesPerMinute < 0 {
+		v.fail(
+			field(path, "bytesPerMinute"),
+			ValidationCodeOutOfRange,
+			"invalid bytesPerMinute setting: %d",
+			s.BytesPerMinute,
+		)
+	}
+}
+
+// QuarantineConfig configures the probation period for users and public keys seen for the first time. A connection is
+// in quarantine if the user or any of the public keys accepted during authentication is new, since the SSH server does
+// not report which of them the client finally signed with.
+//
+// The first-seen times are recorded in the StateStore passed to NewHandlerWithStateStore. With the in-memory store used
+// by NewHandler they are lost on restart, so all users and keys are considered new again. If the store fails the user
+// or key is treated as new. Handlers created by New have no store and don't apply the quarantine.
+type QuarantineConfig struct {
+	// Period is the probation period after a user or public key was first seen. 0 disables the quarantine.
+	Period time.Duration `json:"period" yaml:"period" default:"0s"`
+	// Policy is the policy applied instead of the normal policy during the probation period. If not set all requests
+	// are rejected.
+	Policy *Config `json:"policy" yaml:"policy"`
+}
+
+// Validate validates the quarantine configuration.
+func (q QuarantineConfig) Validate() error {
+	v := &validator{}
+	q.validate(v, "")
+	return v.result()
+}
+
+func (q QuarantineConfig) validate(v *validator, path string) {
+	if q.Period < 0 {
+		v.fail(field(path, "period"), ValidationCodeOutOfRange, "invalid period setting: %s", q.Period)
+	}
+	if q.Policy != nil {
+		q.Policy.validate(v, field(path, "policy"))
+	}
+}
+
+// RecertificationConfig configures the dates by which access must be recertified.
+type RecertificationConfig struct {
+	// Users maps usernames to the date (YYYY-MM-DD) until which their access is certified. Users not listed are not
+	// subject to recertification.
+	Users map[string]string `json:"users" yaml:"users"`
+	// Action configures what happens to users whose recertification date has passed.
+	Action RecertificationAction `json:"action" yaml:"action" default:"deny"`
+}
+
+// Validate validates the recertification configuration.
+func (r RecertificationConfig) Validate() error {
+	v := &validator{}
+	r.validate(v, "")
+	return v.result()
+}
+
+func (r RecertificationConfig) validate(v *validator, path string) {
+	for username, date := range r.Users {
+		if _, err := time.Parse(recertificationDateFormat, date); err != nil {
+			v.fail(key(field(path, "users"), username), ValidationCodeInvalidFormat, "invalid date: %s", date)
+		}
+	}
+	v.check(field(path, "action"), ValidationCodeInvalidMode, r.Action.Validate())
+}
+
+// RecertificationAction drives how to treat users whose access hasn't been recertified in time.
+type RecertificationAction string
+
+const (
+	// RecertificationActionDeny rejects the connection after the handshake. This is the default.
+	RecertificationActionDeny RecertificationAction = "deny"
+
+	// RecertificationActionRestrict only allows session channels and the SFTP subsystem and denies all other requests.
+	RecertificationActionRestrict RecertificationAction = "restrict"
+
+	// RecertificationActionWarn allows the connection with the normal policy, but writes a warning to the standard
+	// error of every program started.
+	RecertificationActionWarn RecertificationAction = "warn"
+)
+
+// Validate validates the recertification action.
+func (r RecertificationAction) Validate() error {
+	switch r {
+	case "":
+	case RecertificationActionDeny:
+	case RecertificationActionRestrict:
+	case RecertificationActionWarn:
+	default:
+		return fmt.Errorf("invalid action: %s", r)
 	}
 	return nil
 }
 
+// DenyDelayConfig configures how long rejected requests take to respond.
+type DenyDelayConfig struct {
+	// Min is the minimum time a rejection takes, measured from receiving the request. This makes all rejections take
+	// a constant time regardless of which check rejected the request.
+	Min time.Duration `json:"min" yaml:"min" default:"0s"`
+	// Jitter adds a random delay between 0 and Jitter to each rejection.
+	Jitter time.Duration `json:"jitter" yaml:"jitter" default:"0s"`
+}
+
+// Validate validates the deny delay configuration.
+func (d DenyDelayConfig) Validate() error {
+	v := &validator{}
+	d.validate(v, "")
+	return v.result()
+}
+
+func (d DenyDelayConfig) validate(v *validator, path string) {
+	if d.Min < 0 {
+		v.fail(field(path, "min"), ValidationCodeOutOfRange, "invalid min setting: %s", d.Min)
+	}
+	if d.Jitter < 0 {
+		v.fail(field(path, "jitter"), ValidationCodeOutOfRange, "invalid jitter setting: %s", d.Jitter)
+	}
+}
+
+// RateLimitConfig configures how many new connections are permitted in a given time frame.
+type RateLimitConfig struct {
+	// PerIP is the number of new connections per minute permitted from a single IP address, aggregated according to
+	// IPv4Aggregation and IPv6Aggregation. 0 means unlimited.
+	PerIP int `json:"perIP" yaml:"perIP" default:"0"`
+	// PerUser is the number of new connections per minute permitted for a single username. 0 means unlimited.
+	PerUser int `json:"perUser" yaml:"perUser" default:"0"`
+	// Burst is the number of connections that can be opened in quick succession before the rate applies.
+	Burst int `json:"burst" yaml:"burst" default:"1"`
+	// Penalty is the time a client is blocked after exceeding the rate. The penalty doubles with each repeated
+	// violation until the client stays within the limits again.
+	Penalty time.Duration `json:"penalty" yaml:"penalty" default:"0s"`
+	// MaxPenalty caps the penalty for repeat offenders. 0 means no cap.
+	MaxPenalty time.Duration `json:"maxPenalty" yaml:"maxPenalty" default:"0s"`
+	// Throttle delays connections exceeding the rate instead of rejecting them.
+	Throttle ThrottleConfig `json:"throttle" yaml:"throttle"`
+}
+
+// ThrottleConfig configures delaying requests exceeding a rate limit until the rate permits them instead of rejecting
+// them. Requests that would have to wait longer than MaxDelay, or find the queue full, are rejected as usual.
+type ThrottleConfig struct {
+	// MaxDelay is the longest time a request is delayed. 0 disables throttling.
+	MaxDelay time.Duration `json:"maxDelay" yaml:"maxDelay" default:"0s"`
+	// MaxQueue is the number of requests that can wait at the same time for each client. 0 means the queue is only
+	// limited by MaxDelay.
+	MaxQueue int `json:"maxQueue" yaml:"maxQueue" default:"0"`
+}
+
+// Validate validates the rate limit configuration.
+func (r RateLimitConfig) Validate() error {
+	v := &validator{}
+	r.validate(v, "")
+	return v.result()
+}
+
+func (r RateLimitConfig) validate(v *validator, path string) {
+	if r.PerIP < 0 {
+		v.fail(field(path, "perIP"), ValidationCodeOutOfRange, "invalid perIP setting: %d", r.PerIP)
+	}
+	if r.PerUser < 0 {
+		v.fail(field(path, "perUser"), ValidationCodeOutOfRange, "invalid perUser setting: %d", r.PerUser)
+	}
+	if r.Burst < 0 {
+		v.fail(field(path, "burst"), ValidationCodeOutOfRange, "invalid burst setting: %d", r.Burst)
+	}
+	if r.Penalty < 0 {
+		v.fail(field(path, "penalty"), ValidationCodeOutOfRange, "invalid penalty setting: %s", r.Penalty)
+	}
+	if r.MaxPenalty < 0 {
+		v.fail(field(path, "maxPenalty"), ValidationCodeOutOfRange, "invalid maxPenalty setting: %s", r.MaxPenalty)
+	}
+	r.Throttle.validate(v, field(path, "throttle"))
+}
+
+// Validate validates the throttle configuration.
+func (t ThrottleConfig) Validate() error {
+	v := &validator{}
+	t.validate(v, "")
+	return v.result()
+}
+
+func (t ThrottleConfig) validate(v *validator, path string) {
+	if t.MaxDelay < 0 {
+		v.fail(field(path, "maxDelay"), ValidationCodeOutOfRange, "invalid maxDelay setting: %s", t.MaxDelay)
+	}
+	if t.MaxQueue < 0 {
+		v.fail(field(path, "maxQueue"), ValidationCodeOutOfRange, "invalid maxQueue setting: %d", t.MaxQueue)
+	}
+}
+
+// ChannelConfig configures how to treat a channel type.
+type ChannelConfig struct {
+	// Mode configures if channels of this type may be opened. ExecutionPolicyFilter and ExecutionPolicyDisable reject
+	// all channels of this type.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// Max is the number of channels of this type that may be opened within a single connection. 0 means unlimited.
+	Max int `json:"max" yaml:"max" default:"0"`
+	// PerMinute is the number of channels of this type per minute that may be opened within a single connection. 0
+	// means unlimited.
+	PerMinute int `json:"perMinute" yaml:"perMinute" default:"0"`
+	// Burst is the number of channels of this type that can be opened in quick succession before PerMinute applies.
+	Burst int `json:"burst" yaml:"burst" default:"1"`
+	// Throttle delays channels exceeding PerMinute instead of rejecting them.
+	Throttle ThrottleConfig `json:"throttle" yaml:"throttle"`
+}
+
+// RequestConfig configures how to treat a request type not covered by any other section.
+type RequestConfig struct {
+	// Mode configures how to treat requests of this type.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows requests whose payload starts with one of
+	// the specified hex-encoded prefixes.
+	Allow []string `json:"allow" yaml:"allow"`
+	// Deny takes effect when Mode is not ExecutionPolicyDisable and disallows requests whose payload starts with one of
+	// the specified hex-encoded prefixes.
+	Deny []string `json:"deny" yaml:"deny"`
+}
+
+// PromptConfig configures the confirmation requested from the user in ExecutionPolicyPrompt mode.
+type PromptConfig struct {
+	// Message is the banner displayed to the user. It should explain what is being confirmed and which phrase to type.
+	Message string `json:"message" yaml:"message" default:"This action requires confirmation. Type \"yes\" to continue: "`
+	// Phrase is the text the user has to type to confirm the request.
+	Phrase string `json:"phrase" yaml:"phrase" default:"yes"`
+	// Timeout is the time the user has to confirm the request. The request is denied and the session channel is
+	// closed if it expires. 0 uses the default of one minute.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" default:"1m"`
+}
+
+// Validate validates the prompt configuration.
+func (p PromptConfig) Validate() error {
+	v := &validator{}
+	p.validate(v, "")
+	return v.result()
+}
+
+func (p PromptConfig) validate(v *validator, path string) {
+	if strings.ContainsAny(p.Phrase, "\r\n") {
+		v.fail(field(path, "phrase"), ValidationCodeInvalidFormat, "the phrase must not contain line breaks")
+	}
+	if p.Timeout < 0 {
+		v.fail(field(path, "timeout"), ValidationCodeOutOfRange, "invalid timeout setting: %s", p.Timeout)
+	}
+}
+
+// EgressConfig declares the egress policy for programs started in a session. This library does not enforce the
+// egress policy itself, it is handed to session backends implementing EgressPolicyHandler, for example to configure a
+// host firewall or an eBPF enforcer. If a policy is configured and the backend doesn't implement EgressPolicyHandler
+// no programs are started.
+type EgressConfig struct {
+	// Mode configures the egress policy. Unlike other sections this does not fall back to DefaultMode, leaving it
+	// unconfigured means no egress policy is communicated to the backend.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows connecting to the specified
+	// destinations. Destinations are specified as host, host:port or *.domain:port.
+	Allow []string `json:"allow" yaml:"allow"`
+	// Deny takes effect when Mode is ExecutionPolicyEnable and disallows connecting to the specified destinations.
+	Deny []string `json:"deny" yaml:"deny"`
+}
+
+// Validate validates the egress configuration.
+func (e EgressConfig) Validate() error {
+	v := &validator{}
+	e.validate(v, "")
+	return v.result()
+}
+
+func (e EgressConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), e.Mode, false)
+	for i, destination := range e.Allow {
+		v.check(index(field(path, "allow"), i), ValidationCodeInvalidPattern, validateEgressDestination(destination))
+	}
+	for i, destination := range e.Deny {
+		v.check(index(field(path, "deny"), i), ValidationCodeInvalidPattern, validateEgressDestination(destination))
+	}
+}
+
+// ForwardingConfig configures the policy for local port forwarding (direct-tcpip channels). The channel policy for
+// direct-tcpip in Channels is applied first.
+type ForwardingConfig struct {
+	// Mode configures how to treat port forwarding requests. ExecutionPolicyPrompt is not supported.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows forwarding to the specified destinations.
+	// Destinations are specified as host:ports, where host is an IP address, a CIDR range, a hostname or *, and ports
+	// is a single port or a range such as 8000-9000. The port may be omitted to match all ports, for example
+	// 10.0.0.0/8:5432, [fd00::/8]:22 or db.internal. Hostnames are compared as sent by the client and are not
+	// resolved.
+	Allow []string `json:"allow" yaml:"allow"`
+	// Deny takes effect when Mode is not ExecutionPolicyDisable and disallows forwarding to the specified
+	// destinations. The same format as for Allow is supported.
+	Deny []string `json:"deny" yaml:"deny"`
+}
+
+// Validate validates the forwarding configuration.
+func (f ForwardingConfig) Validate() error {
+	v := &validator{}
+	f.validate(v, "")
+	return v.result()
+}
+
+func (f ForwardingConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), f.Mode, false)
+	for i, destination := range f.Allow {
+		_, err := parseAddressRule(destination)
+		v.check(index(field(path, "allow"), i), ValidationCodeInvalidPattern, err)
+	}
+	for i, destination := range f.Deny {
+		_, err := parseAddressRule(destination)
+		v.check(index(field(path, "deny"), i), ValidationCodeInvalidPattern, err)
+	}
+}
+
+// ReverseForwardingConfig configures the policy for remote port forwarding (tcpip-forward requests). The global
+// request policy for tcpip-forward in GlobalRequests is applied first.
+type ReverseForwardingConfig struct {
+	// Mode configures how to treat remote port forwarding requests. ExecutionPolicyPrompt is not supported.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows binding the specified addresses and
+	// ports. The same format as for Forwarding.Allow is supported, for example 127.0.0.1:8000-9000 or
+	// localhost:8080. Port 0 requests a port chosen by the server and must be included in the port range to be
+	// allowed. Binding all interfaces (an empty address) is only matched by *.
+	Allow []string `json:"allow" yaml:"allow"`
+	// Deny takes effect when Mode is not ExecutionPolicyDisable and disallows binding the specified addresses and
+	// ports.
+	Deny []string `json:"deny" yaml:"deny"`
+	// ForbidWildcard rejects binding all interfaces (an empty address, *, 0.0.0.0 or ::) regardless of Mode.
+	ForbidWildcard bool `json:"forbidWildcard" yaml:"forbidWildcard"`
+}
+
+// Validate validates the reverse forwarding configuration.
+func (r ReverseForwardingConfig) Validate() error {
+	v := &validator{}
+	r.validate(v, "")
+	return v.result()
+}
+
+func (r ReverseForwardingConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), r.Mode, false)
+	for i, address := range r.Allow {
+		_, err := parseAddressRule(address)
+		v.check(index(field(path, "allow"), i), ValidationCodeInvalidPattern, err)
+	}
+	for i, address := range r.Deny {
+		_, err := parseAddressRule(address)
+		v.check(index(field(path, "deny"), i), ValidationCodeInvalidPattern, err)
+	}
+}
+
+// StreamLocalConfig configures the policy for Unix domain socket forwarding. The policies for the channel and request
+// types in Channels and GlobalRequests are applied first.
+type StreamLocalConfig struct {
+	// Mode configures how to treat Unix domain socket forwarding. ExecutionPolicyPrompt is not supported.
+	Mode ExecutionPolicy `json:"mode" yaml:"mode" default:""`
+	// MatchMode configures how the Allow and Deny entries are matched. Entries may select a different match mode with
+	// a prefix, see MatchMode.
+	MatchMode MatchMode `json:"matchMode" yaml:"matchMode" default:"exact"`
+	// Allow takes effect when Mode is ExecutionPolicyFilter and only allows the specified socket paths. Paths are
+	// cleaned before matching, but symbolic links are not resolved. For example, /var/run/docker.sock and
+	// /run/docker.sock have to be listed separately.
+	Allow []string `json:"allow" yaml:"allow"`
+	// Deny takes effect when Mode is not ExecutionPolicyDisable and disallows the specified socket paths.
+	Deny []string `json:"deny" yaml:"deny"`
+}
+
+// Validate validates the Unix domain socket forwarding configuration.
+func (s StreamLocalConfig) Validate() error {
+	v := &validator{}
+	s.validate(v, "")
+	return v.result()
+}
+
+func (s StreamLocalConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), s.Mode, false)
+	v.check(field(path, "matchMode"), ValidationCodeInvalidMode, s.MatchMode.Validate())
+	validatePatterns(v, field(path, "allow"), s.MatchMode, s.Allow)
+	validatePatterns(v, field(path, "deny"), s.MatchMode, s.Deny)
+}
+
 // ExecutionPolicy drives how to treat a certain request.
 type ExecutionPolicy string
 
@@ -180,6 +1339,17 @@ const (
 	// "deny" list.
 	ExecutionPolicyEnable ExecutionPolicy = "enable"
 
+	// ExecutionPolicyAudit allows the execution of the specified method like ExecutionPolicyEnable, but additionally
+	// reports every permitted request to the backend in full detail. The backend must implement AuditHandler,
+	// otherwise the requests are rejected. Requests that would be denied by ExecutionPolicyFilter are flagged in the
+	// Decision, so allow lists can be tried in report-only mode before switching to ExecutionPolicyFilter.
+	ExecutionPolicyAudit ExecutionPolicy = "audit"
+
+	// ExecutionPolicyPrompt allows the execution of the specified method like ExecutionPolicyEnable, but the user has to
+	// confirm the request by typing the configured phrase first. This is only supported for shell, command and
+	// subsystem requests in interactive sessions (with a TTY).
+	ExecutionPolicyPrompt ExecutionPolicy = "prompt"
+
 	// ExecutionPolicyFilter filters the execution against a specified allow list. If the allow list is empty or not
 	// supported this ootion behaves like "disable".
 	ExecutionPolicyFilter ExecutionPolicy = "filter"
@@ -193,6 +1363,8 @@ func (e ExecutionPolicy) Validate() error {
 	switch e {
 	case ExecutionPolicyUnconfigured:
 	case ExecutionPolicyEnable:
+	case ExecutionPolicyAudit:
+	case ExecutionPolicyPrompt:
 	case ExecutionPolicyFilter:
 	case ExecutionPolicyDisable:
 	default:
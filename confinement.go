@@ -0,0 +1,128 @@
+package security
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+)
+
+const defaultHomeDirectory = "/home/{{ .Username }}"
+
+// homeDirectoryData is the data structure passed to the HomeDirectory template.
+type homeDirectoryData struct {
+	Username string
+}
+
+func parseHomeDirectory(homeDirectory string) (*template.Template, error) {
+	if homeDirectory == "" {
+		homeDirectory = defaultHomeDirectory
+	}
+	return template.New("homeDirectory").Option("missingkey=error").Parse(homeDirectory)
+}
+
+// resolveHomeDirectory returns the home directory of the specified user based on the HomeDirectory template.
+func resolveHomeDirectory(homeDirectory string, username string) (string, error) {
+	if username == "" || username == "." || username == ".." || strings.ContainsAny(username, "/\x00") {
+		return "", fmt.Errorf("cannot determine home directory for username %q", username)
+	}
+	tpl, err := parseHomeDirectory(homeDirectory)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, homeDirectoryData{Username: username}); err != nil {
+		return "", err
+	}
+	home := buf.String()
+	if !path.IsAbs(home) {
+		return "", fmt.Errorf("home directory is not absolute: %s", home)
+	}
+	return path.Clean(home), nil
+}
+
+// ConfinePath resolves p relative to root and returns the resulting absolute path. Paths starting with ~ are
+// resolved relative to root. An error is returned if the resolved path is outside of root.
+//
+// The resolution is purely lexical: symlinks are not followed since the target filesystem is usually not available
+// to this library.
+func ConfinePath(root string, p string) (string, error) {
+	if !path.IsAbs(root) {
+		return "", fmt.Errorf("root directory is not absolute: %s", root)
+	}
+	root = path.Clean(root)
+	switch {
+	case p == "~" || strings.HasPrefix(p, "~/"):
+		p = path.Join(root, p[1:])
+	case strings.HasPrefix(p, "~"):
+		return "", fmt.Errorf("path %s references another user's home directory", p)
+	case !path.IsAbs(p):
+		p = path.Join(root, p)
+	default:
+		p = path.Clean(p)
+	}
+	if root != "/" && p != root && !strings.HasPrefix(p, root+"/") {
+		return "", fmt.Errorf("path %s is outside of %s", p, root)
+	}
+	return p, nil
+}
+
+// pathArguments returns the arguments of a command line that may reference a path. The program itself is not
+// included. Values in the form of option=value and values attached to short options, such as -f/path, are also
+// considered. An error is returned if the command can't be split into words unambiguously.
+func pathArguments(program string) ([]string, error) {
+	words, err := splitShellWords(program)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+	var result []string
+	for _, arg := range words[1:] {
+		candidates := []string{arg}
+		if i := strings.Index(arg, "="); i >= 0 {
+			candidates = append(candidates, arg[i+1:])
+		}
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' {
+			// The value of a short option may be attached to it or to a bundle of other options.
+			candidates = append(candidates, arg[2:])
+			if i := strings.IndexAny(arg, "/~"); i >= 0 {
+				candidates = append(candidates, arg[i:])
+			}
+		}
+		for _, candidate := range candidates {
+			if isPathLike(candidate) {
+				result = append(result, candidate)
+			}
+		}
+	}
+	return result, nil
+}
+
+func isPathLike(arg string) bool {
+	if strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, "~") {
+		return true
+	}
+	for _, element := range strings.Split(arg, "/") {
+		if element == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConfinement verifies that all path-like arguments of program are within root.
+func checkConfinement(root string, program string) error {
+	args, err := pathArguments(program)
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if _, err := ConfinePath(root, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfinePath(t *testing.T) {
+	for p, expected := range map[string]string{
+		"":                  "/home/foo",
+		"~":                 "/home/foo",
+		"~/.ssh":            "/home/foo/.ssh",
+		"data/file.txt":     "/home/foo/data/file.txt",
+		"data/../other":     "/home/foo/other",
+		"/home/foo/file":    "/home/foo/file",
+		"/home/foo/../foo/": "/home/foo",
+	} {
+		resolved, err := ConfinePath("/home/foo", p)
+		assert.NoError(t, err, p)
+		assert.Equal(t, expected, resolved, p)
+	}
+	for _, p := range []string{
+		"/etc/passwd",
+		"..",
+		"../bar",
+		"data/../../bar",
+		"/home/foobar",
+		"~bar/file",
+	} {
+		_, err := ConfinePath("/home/foo", p)
+		assert.Error(t, err, p)
+	}
+	_, err := ConfinePath("home/foo", "file")
+	assert.Error(t, err)
+}
+
+func TestConfineToHome(t *testing.T) {
+	session := &sessionHandler{
+		config: Config{
+			ConfineToHome: true,
+		},
+		backend: &dummyBackend{},
+		sshConnection: &sshConnectionHandler{
+			username: "foo",
+			lock:     &sync.Mutex{},
+		},
+	}
+
+	assert.NoError(t, session.OnExecRequest(1, "/usr/bin/scp -t data/"))
+	assert.NoError(t, session.OnExecRequest(2, "/usr/bin/scp -t /home/foo/data"))
+	assert.NoError(t, session.OnExecRequest(3, "ls -la .ssh 'my files'"))
+	assert.Error(t, session.OnExecRequest(4, "/usr/bin/scp -f /etc/passwd"))
+	assert.Error(t, session.OnExecRequest(5, "cat ../bar/.ssh/id_rsa"))
+	assert.Error(t, session.OnExecRequest(6, "tar --file=/tmp/out.tar -c ."))
+	for _, command := range []string{
+		"ls -la ~/.ssh",
+		"cat '/etc/passwd'",
+		"cat \\/etc/passwd",
+		"cat $HOME/../bar/file",
+		"tar -cf/tmp/out.tar .",
+		"tar -cvf../out.tar .",
+		"dd if=/etc/shadow",
+		"cat data; cat /etc/passwd",
+	} {
+		assert.Error(t, session.OnExecRequest(10, command), command)
+	}
+
+	session.config.HomeDirectory = "/srv/{{ .Username }}/files"
+	assert.NoError(t, session.OnExecRequest(7, "cat /srv/foo/files/readme"))
+	assert.Error(t, session.OnExecRequest(8, "cat /home/foo/readme"))
+
+	session.sshConnection.username = "../root"
+	assert.Error(t, session.OnExecRequest(9, "ls"))
+}
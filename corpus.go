@@ -0,0 +1,172 @@
+package security
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// CorpusRecorder records the shapes of evaluated requests, so a regression corpus for evaluator changes can be built
+// from production traffic without storing its content. Payload values are anonymized character by character:
+// lowercase letters become a, uppercase letters A, digits 0, other letters x, while whitespace, punctuation and the
+// length are kept. For example, the command "git clone repo-1.git" is recorded as "aaa aaaaa aaaa-0.aaa". Usernames
+// and command hashes are not recorded. Each distinct shape is recorded once, as long as it is among the most
+// recently seen corpusMaxSeen shapes, which bounds the memory used for long-running recorders.
+type CorpusRecorder struct {
+	lock   *sync.Mutex
+	writer io.Writer
+	keep   map[string]bool
+	// seen and order contain the recently recorded shapes, order from the most to the least recently seen.
+	seen    map[string]*list.Element
+	order   *list.List
+	maxSeen int
+	err     error
+}
+
+// corpusMaxSeen is the number of recently recorded shapes a CorpusRecorder remembers to avoid duplicates.
+const corpusMaxSeen = 10000
+
+// NewCorpusRecorder creates a recorder writing the anonymized requests to writer as JSON lines. The payload fields
+// listed in keep, e.g. signal or subsystem, are recorded verbatim.
+//goland:noinspection GoUnusedExportedFunction
+func NewCorpusRecorder(writer io.Writer, keep ...string) *CorpusRecorder {
+	keepFields := map[string]bool{}
+	for _, name := range keep {
+		keepFields[name] = true
+	}
+	return &CorpusRecorder{
+		lock:    &sync.Mutex{},
+		writer:  writer,
+		keep:    keepFields,
+		seen:    map[string]*list.Element{},
+		order:   list.New(),
+		maxSeen: corpusMaxSeen,
+	}
+}
+
+// Register registers the recorder with the decision hooks of a handler or evaluator.
+func (c *CorpusRecorder) Register(hooks DecisionHooks) {
+	hooks.OnAfterDecision(func(request DecisionRequest, _ Decision) {
+		c.record(request)
+	})
+}
+
+// Err returns the first error encountered while writing the corpus.
+func (c *CorpusRecorder) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+func (c *CorpusRecorder) record(request DecisionRequest) {
+	anonymized := DecisionRequest{RequestType: request.RequestType, Payload: map[string]string{}}
+	for name, value := range request.Payload {
+		if name == "commandHash" {
+			// The hash is derived from the content and recomputed by Evaluate.
+			continue
+		}
+		if c.keep[name] {
+			anonymized.Payload[name] = value
+		} else {
+			anonymized.Payload[name] = anonymize(value)
+		}
+	}
+	line, err := json.Marshal(anonymized)
+	if err != nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return
+	}
+	if element, ok := c.seen[string(line)]; ok {
+		c.order.MoveToFront(element)
+		return
+	}
+	c.seen[string(line)] = c.order.PushFront(string(line))
+	if c.order.Len() > c.maxSeen {
+		delete(c.seen, c.order.Remove(c.order.Back()).(string))
+	}
+	if _, err := c.writer.Write(append(line, '\n')); err != nil {
+		c.err = fmt.Errorf("failed to write corpus (%w)", err)
+	}
+}
+
+// anonymize replaces the letters and digits of the value while keeping its shape.
+func anonymize(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a'
+		case r >= 'A' && r <= 'Z':
+			return 'A'
+		case r >= '0' && r <= '9':
+			return '0'
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return 'x'
+		default:
+			return r
+		}
+	}, value)
+}
+
+// ReadCorpus reads a corpus written by a CorpusRecorder.
+//goland:noinspection GoUnusedExportedFunction
+func ReadCorpus(reader io.Reader) ([]DecisionRequest, error) {
+	var requests []DecisionRequest
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		request := DecisionRequest{}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			return nil, fmt.Errorf("invalid corpus entry on line %d (%w)", line, err)
+		}
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read corpus (%w)", err)
+	}
+	return requests, nil
+}
+
+// CorpusResult is the decision for a corpus entry.
+type CorpusResult struct {
+	// Request is the corpus entry.
+	Request DecisionRequest `json:"request"`
+	// Allowed is true if the request was allowed.
+	Allowed bool `json:"allowed"`
+	// Reason is the reason of the decision, or the error if the request could not be evaluated.
+	Reason string `json:"reason"`
+}
+
+// ReplayCorpus evaluates the corpus entries using Evaluate. The results can be stored alongside the corpus and
+// compared after evaluator or policy changes. They are sorted by request type, and within the same type keep the order
+// of the corpus.
+//goland:noinspection GoUnusedExportedFunction
+func ReplayCorpus(evaluator *Evaluator, requests []DecisionRequest) []CorpusResult {
+	results := make([]CorpusResult, len(requests))
+	for i, request := range requests {
+		results[i] = CorpusResult{Request: request}
+		decision, err := evaluator.Evaluate(request)
+		if err != nil {
+			results[i].Reason = err.Error()
+			continue
+		}
+		results[i].Allowed = decision.Allowed
+		results[i].Reason = string(decision.Reason)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Request.RequestType < results[j].Request.RequestType
+	})
+	return results
+}
@@ -0,0 +1,60 @@
+package security
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorpus(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		Command: CommandConfig{Mode: ExecutionPolicyFilter, Allow: []string{"aaa aaaaa aaaa-0.aaa"}},
+	})
+	assert.NoError(t, err)
+	buf := &bytes.Buffer{}
+	recorder := NewCorpusRecorder(buf, "subsystem")
+	recorder.Register(evaluator)
+
+	evaluator.WithUsername("alice").EvaluateExec("git clone repo-1.git")
+	evaluator.EvaluateExec("abc bcdef cdef-9.xyz")
+	evaluator.EvaluateSubsystem("sftp")
+	evaluator.EvaluateEnv("LANG", "C.UTF-8")
+	assert.NoError(t, recorder.Err())
+	assert.NotContains(t, buf.String(), "alice")
+	assert.NotContains(t, buf.String(), "clone")
+
+	requests, err := ReadCorpus(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Len(t, requests, 3)
+	assert.Equal(t, "aaa aaaaa aaaa-0.aaa", requests[0].Payload["command"])
+	assert.Equal(t, "sftp", requests[1].Payload["subsystem"])
+	assert.Equal(t, "AAAA", requests[2].Payload["name"])
+
+	results := ReplayCorpus(evaluator, requests)
+	assert.Equal(t, "env", results[0].Request.RequestType)
+	assert.Equal(t, "exec", results[1].Request.RequestType)
+	assert.True(t, results[1].Allowed)
+	assert.Equal(t, string(DecisionReasonInAllowList), results[1].Reason)
+
+	_, err = ReadCorpus(bytes.NewReader([]byte("{")))
+	assert.Error(t, err)
+}
+
+func TestCorpusSeenBound(t *testing.T) {
+	buf := &bytes.Buffer{}
+	recorder := NewCorpusRecorder(buf, "subsystem")
+	recorder.maxSeen = 2
+	for _, subsystem := range []string{"a", "b", "a", "c", "b", "a"} {
+		recorder.record(DecisionRequest{RequestType: "subsystem", Payload: map[string]string{"subsystem": subsystem}})
+	}
+	assert.Len(t, recorder.seen, 2)
+	assert.Equal(t, 2, recorder.order.Len())
+	requests, err := ReadCorpus(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	var recorded []string
+	for _, request := range requests {
+		recorded = append(recorded, request.Payload["subsystem"])
+	}
+	assert.Equal(t, []string{"a", "b", "c", "b", "a"}, recorded)
+}
@@ -0,0 +1,26 @@
+package security
+
+// counterStore keeps track of concurrently used resources by key. It is shared between all connections of a handler.
+type counterStore struct {
+	store  StateStore
+	prefix string
+}
+
+func newCounterStore(store StateStore, prefix string) *counterStore {
+	return &counterStore{
+		store:  store,
+		prefix: prefix,
+	}
+}
+
+// increment increases the counter for key if it is below limit and returns true if the counter was increased.
+// A negative limit means unlimited. If the store fails the counter is not increased.
+func (c *counterStore) increment(key string, limit int) bool {
+	_, ok, err := c.store.Increment(c.prefix+key, 1, int64(limit))
+	return ok && err == nil
+}
+
+// decrement decreases the counter for key.
+func (c *counterStore) decrement(key string) {
+	_, _, _ = c.store.Increment(c.prefix+key, -1, -1)
+}
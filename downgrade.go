@@ -0,0 +1,44 @@
+package security
+
+import (
+	"fmt"
+)
+
+// AuthMethod is the method a user authenticated with.
+type AuthMethod string
+
+const (
+	// AuthMethodPassword is password authentication.
+	AuthMethodPassword AuthMethod = "password"
+
+	// AuthMethodKeyboardInteractive is keyboard-interactive authentication.
+	AuthMethodKeyboardInteractive AuthMethod = "keyboard-interactive"
+
+	// AuthMethodPublicKey is public key authentication.
+	AuthMethodPublicKey AuthMethod = "publickey"
+)
+
+// Validate validates the authentication method.
+func (a AuthMethod) Validate() error {
+	switch a {
+	case AuthMethodPassword:
+	case AuthMethodKeyboardInteractive:
+	case AuthMethodPublicKey:
+	default:
+		return fmt.Errorf("invalid authentication method: %s", a)
+	}
+	return nil
+}
+
+// downgrade returns the policy with the profile configured in Downgrade for the authentication method merged onto it.
+func (c Config) downgrade(policy Config, method AuthMethod) Config {
+	name, ok := c.Downgrade[method]
+	if !ok {
+		return policy
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return policy
+	}
+	return mergeConfig(policy, profile.withoutOverrides(), false)
+}
@@ -0,0 +1,52 @@
+package security
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDowngrade(t *testing.T) {
+	config := Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: -1,
+		Shell:               ShellConfig{Mode: ExecutionPolicyEnable},
+		Profiles: map[string]Config{
+			"weak-auth": {
+				Shell:      ShellConfig{Mode: ExecutionPolicyDisable},
+				Forwarding: ForwardingConfig{Mode: ExecutionPolicyDisable},
+			},
+		},
+		Downgrade: map[AuthMethod]string{
+			AuthMethodPassword:            "weak-auth",
+			AuthMethodKeyboardInteractive: "weak-auth",
+		},
+	}
+	h, err := NewHandler(config, &dummyHandler{})
+	assert.NoError(t, err)
+	client := net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+
+	network, err := h.OnNetworkConnection(client, "1")
+	assert.NoError(t, err)
+	_, err = network.OnAuthPassword("alice", []byte("secret"))
+	assert.NoError(t, err)
+	connection, err := network.OnHandshakeSuccess("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, ExecutionPolicyDisable, connection.(*sshConnectionHandler).config.Shell.Mode)
+	assert.Equal(t, ExecutionPolicyDisable, connection.(*sshConnectionHandler).config.Forwarding.Mode)
+
+	network, err = h.OnNetworkConnection(client, "2")
+	assert.NoError(t, err)
+	_, err = network.OnAuthPubKey("alice", "ssh-ed25519 AAAA")
+	assert.NoError(t, err)
+	connection, err = network.OnHandshakeSuccess("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, ExecutionPolicyEnable, connection.(*sshConnectionHandler).config.Shell.Mode)
+
+	assert.Error(t, Config{Downgrade: map[AuthMethod]string{AuthMethodPassword: "missing"}}.Validate())
+	assert.Error(t, Config{
+		Profiles:  map[string]Config{"weak-auth": {}},
+		Downgrade: map[AuthMethod]string{"hostbased": "weak-auth"},
+	}.Validate())
+}
@@ -0,0 +1,56 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// EgressPolicyHandler is an optional interface for session channel backends. Backends implementing it receive the
+// egress policy before a program is started and are responsible for enforcing it on the started program.
+type EgressPolicyHandler interface {
+	// OnEgressPolicy receives the egress policy for the program about to be started. If an error is returned the
+	// program is not started.
+	OnEgressPolicy(requestID uint64, policy EgressConfig) error
+}
+
+// validateEgressDestination checks a destination in the form of host, host:port, [ipv6] or [ipv6]:port. The host
+// may start with a *. wildcard.
+func validateEgressDestination(destination string) error {
+	host := destination
+	switch {
+	case strings.HasPrefix(destination, "[") && strings.HasSuffix(destination, "]"):
+		host = destination[1 : len(destination)-1]
+	case strings.HasPrefix(destination, "[") || strings.Count(destination, ":") == 1:
+		var port string
+		var err error
+		host, port, err = net.SplitHostPort(destination)
+		if err != nil {
+			return fmt.Errorf("invalid egress destination %s (%w)", destination, err)
+		}
+		portNumber, err := strconv.Atoi(port)
+		if err != nil || portNumber < 1 || portNumber > 65535 {
+			return fmt.Errorf("invalid port in egress destination: %s", destination)
+		}
+	}
+	if host == "" || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		return fmt.Errorf("invalid host in egress destination: %s", destination)
+	}
+	return nil
+}
+
+// applyEgressPolicy hands the egress policy to the backend if one is configured.
+func (s *sessionHandler) applyEgressPolicy(requestID uint64) error {
+	if s.config.Egress.Mode == ExecutionPolicyUnconfigured {
+		return nil
+	}
+	egressHandler, ok := s.backend.(EgressPolicyHandler)
+	if !ok {
+		return fmt.Errorf("egress policy not supported by backend")
+	}
+	if err := egressHandler.OnEgressPolicy(requestID, s.config.Egress); err != nil {
+		return fmt.Errorf("failed to apply egress policy")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEgressDestination(t *testing.T) {
+	for _, destination := range []string{
+		"example.com",
+		"example.com:443",
+		"*.example.com:443",
+		"10.0.0.1",
+		"2001:db8::1",
+		"[2001:db8::1]",
+		"[2001:db8::1]:443",
+	} {
+		assert.NoError(t, validateEgressDestination(destination), destination)
+	}
+	for _, destination := range []string{
+		"",
+		":443",
+		"example.com:0",
+		"example.com:http",
+		"example.com:65536",
+		"foo.*.example.com",
+		"[2001:db8::1]:",
+	} {
+		assert.Error(t, validateEgressDestination(destination), destination)
+	}
+}
+
+func TestEgressPolicy(t *testing.T) {
+	session := &sessionHandler{
+		config:  Config{},
+		backend: &dummyBackend{},
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnShell(1))
+
+	session.config.Egress = EgressConfig{
+		Mode:  ExecutionPolicyFilter,
+		Allow: []string{"example.com:443"},
+	}
+	assert.Error(t, session.OnShell(2))
+
+	backend := &egressBackend{}
+	session.backend = backend
+	assert.NoError(t, session.OnShell(3))
+	assert.Equal(t, []string{"example.com:443"}, backend.policy.Allow)
+}
+
+type egressBackend struct {
+	dummyBackend
+	policy EgressConfig
+}
+
+func (e *egressBackend) OnEgressPolicy(_ uint64, policy EgressConfig) error {
+	e.policy = policy
+	return nil
+}
@@ -0,0 +1,47 @@
+package security
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// checkEnvValue denies an allowed environment variable if its value violates Env.MaxValueLength or the constraints in
+// Env.Values.
+func (e *Evaluator) checkEnvValue(mode ExecutionPolicy, name string, value string, decision Decision) Decision {
+	if !decision.Allowed {
+		return decision
+	}
+	if maxLength := e.config.Env.MaxValueLength; maxLength > 0 && len(value) > maxLength {
+		return deny(mode, DecisionReasonInvalidValue, fmt.Sprintf("the value is longer than %d bytes", maxLength))
+	}
+	constraints, ok := e.config.Env.Values[name]
+	if !ok {
+		if constraints, ok = e.config.Env.Values["*"]; !ok {
+			return decision
+		}
+	}
+	if err := constraints.check(e.config.patterns, value); err != nil {
+		return deny(mode, DecisionReasonInvalidValue, err.Error())
+	}
+	return decision
+}
+
+func (e EnvValueConfig) check(patterns *patternSet, value string) error {
+	if e.MaxLength > 0 && len(value) > e.MaxLength {
+		return fmt.Errorf("the value is longer than %d bytes", e.MaxLength)
+	}
+	for _, r := range value {
+		switch {
+		case unicode.IsControl(r):
+			if !e.AllowControlCharacters {
+				return fmt.Errorf("the value contains control characters")
+			}
+		case e.ASCII && r > unicode.MaxASCII:
+			return fmt.Errorf("the value contains non-ASCII characters")
+		}
+	}
+	if e.Pattern != "" && !patterns.matchPattern(MatchModeRegex, e.Pattern, value) {
+		return fmt.Errorf("the value does not match the required pattern")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvValues(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		Env: EnvConfig{
+			Values: map[string]EnvValueConfig{
+				"LANG": {MaxLength: 32, Pattern: `[a-zA-Z_]+(\.[a-zA-Z0-9-]+)?`},
+				"*":    {MaxLength: 8, ASCII: true},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	for _, testCase := range []struct {
+		name    string
+		value   string
+		allowed bool
+	}{
+		{"LANG", "en_US.UTF-8", true},
+		{"LANG", "en_US.UTF-8; rm -rf /", false},
+		{"LANG", "en_US\n.UTF-8", false},
+		{"TZ", "UTC", true},
+		{"TZ", "Europe/Berlin", false},
+		{"TZ", "Zürich", false},
+		{"TZ", "\x1b[2J", false},
+	} {
+		decision := evaluator.EvaluateEnv(testCase.name, testCase.value)
+		assert.Equal(t, testCase.allowed, decision.Allowed, testCase.value)
+		if !testCase.allowed {
+			assert.Equal(t, DecisionReasonInvalidValue, decision.Reason, testCase.value)
+		}
+	}
+
+	assert.Error(t, EnvValueConfig{MaxLength: -1}.Validate())
+	assert.Error(t, EnvValueConfig{Pattern: "("}.Validate())
+}
+
+func TestEnvLimits(t *testing.T) {
+	backend := &dummyBackend{env: map[string]string{}}
+	session := &sessionHandler{
+		config: Config{
+			Env: EnvConfig{MaxCount: 2, MaxValueLength: 4},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnEnvRequest(1, "LANG", "C"))
+	assert.Error(t, session.OnEnvRequest(2, "TZ", "Europe/Berlin"))
+	assert.Error(t, session.OnEnvRequest(3, "TZ", "UTC"))
+	assert.Equal(t, map[string]string{"LANG": "C"}, backend.env)
+
+	assert.Error(t, EnvConfig{MaxCount: -1}.Validate())
+	assert.Error(t, EnvConfig{MaxValueLength: -1}.Validate())
+}
@@ -0,0 +1,425 @@
+package security
+
+// Decision is the result of evaluating a request against the policy.
+type Decision struct {
+	// Allowed is true if the request is permitted.
+	Allowed bool `json:"allowed"`
+	// Mode is the effective execution policy applied to the request.
+	Mode ExecutionPolicy `json:"mode"`
+	// Reason is a machine-readable code explaining why the request was allowed or denied.
+	Reason DecisionReason `json:"reason"`
+	// Message describes the reason in a human-readable form.
+	Message string `json:"message"`
+	// Flagged is true if the request was allowed in ExecutionPolicyAudit mode, but would have been denied in
+	// ExecutionPolicyFilter mode. This allows rolling out allow lists in report-only mode before enforcing them.
+	Flagged bool `json:"flagged"`
+}
+
+// DecisionReason explains a decision.
+type DecisionReason string
+
+const (
+	// DecisionReasonModeEnabled means the request was allowed because its section is enabled and it didn't match the
+	// deny list.
+	DecisionReasonModeEnabled DecisionReason = "mode-enabled"
+	// DecisionReasonInAllowList means the request was allowed because it matched the allow list in filter mode.
+	DecisionReasonInAllowList DecisionReason = "in-allowlist"
+	// DecisionReasonForceCommandOverride means the request was allowed, but the program is replaced by ForceCommand.
+	DecisionReasonForceCommandOverride DecisionReason = "force-command-override"
+	// DecisionReasonModeDisabled means the request was denied because its section is disabled.
+	DecisionReasonModeDisabled DecisionReason = "mode-disabled"
+	// DecisionReasonDeniedByDefaultMode means the request was denied because its section is not configured and
+	// the default of its category or DefaultMode disables it.
+	DecisionReasonDeniedByDefaultMode DecisionReason = "denied-by-default-mode"
+	// DecisionReasonNotInAllowList means the request was denied because it didn't match the allow list in filter
+	// mode.
+	DecisionReasonNotInAllowList DecisionReason = "not-in-allowlist"
+	// DecisionReasonMatchedDenyList means the request was denied because it matched the deny list.
+	DecisionReasonMatchedDenyList DecisionReason = "matched-denylist"
+	// DecisionReasonHardenedEnvironment means the environment variable is reserved by Env.HardenEnvironment.
+	DecisionReasonHardenedEnvironment DecisionReason = "hardened-environment"
+	// DecisionReasonDangerousVariable means the environment variable is on the built-in list of variables that can
+	// alter the behavior of programs, see Env.AllowDangerous.
+	DecisionReasonDangerousVariable DecisionReason = "dangerous-variable"
+	// DecisionReasonInvalidValue means the value of the environment variable violates Env.MaxValueLength or the
+	// constraints in Env.Values.
+	DecisionReasonInvalidValue DecisionReason = "invalid-value"
+	// DecisionReasonUnknownSignal means the signal is not defined by the SSH protocol while Signal.Strict is enabled.
+	DecisionReasonUnknownSignal DecisionReason = "unknown-signal"
+	// DecisionReasonOutsideHomeDirectory means the command references paths outside the home directory while
+	// ConfineToHome is enabled.
+	DecisionReasonOutsideHomeDirectory DecisionReason = "outside-home-directory"
+	// DecisionReasonHomeDirectoryUnavailable means the home directory for ConfineToHome could not be determined.
+	DecisionReasonHomeDirectoryUnavailable DecisionReason = "home-directory-unavailable"
+	// DecisionReasonWildcardBind means the reverse forwarding request was denied because it binds all interfaces
+	// while ReverseForwarding.ForbidWildcard is enabled.
+	DecisionReasonWildcardBind DecisionReason = "wildcard-bind"
+	// DecisionReasonSingleConnectionRequired means the X11 forwarding request was denied because it asked for more
+	// than one connection while X11.SingleConnection is enabled.
+	DecisionReasonSingleConnectionRequired DecisionReason = "single-connection-required"
+	// DecisionReasonBreakTooLong means the break request was denied because it asked for a break longer than
+	// Break.MaxLength.
+	DecisionReasonBreakTooLong DecisionReason = "break-too-long"
+	// DecisionReasonTermTooLong means the TTY request was denied because its terminal type is longer than
+	// TTY.MaxTermLength.
+	DecisionReasonTermTooLong DecisionReason = "term-too-long"
+	// DecisionReasonDirectionNotAllowed means the rsync transfer was denied because of its direction.
+	DecisionReasonDirectionNotAllowed DecisionReason = "direction-not-allowed"
+	// DecisionReasonUnparseableCommand means the command was denied because its arguments could not be determined
+	// unambiguously.
+	DecisionReasonUnparseableCommand DecisionReason = "unparseable-command"
+	// DecisionReasonSFTPServerExec means the command was denied because it runs an SFTP server while SFTP
+	// restrictions are configured, which would bypass the SFTP filter.
+	DecisionReasonSFTPServerExec DecisionReason = "sftp-server-exec"
+	// DecisionReasonDryRun means the request was allowed because DryRun is enabled, although the policy denies it. The
+	// message explains why the request would have been denied.
+	DecisionReasonDryRun DecisionReason = "dry-run"
+	// DecisionReasonVetoed means the request was denied by an OnBeforeDecision hook.
+	DecisionReasonVetoed DecisionReason = "vetoed"
+)
+
+// Evaluator decides whether requests are permitted by a policy. It only evaluates the policy itself, side effects
+// such as auditing, prompting the user or forcing commands are applied by the handlers returned from New and
+// NewHandler.
+type Evaluator struct {
+	config   Config
+	username string
+	hooks    *decisionHooks
+	groups   []string
+	// base is the policy before the overrides of the user and groups were applied, if any.
+	base *Config
+}
+
+// NewEvaluator creates an evaluator for the specified policy.
+//goland:noinspection GoUnusedExportedFunction
+func NewEvaluator(config Config) (*Evaluator, error) {
+	config, err := config.prepare()
+	if err != nil {
+		return nil, err
+	}
+	return &Evaluator{
+		config: config,
+		hooks:  newDecisionHooks(),
+	}, nil
+}
+
+// OnBeforeDecision registers a hook called before a request is evaluated. If the hook returns an error the request is
+// denied with the error as the message. Hooks are shared with evaluators returned by WithUsername.
+func (e *Evaluator) OnBeforeDecision(hook func(request DecisionRequest) error) {
+	e.hooks.OnBeforeDecision(hook)
+}
+
+// OnAfterDecision registers a hook called with the decision after a request has been evaluated. Hooks are shared with
+// evaluators returned by WithUsername.
+func (e *Evaluator) OnAfterDecision(hook func(request DecisionRequest, decision Decision)) {
+	e.hooks.OnAfterDecision(hook)
+}
+
+// decide evaluates a request with the effective policy for primary, running the registered hooks.
+func (e *Evaluator) decide(
+	requestType string,
+	payload map[string]string,
+	primary ExecutionPolicy,
+	evaluate func(mode ExecutionPolicy) Decision,
+) Decision {
+	mode := e.config.effectivePolicy(primary, e.config.Defaults.forRequest(requestType))
+	request := DecisionRequest{
+		Username:    e.username,
+		RequestType: requestType,
+		Payload:     payload,
+	}
+	decision := e.hooks.decide(request, mode, func() Decision {
+		decision := evaluate(mode)
+		if decision.Reason == DecisionReasonModeDisabled && primary == ExecutionPolicyUnconfigured {
+			decision.Reason = DecisionReasonDeniedByDefaultMode
+		}
+		if decision.Allowed && mode == ExecutionPolicyAudit {
+			decision.Flagged = !evaluate(ExecutionPolicyFilter).Allowed
+		}
+		return decision
+	})
+	if e.config.DryRun && !decision.Allowed && decision.Reason != DecisionReasonVetoed {
+		return allow(decision.Mode, DecisionReasonDryRun, decision.Message)
+	}
+	return decision
+}
+
+// WithUsername returns an evaluator for requests of the specified user, applying the override in Users for the user.
+// The username is required to evaluate command requests when ConfineToHome is enabled.
+func (e *Evaluator) WithUsername(username string) *Evaluator {
+	return e.derive(username, e.groups)
+}
+
+// WithGroups returns an evaluator for requests of a user in the specified groups, applying the overrides in Groups in
+// the specified order.
+func (e *Evaluator) WithGroups(groups ...string) *Evaluator {
+	return e.derive(e.username, groups)
+}
+
+func (e *Evaluator) derive(username string, groups []string) *Evaluator {
+	base := e.base
+	if base == nil {
+		base = &e.config
+	}
+	return &Evaluator{
+		config:   base.forUser(username, groups),
+		username: username,
+		groups:   groups,
+		hooks:    e.hooks,
+		base:     base,
+	}
+}
+
+func allow(mode ExecutionPolicy, reason DecisionReason, message string) Decision {
+	return Decision{Allowed: true, Mode: mode, Reason: reason, Message: message}
+}
+
+func deny(mode ExecutionPolicy, reason DecisionReason, message string) Decision {
+	return Decision{Allowed: false, Mode: mode, Reason: reason, Message: message}
+}
+
+// applyForceCommand marks allowed decisions for programs replaced by a forced command.
+func (e *Evaluator) applyForceCommand(requestType string, decision Decision) Decision {
+	if !decision.Allowed || !e.config.forcedFor(requestType).set() {
+		return decision
+	}
+	return allow(decision.Mode, DecisionReasonForceCommandOverride, "the program is replaced by the forced command")
+}
+
+// EvaluateEnv evaluates a request to set an environment variable.
+func (e *Evaluator) EvaluateEnv(name string, value string) Decision {
+	payload := map[string]string{"name": name, "value": value}
+	return e.decide("env", payload, e.config.Env.Mode, func(mode ExecutionPolicy) Decision {
+		return e.checkEnvValue(mode, name, value, e.evaluateEnv(mode, name))
+	})
+}
+
+func (e *Evaluator) evaluateEnv(mode ExecutionPolicy, name string) Decision {
+	if e.config.Env.HardenEnvironment && isHardenedEnv(name) {
+		return deny(mode, DecisionReasonHardenedEnvironment, "the variable is set by the hardened environment")
+	}
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "environment variables are disabled")
+	case ExecutionPolicyFilter:
+		if e.config.patterns.matchList(e.config.Env.MatchMode, e.config.Env.Allow, name) {
+			return allow(mode, DecisionReasonInAllowList, "the variable is on the allow list")
+		}
+		return deny(mode, DecisionReasonNotInAllowList, "the variable is not on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		if e.config.patterns.matchList(e.config.Env.MatchMode, e.config.Env.Deny, name) {
+			return deny(mode, DecisionReasonMatchedDenyList, "the variable is on the deny list")
+		}
+		if !e.config.Env.AllowDangerous && isDangerousEnv(name) {
+			return deny(mode, DecisionReasonDangerousVariable, "the variable can alter the behavior of programs")
+		}
+		return allow(mode, DecisionReasonModeEnabled, "environment variables are enabled")
+	}
+}
+
+// EvaluatePTY evaluates a TTY/PTY request for the specified terminal type (TERM).
+func (e *Evaluator) EvaluatePTY(term string) Decision {
+	return e.decide("pty", map[string]string{"term": term}, e.config.TTY.Mode, func(mode ExecutionPolicy) Decision {
+		return e.evaluatePTY(mode, term)
+	})
+}
+
+func (e *Evaluator) evaluatePTY(mode ExecutionPolicy, term string) Decision {
+	config := e.config.TTY
+	if mode != ExecutionPolicyDisable && config.MaxTermLength > 0 && len(term) > config.MaxTermLength {
+		return deny(mode, DecisionReasonTermTooLong, "the terminal type is too long")
+	}
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "TTY requests are disabled")
+	case ExecutionPolicyFilter:
+		if len(config.Allow) == 0 {
+			return deny(mode, DecisionReasonModeDisabled, "TTY requests are disabled")
+		}
+		if e.config.patterns.matchList(config.MatchMode, config.Allow, term) {
+			return allow(mode, DecisionReasonInAllowList, "the terminal type is on the allow list")
+		}
+		return deny(mode, DecisionReasonNotInAllowList, "the terminal type is not on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		if e.config.patterns.matchList(config.MatchMode, config.Deny, term) {
+			return deny(mode, DecisionReasonMatchedDenyList, "the terminal type is on the deny list")
+		}
+		return allow(mode, DecisionReasonModeEnabled, "TTY requests are enabled")
+	}
+}
+
+// EvaluateExec evaluates a command execution (exec) request.
+func (e *Evaluator) EvaluateExec(program string) Decision {
+	payload := map[string]string{"command": program, "commandHash": CommandHash(program)}
+	primary := e.config.Command.Mode
+	invocation, rsync := e.config.Rsync.parse(program)
+	if rsync = rsync && e.config.Rsync.Mode != ExecutionPolicyUnconfigured; rsync {
+		primary = e.config.Rsync.Mode
+	}
+	return e.decide("exec", payload, primary, func(mode ExecutionPolicy) Decision {
+		if mode != ExecutionPolicyDisable && matchCommandHash(e.config.Command.DenyHashes, program) {
+			return deny(mode, DecisionReasonMatchedDenyList, "the command hash is on the deny list")
+		}
+		if mode != ExecutionPolicyDisable && len(e.config.Command.Rewrite) > 0 {
+			if _, err := splitShellWords(program); err != nil {
+				return deny(mode, DecisionReasonUnparseableCommand, "the command can't be checked for rewriting")
+			}
+		}
+		if mode != ExecutionPolicyDisable && e.sftpRestricted() && runsSFTPServer(program) {
+			return deny(mode, DecisionReasonSFTPServerExec, "the SFTP server may only be started as a subsystem")
+		}
+		var decision Decision
+		if rsync {
+			decision = e.evaluateRsync(mode, invocation)
+			commandMode := e.config.effectivePolicy(e.config.Command.Mode, e.config.Defaults.forRequest("exec"))
+			if decision.Allowed && commandMode == ExecutionPolicyFilter {
+				if command := e.evaluateExec(commandMode, program); !command.Allowed {
+					decision = deny(mode, command.Reason, command.Message)
+				}
+			}
+		} else {
+			decision = e.evaluateExec(mode, program)
+		}
+		return e.applyForceCommand("exec", e.confine(mode, program, decision))
+	})
+}
+
+func (e *Evaluator) evaluateExec(mode ExecutionPolicy, program string) Decision {
+	decision := allow(mode, DecisionReasonModeEnabled, "command execution is enabled")
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "command execution is disabled")
+	case ExecutionPolicyFilter:
+		command := e.config.Command
+		if !e.config.patterns.matchList(command.MatchMode, command.Allow, program) &&
+			!e.config.patterns.matchCommandRules(command.Rules, program) {
+			return deny(mode, DecisionReasonNotInAllowList, "the command is not on the allow list")
+		}
+		decision = allow(mode, DecisionReasonInAllowList, "the command is on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	case ExecutionPolicyPrompt:
+		fallthrough
+	default:
+	}
+	return decision
+}
+
+// confine denies an allowed command if it references paths outside the home directory while ConfineToHome is
+// enabled.
+func (e *Evaluator) confine(mode ExecutionPolicy, program string, decision Decision) Decision {
+	if !decision.Allowed {
+		return decision
+	}
+	if e.config.ConfineToHome {
+		if e.username == "" {
+			return deny(mode, DecisionReasonHomeDirectoryUnavailable, "the username is required to confine the command to the home directory")
+		}
+		home, err := resolveHomeDirectory(e.config.HomeDirectory, e.username)
+		if err != nil {
+			return deny(mode, DecisionReasonHomeDirectoryUnavailable, "the home directory could not be determined")
+		}
+		if err := checkConfinement(home, program); err != nil {
+			return deny(mode, DecisionReasonOutsideHomeDirectory, "the command references paths outside the home directory")
+		}
+	}
+	return decision
+}
+
+// EvaluateShell evaluates a shell request.
+func (e *Evaluator) EvaluateShell() Decision {
+	return e.decide("shell", map[string]string{}, e.config.Shell.Mode, func(mode ExecutionPolicy) Decision {
+		return e.applyForceCommand("shell", e.evaluateShell(mode))
+	})
+}
+
+func (e *Evaluator) evaluateShell(mode ExecutionPolicy) Decision {
+	switch mode {
+	case ExecutionPolicyDisable:
+		fallthrough
+	case ExecutionPolicyFilter:
+		return deny(mode, DecisionReasonModeDisabled, "shell execution is disabled")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	case ExecutionPolicyPrompt:
+		fallthrough
+	default:
+		return allow(mode, DecisionReasonModeEnabled, "shell execution is enabled")
+	}
+}
+
+// EvaluateSubsystem evaluates a subsystem request.
+func (e *Evaluator) EvaluateSubsystem(subsystem string) Decision {
+	payload := map[string]string{"subsystem": subsystem}
+	return e.decide("subsystem", payload, e.config.Subsystem.Mode, func(mode ExecutionPolicy) Decision {
+		return e.applyForceCommand("subsystem", e.evaluateSubsystem(mode, subsystem))
+	})
+}
+
+func (e *Evaluator) evaluateSubsystem(mode ExecutionPolicy, subsystem string) Decision {
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "subsystems are disabled")
+	case ExecutionPolicyFilter:
+		if !e.config.patterns.matchSubsystem(e.config.Subsystem.MatchMode, e.config.Subsystem.Allow, subsystem) {
+			return deny(mode, DecisionReasonNotInAllowList, "the subsystem is not on the allow list")
+		}
+		return allow(mode, DecisionReasonInAllowList, "the subsystem is on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	case ExecutionPolicyPrompt:
+		if e.config.patterns.matchSubsystem(e.config.Subsystem.MatchMode, e.config.Subsystem.Deny, subsystem) {
+			return deny(mode, DecisionReasonMatchedDenyList, "the subsystem is on the deny list")
+		}
+		return allow(mode, DecisionReasonModeEnabled, "subsystems are enabled")
+	default:
+		return allow(mode, DecisionReasonModeEnabled, "subsystems are enabled")
+	}
+}
+
+// EvaluateSignal evaluates a signal request. The signal is specified without the SIG prefix.
+func (e *Evaluator) EvaluateSignal(signal string) Decision {
+	payload := map[string]string{"signal": signal}
+	return e.decide("signal", payload, e.config.Signal.Mode, func(mode ExecutionPolicy) Decision {
+		return e.evaluateSignal(mode, normalizeSignal(signal))
+	})
+}
+
+func (e *Evaluator) evaluateSignal(mode ExecutionPolicy, signal string) Decision {
+	config := e.config.Signal
+	if config.Strict && mode != ExecutionPolicyDisable && validateSignal(signal) != nil {
+		return deny(mode, DecisionReasonUnknownSignal, "the signal is not defined by the SSH protocol")
+	}
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "signals are disabled")
+	case ExecutionPolicyFilter:
+		if e.config.patterns.matchList(config.MatchMode, signalList(config.MatchMode, config.Allow), signal) {
+			return allow(mode, DecisionReasonInAllowList, "the signal is on the allow list")
+		}
+		return deny(mode, DecisionReasonNotInAllowList, "the signal is not on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		if e.config.patterns.matchList(config.MatchMode, signalList(config.MatchMode, config.Deny), signal) {
+			return deny(mode, DecisionReasonMatchedDenyList, "the signal is on the deny list")
+		}
+		return allow(mode, DecisionReasonModeEnabled, "signals are enabled")
+	}
+}
@@ -0,0 +1,223 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluator(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		DefaultMode: ExecutionPolicyDisable,
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"/bin/ls ./src"},
+		},
+		Env: EnvConfig{
+			Mode:              ExecutionPolicyEnable,
+			Deny:              []string{"LD_PRELOAD"},
+			HardenEnvironment: true,
+		},
+		Subsystem: SubsystemConfig{
+			Mode: ExecutionPolicyAudit,
+			Deny: []string{"internal/*"},
+		},
+		ConfineToHome: true,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, Decision{
+		Allowed: true,
+		Mode:    ExecutionPolicyEnable,
+		Reason:  DecisionReasonModeEnabled,
+		Message: "environment variables are enabled",
+	}, evaluator.EvaluateEnv("LANG", "C"))
+	assert.False(t, evaluator.EvaluateEnv("LD_PRELOAD", "/tmp/evil.so").Allowed)
+	assert.False(t, evaluator.EvaluateEnv("PATH", "/tmp").Allowed)
+
+	assert.False(t, evaluator.EvaluateExec("/bin/ls ./src").Allowed)
+	assert.True(t, evaluator.WithUsername("foo").EvaluateExec("/bin/ls ./src").Allowed)
+	assert.False(t, evaluator.WithUsername("foo").EvaluateExec("/bin/cat ./src").Allowed)
+
+	assert.Equal(t, Decision{
+		Allowed: false,
+		Mode:    ExecutionPolicyDisable,
+		Reason:  DecisionReasonDeniedByDefaultMode,
+		Message: "shell execution is disabled",
+	}, evaluator.EvaluateShell())
+	assert.False(t, evaluator.EvaluatePTY("xterm").Allowed)
+	assert.False(t, evaluator.EvaluateSignal("TERM").Allowed)
+
+	subsystem := evaluator.EvaluateSubsystem("sftp")
+	assert.True(t, subsystem.Allowed)
+	assert.Equal(t, ExecutionPolicyAudit, subsystem.Mode)
+	assert.False(t, evaluator.EvaluateSubsystem("internal/backup").Allowed)
+
+	_, err = NewEvaluator(Config{DefaultMode: "foo"})
+	assert.Error(t, err)
+}
+
+func TestDecisionReasons(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		ForceCommand: "/usr/bin/backup",
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"backup"},
+		},
+		Env: EnvConfig{
+			Deny: []string{"LD_PRELOAD"},
+		},
+		TTY: TTYConfig{
+			Mode: ExecutionPolicyDisable,
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, DecisionReasonForceCommandOverride, evaluator.EvaluateExec("backup").Reason)
+	assert.Equal(t, DecisionReasonNotInAllowList, evaluator.EvaluateExec("restore").Reason)
+	assert.Equal(t, DecisionReasonMatchedDenyList, evaluator.EvaluateEnv("LD_PRELOAD", "").Reason)
+	assert.Equal(t, DecisionReasonModeEnabled, evaluator.EvaluateEnv("LANG", "C").Reason)
+	assert.Equal(t, DecisionReasonModeDisabled, evaluator.EvaluatePTY("xterm").Reason)
+}
+
+func TestCategoryDefaults(t *testing.T) {
+	config := Config{
+		DefaultMode: ExecutionPolicyDisable,
+		Defaults: DefaultsConfig{
+			SessionRequests: ExecutionPolicyEnable,
+		},
+		Forwarding: ForwardingConfig{
+			Mode: ExecutionPolicyEnable,
+		},
+	}
+	evaluator, err := NewEvaluator(config)
+	assert.NoError(t, err)
+	assert.True(t, evaluator.EvaluateShell().Allowed)
+	assert.True(t, evaluator.EvaluateForwarding("127.0.0.1", 80).Allowed)
+	decision := evaluator.EvaluateReverseForwarding("127.0.0.1", 8080)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, DecisionReasonDeniedByDefaultMode, decision.Reason)
+
+	config.Defaults.Forwarding = ExecutionPolicyEnable
+	config.Defaults.SessionRequests = ExecutionPolicyDisable
+	evaluator, err = NewEvaluator(config)
+	assert.NoError(t, err)
+	assert.False(t, evaluator.EvaluateShell().Allowed)
+	assert.True(t, evaluator.EvaluateReverseForwarding("127.0.0.1", 8080).Allowed)
+	assert.Equal(t, ExecutionPolicyDisable, config.EffectiveCapabilities().Shell)
+
+	backend := &globalRequestBackend{}
+	connection := &sshConnectionHandler{
+		config: Config{
+			DefaultMode: ExecutionPolicyDisable,
+			Defaults:    DefaultsConfig{GlobalRequests: ExecutionPolicyEnable},
+		},
+		backend: backend,
+		lock:    &sync.Mutex{},
+	}
+	connection.OnUnsupportedGlobalRequest(1, "keepalive@openssh.com", []byte{})
+	assert.Equal(t, []string{"keepalive@openssh.com"}, backend.requests)
+
+	assert.Error(t, Config{Defaults: DefaultsConfig{Channels: "sometimes"}}.Validate())
+}
+
+func TestAuditFlagging(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyAudit,
+			Allow: []string{"/bin/ls"},
+		},
+		Env: EnvConfig{
+			Mode: ExecutionPolicyAudit,
+			Deny: []string{"LD_PRELOAD"},
+		},
+	})
+	assert.NoError(t, err)
+	decision := evaluator.EvaluateExec("/bin/ls")
+	assert.True(t, decision.Allowed)
+	assert.False(t, decision.Flagged)
+	decision = evaluator.EvaluateExec("/bin/rm -rf /")
+	assert.True(t, decision.Allowed)
+	assert.True(t, decision.Flagged)
+	assert.False(t, evaluator.EvaluateEnv("LD_PRELOAD", "foo.so").Allowed)
+}
+
+func TestDryRun(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		DefaultMode: ExecutionPolicyDisable,
+		DryRun:      true,
+	})
+	assert.NoError(t, err)
+	var decisions []Decision
+	evaluator.OnAfterDecision(func(_ DecisionRequest, decision Decision) {
+		decisions = append(decisions, decision)
+	})
+	decision := evaluator.EvaluateShell()
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, DecisionReasonDryRun, decision.Reason)
+	assert.Len(t, decisions, 1)
+	assert.False(t, decisions[0].Allowed)
+	assert.Equal(t, DecisionReasonDeniedByDefaultMode, decisions[0].Reason)
+
+	evaluator.OnBeforeDecision(func(_ DecisionRequest) error {
+		return fmt.Errorf("maintenance")
+	})
+	assert.False(t, evaluator.EvaluateShell().Allowed)
+}
+
+func TestDangerousEnv(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionReasonDangerousVariable, evaluator.EvaluateEnv("LD_PRELOAD", "/tmp/evil.so").Reason)
+	assert.Equal(t, DecisionReasonDangerousVariable, evaluator.EvaluateEnv("PYTHONSTARTUP", "/tmp/evil.py").Reason)
+	assert.True(t, evaluator.EvaluateEnv("LANG", "C").Allowed)
+
+	evaluator, err = NewEvaluator(Config{Env: EnvConfig{AllowDangerous: true}})
+	assert.NoError(t, err)
+	assert.True(t, evaluator.EvaluateEnv("LD_LIBRARY_PATH", "/opt/lib").Allowed)
+
+	evaluator, err = NewEvaluator(Config{Env: EnvConfig{Mode: ExecutionPolicyFilter, Allow: []string{"LD_LIBRARY_PATH"}}})
+	assert.NoError(t, err)
+	assert.True(t, evaluator.EvaluateEnv("LD_LIBRARY_PATH", "/opt/lib").Allowed)
+}
+
+func TestTTYTerm(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		TTY: TTYConfig{
+			Mode:          ExecutionPolicyFilter,
+			Allow:         []string{"glob:xterm*", "glob:screen*", "glob:tmux*", "vt100"},
+			MaxTermLength: 32,
+		},
+	})
+	assert.NoError(t, err)
+	for _, testCase := range []struct {
+		term   string
+		reason DecisionReason
+	}{
+		{"xterm-256color", DecisionReasonInAllowList},
+		{"screen.xterm-256color", DecisionReasonInAllowList},
+		{"tmux-256color", DecisionReasonInAllowList},
+		{"vt100", DecisionReasonInAllowList},
+		{"vt220", DecisionReasonNotInAllowList},
+		{"xterm" + strings.Repeat("A", 64), DecisionReasonTermTooLong},
+	} {
+		assert.Equal(t, testCase.reason, evaluator.EvaluatePTY(testCase.term).Reason, testCase.term)
+	}
+
+	evaluator, err = NewEvaluator(Config{
+		TTY: TTYConfig{Mode: ExecutionPolicyEnable, Deny: []string{"re:.*[^a-z0-9.+-].*"}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, evaluator.EvaluatePTY("xterm-256color").Allowed)
+	assert.Equal(t, DecisionReasonMatchedDenyList, evaluator.EvaluatePTY("xterm;id").Reason)
+
+	evaluator, err = NewEvaluator(Config{TTY: TTYConfig{Mode: ExecutionPolicyFilter}})
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionReasonModeDisabled, evaluator.EvaluatePTY("xterm").Reason)
+
+	assert.Error(t, TTYConfig{MaxTermLength: -1}.Validate())
+	assert.Error(t, TTYConfig{Deny: []string{"re:xterm("}}.Validate())
+}
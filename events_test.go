@@ -0,0 +1,107 @@
+package security
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	protoMessage = regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n}`)
+	protoField   = regexp.MustCompile(`(?m)^\s+([\w<>, ]+?) (\w+) = \d+;`)
+)
+
+// protoJSONName returns the JSON name protobuf derives from a field name.
+func protoJSONName(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// protoType returns the protobuf type a Go field is expected to be declared with.
+func protoType(fieldType reflect.Type) string {
+	switch fieldType.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Int:
+		return "int32"
+	case reflect.Uint64:
+		return "uint64"
+	case reflect.Map:
+		return "map<string, string>"
+	default:
+		return fieldType.Name()
+	}
+}
+
+// TestEventSchema checks that the event structures match their protobuf definitions, so the JSON written by this
+// library can be parsed using the protobuf JSON mapping.
+func TestEventSchema(t *testing.T) {
+	data, err := ioutil.ReadFile("proto/security/v1/events.proto")
+	assert.NoError(t, err)
+	messages := map[string]map[string]string{}
+	for _, message := range protoMessage.FindAllStringSubmatch(string(data), -1) {
+		fields := map[string]string{}
+		for _, field := range protoField.FindAllStringSubmatch(message[2], -1) {
+			fields[protoJSONName(field[2])] = field[1]
+		}
+		messages[message[1]] = fields
+	}
+	for _, value := range []interface{}{AuditedRequest{}, DecisionRequest{}, Decision{}, DecisionEvent{}} {
+		goType := reflect.TypeOf(value)
+		fields, ok := messages[goType.Name()]
+		if !assert.True(t, ok, "no protobuf message for %s", goType.Name()) {
+			continue
+		}
+		goFields := map[string]bool{}
+		for i := 0; i < goType.NumField(); i++ {
+			name := strings.Split(goType.Field(i).Tag.Get("json"), ",")[0]
+			goFields[name] = true
+			assert.Equal(t, protoType(goType.Field(i).Type), fields[name], "%s.%s", goType.Name(), name)
+		}
+		for name := range fields {
+			assert.True(t, goFields[name], "%s.%s is not in the Go structure", goType.Name(), name)
+		}
+	}
+}
+
+// TestEventSchemaV1 checks that events written with schema version 1 can still be decoded.
+func TestEventSchemaV1(t *testing.T) {
+	auditedRequest := AuditedRequest{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"schemaVersion": 1,
+		"requestId": 3,
+		"username": "foo",
+		"requestType": "exec",
+		"payload": {"command": "/bin/ls"},
+		"sampled": true
+	}`), &auditedRequest))
+	assert.Equal(t, AuditedRequest{
+		SchemaVersion: 1,
+		RequestID:     3,
+		Username:      "foo",
+		RequestType:   "exec",
+		Payload:       map[string]string{"command": "/bin/ls"},
+		Sampled:       true,
+	}, auditedRequest)
+
+	event := DecisionEvent{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"schemaVersion": 1,
+		"request": {"username": "foo", "requestType": "shell", "payload": {}},
+		"decision": {"allowed": false, "mode": "disable", "reason": "mode-disabled", "message": "", "flagged": false}
+	}`), &event))
+	assert.Equal(t, NewDecisionEvent(
+		DecisionRequest{Username: "foo", RequestType: "shell", Payload: map[string]string{}},
+		Decision{Mode: ExecutionPolicyDisable, Reason: DecisionReasonModeDisabled},
+	), event)
+}
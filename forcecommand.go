@@ -0,0 +1,230 @@
+package security
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// forceCommandData is the data structure passed to the ForceCommand, ForceCommandArgs and Env.Set templates. The
+// values are quoted for the shell in ForceCommand only.
+type forceCommandData struct {
+	Username     string
+	RemoteIP     string
+	ConnectionID string
+	SessionID    string
+}
+
+func parseForceCommand(forceCommand string) (*template.Template, error) {
+	return template.New("forceCommand").Option("missingkey=error").Parse(forceCommand)
+}
+
+// shellQuote quotes the value as a single argument for POSIX shells.
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// placeholderMarker stands in for the template values when checking where a ForceCommand template inserts them.
+const placeholderMarker = "\x00"
+
+// checkPlaceholderQuoting returns an error if the ForceCommand template inserts a value within quotes or after a
+// backslash. The values are quoted when they are inserted, so quoting them again would break or undo the quoting.
+func checkPlaceholderQuoting(tpl *template.Template) error {
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, forceCommandData{
+		Username:     placeholderMarker,
+		RemoteIP:     placeholderMarker,
+		ConnectionID: placeholderMarker,
+		SessionID:    placeholderMarker,
+	}); err != nil {
+		return err
+	}
+	var quote byte
+	escaped := false
+	command := buf.String()
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == placeholderMarker[0]:
+			if quote != 0 || escaped {
+				return fmt.Errorf("placeholders must not be quoted, they are quoted when inserted")
+			}
+		case escaped:
+		case c == '\\' && quote != '\'':
+			escaped = true
+			continue
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case c == quote:
+			quote = 0
+		}
+		escaped = false
+	}
+	return nil
+}
+
+// formatForceCommand renders the ForceCommand template with the connection metadata.
+func formatForceCommand(forceCommand string, data forceCommandData) (string, error) {
+	tpl, err := parseForceCommand(forceCommand)
+	if err != nil {
+		return "", err
+	}
+	data = forceCommandData{
+		Username:     shellQuote(data.Username),
+		RemoteIP:     shellQuote(data.RemoteIP),
+		ConnectionID: shellQuote(data.ConnectionID),
+		SessionID:    shellQuote(data.SessionID),
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// formatForceCommandArgs renders the ForceCommandArgs templates with the connection metadata. The values are not
+// quoted since the arguments are not interpreted by a shell.
+func formatForceCommandArgs(args []string, data forceCommandData) ([]string, error) {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		var err error
+		if result[i], err = formatTemplate(arg, data); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// formatTemplate renders a template with the connection metadata without quoting the values.
+func formatTemplate(text string, data forceCommandData) (string, error) {
+	tpl, err := parseForceCommand(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// joinArgs joins the arguments into a command line for the shell.
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// forcedCommand is a forced command configured either as a command line template or as argument templates.
+type forcedCommand struct {
+	command string
+	args    []string
+}
+
+func (f forcedCommand) set() bool {
+	return f.command != "" || len(f.args) > 0
+}
+
+// line returns the forced command as a command line.
+func (f forcedCommand) line() string {
+	if len(f.args) > 0 {
+		return joinArgs(f.args)
+	}
+	return f.command
+}
+
+// forcedFor returns the forced command for the exec, shell or subsystem request type. The forced command of the
+// section takes precedence over the global one.
+func (c Config) forcedFor(requestType string) forcedCommand {
+	var section forcedCommand
+	switch requestType {
+	case "exec":
+		section = forcedCommand{c.Command.ForceCommand, c.Command.ForceCommandArgs}
+	case "shell":
+		section = forcedCommand{c.Shell.ForceCommand, c.Shell.ForceCommandArgs}
+	case "subsystem":
+		section = forcedCommand{c.Subsystem.ForceCommand, c.Subsystem.ForceCommandArgs}
+	}
+	if section.set() {
+		return section
+	}
+	return forcedCommand{c.ForceCommand, c.ForceCommandArgs}
+}
+
+// forcedCommand returns the global forced command as a command line.
+func (c Config) forcedCommand() string {
+	return forcedCommand{c.ForceCommand, c.ForceCommandArgs}.line()
+}
+
+// validateForceCommand validates a ForceCommand and ForceCommandArgs pair in the specified section.
+func validateForceCommand(v *validator, path string, forceCommand string, forceCommandArgs []string) {
+	if tpl, err := parseForceCommand(forceCommand); err != nil {
+		v.check(field(path, "forceCommand"), ValidationCodeInvalidTemplate, err)
+	} else {
+		v.check(field(path, "forceCommand"), ValidationCodeInvalidTemplate, checkPlaceholderQuoting(tpl))
+	}
+	if forceCommand != "" && len(forceCommandArgs) > 0 {
+		v.fail(
+			field(path, "forceCommandArgs"),
+			ValidationCodeInvalidFormat,
+			"forceCommand and forceCommandArgs cannot be set at the same time",
+		)
+	}
+	if len(forceCommandArgs) > 0 && forceCommandArgs[0] == "" {
+		v.fail(index(field(path, "forceCommandArgs"), 0), ValidationCodeInvalidFormat, "no program specified")
+	}
+	for i, arg := range forceCommandArgs {
+		if _, err := parseForceCommand(arg); err != nil {
+			v.check(index(field(path, "forceCommandArgs"), i), ValidationCodeInvalidTemplate, err)
+		}
+	}
+}
+
+// ExecArgsHandler is an optional interface for session channel backends. Backends implementing it receive
+// ForceCommandArgs as an argument vector and must execute it without a shell.
+type ExecArgsHandler interface {
+	// OnExecArgs executes the program with the specified arguments, the first argument being the program.
+	OnExecArgs(requestID uint64, args []string) error
+}
+
+// templateData returns the connection metadata of the session for templates.
+func (s *sessionHandler) templateData() forceCommandData {
+	return forceCommandData{
+		Username:     s.sshConnection.username,
+		RemoteIP:     s.sshConnection.remoteIP,
+		ConnectionID: s.sshConnection.connectionID,
+		SessionID:    s.sshConnection.connectionID + "-" + strconv.FormatUint(s.channelID, 10),
+	}
+}
+
+// execForceCommand executes the forced command instead of the requested program.
+func (s *sessionHandler) execForceCommand(requestID uint64, forced forcedCommand) error {
+	data := s.templateData()
+	if len(forced.args) > 0 {
+		args, err := formatForceCommandArgs(forced.args, data)
+		if err != nil {
+			return fmt.Errorf("failed to execute command")
+		}
+		s.approveProgram(joinArgs(args))
+		if err := s.applySecurityContext(requestID, "exec"); err != nil {
+			return err
+		}
+		if argsHandler, ok := s.backend.(ExecArgsHandler); ok {
+			return argsHandler.OnExecArgs(requestID, args)
+		}
+		return s.backend.OnExecRequest(requestID, joinArgs(args))
+	}
+	command, err := formatForceCommand(forced.command, data)
+	if err != nil {
+		return fmt.Errorf("failed to execute command")
+	}
+	s.approveProgram(command)
+	if err := s.applySecurityContext(requestID, "exec"); err != nil {
+		return err
+	}
+	return s.backend.OnExecRequest(requestID, command)
+}
@@ -0,0 +1,105 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForceCommandTemplate(t *testing.T) {
+	backend := &dummyBackend{}
+	session := &sessionHandler{
+		config: Config{
+			ForceCommand: "/usr/local/bin/wrapper --user {{ .Username }} --from {{ .RemoteIP }} --session {{ .SessionID }}",
+		},
+		backend:   backend,
+		channelID: 3,
+		sshConnection: &sshConnectionHandler{
+			connectionID: "0123456789abcdef",
+			remoteIP:     "192.0.2.1",
+			username:     "o'brien",
+			lock:         &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnShell(1))
+	assert.Equal(t, []string{
+		`/usr/local/bin/wrapper --user 'o'\''brien' --from '192.0.2.1' --session '0123456789abcdef-3'`,
+	}, backend.commandsExecuted)
+
+	assert.Error(t, Config{ForceCommand: "{{ .Username"}.Validate())
+	assert.Error(t, Config{ForceCommand: `/bin/echo "{{ .Username }}"`}.Validate())
+	assert.Error(t, Config{ForceCommand: "/bin/echo --user='{{ .Username }}'"}.Validate())
+	assert.Error(t, Config{ForceCommand: `/bin/echo \{{ .Username }}`}.Validate())
+	assert.Error(t, Config{ForceCommand: "/bin/echo {{ .Unknown }}"}.Validate())
+	assert.NoError(t, Config{ForceCommand: `/bin/echo "it's" --user={{ .Username }} 'x\'`}.Validate())
+	assert.NoError(t, Config{ForceCommand: `/bin/echo '{{ "{{" }}' {{ .Username }}`}.Validate())
+	_, err := formatForceCommand("{{ .Hostname }}", forceCommandData{})
+	assert.Error(t, err)
+}
+
+type argsBackend struct {
+	dummyBackend
+	args [][]string
+}
+
+func (a *argsBackend) OnExecArgs(_ uint64, args []string) error {
+	a.args = append(a.args, args)
+	return nil
+}
+
+func TestForceCommandArgs(t *testing.T) {
+	config := Config{
+		ForceCommandArgs: []string{"/usr/local/bin/wrapper", "--user", "{{ .Username }}"},
+	}
+	connection := &sshConnectionHandler{
+		username: "o'brien",
+		lock:     &sync.Mutex{},
+	}
+
+	backend := &argsBackend{}
+	session := &sessionHandler{config: config, backend: backend, sshConnection: connection}
+	assert.NoError(t, session.OnShell(1))
+	assert.Equal(t, [][]string{{"/usr/local/bin/wrapper", "--user", "o'brien"}}, backend.args)
+	assert.Empty(t, backend.commandsExecuted)
+
+	fallbackBackend := &dummyBackend{}
+	session = &sessionHandler{config: config, backend: fallbackBackend, sshConnection: connection}
+	assert.NoError(t, session.OnExecRequest(1, "id"))
+	assert.Equal(t, []string{`'/usr/local/bin/wrapper' '--user' 'o'\''brien'`}, fallbackBackend.commandsExecuted)
+
+	assert.Error(t, Config{ForceCommand: "/bin/true", ForceCommandArgs: []string{"/bin/true"}}.Validate())
+	assert.Error(t, Config{ForceCommandArgs: []string{""}}.Validate())
+	assert.Error(t, Config{ForceCommandArgs: []string{"/bin/echo", "{{ .Username"}}.Validate())
+}
+
+func TestForceCommandPerRequestType(t *testing.T) {
+	config := Config{
+		ForceCommand: "/usr/local/bin/logger",
+		Shell:        ShellConfig{ForceCommandArgs: []string{"/usr/local/bin/menu"}},
+		Subsystem:    SubsystemConfig{ForceCommand: "/usr/lib/openssh/sftp-server"},
+	}
+	backend := &dummyBackend{}
+	session := &sessionHandler{
+		config:  config,
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnExecRequest(1, "id"))
+	assert.NoError(t, session.OnShell(2))
+	assert.NoError(t, session.OnSubsystem(3, "sftp"))
+	assert.Equal(t, []string{
+		"/usr/local/bin/logger",
+		"'/usr/local/bin/menu'",
+		"/usr/lib/openssh/sftp-server",
+	}, backend.commandsExecuted)
+
+	evaluator, err := NewEvaluator(Config{Shell: ShellConfig{ForceCommand: "/usr/local/bin/menu"}})
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionReasonForceCommandOverride, evaluator.EvaluateShell().Reason)
+	assert.NotEqual(t, DecisionReasonForceCommandOverride, evaluator.EvaluateSubsystem("sftp").Reason)
+
+	assert.Error(t, Config{Shell: ShellConfig{ForceCommand: "{{ .Username"}}.Validate())
+}
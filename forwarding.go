@@ -0,0 +1,309 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// addressRule is a parsed host:ports entry of an allow or deny list.
+type addressRule struct {
+	// network is set if the host is an IP address or a CIDR range.
+	network *net.IPNet
+	// hostname is set if the host is a hostname. It is empty if any host matches.
+	hostname string
+	minPort  uint32
+	maxPort  uint32
+}
+
+// parseAddressRule parses a rule in the form of host, host:ports, [ipv6] or [ipv6]:ports. The host may be an IP
+// address, a CIDR range, a hostname or *, ports may be a single port or a range such as 8000-9000.
+func parseAddressRule(rule string) (addressRule, error) {
+	host := rule
+	ports := ""
+	switch {
+	case strings.HasPrefix(rule, "["):
+		end := strings.Index(rule, "]")
+		if end < 0 {
+			return addressRule{}, fmt.Errorf("missing ] in address rule: %s", rule)
+		}
+		host = rule[1:end]
+		rest := rule[end+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return addressRule{}, fmt.Errorf("invalid address rule: %s", rule)
+			}
+			ports = rest[1:]
+		}
+	case strings.Count(rule, ":") == 1:
+		separator := strings.LastIndex(rule, ":")
+		host = rule[:separator]
+		ports = rule[separator+1:]
+	}
+	result := addressRule{minPort: 0, maxPort: 65535}
+	if ports != "" {
+		var err error
+		if result.minPort, result.maxPort, err = parsePortRange(ports); err != nil {
+			return addressRule{}, fmt.Errorf("invalid ports in address rule %s (%w)", rule, err)
+		}
+	}
+	switch {
+	case host == "":
+		return addressRule{}, fmt.Errorf("missing host in address rule: %s", rule)
+	case host == "*":
+	case strings.Contains(host, "/"):
+		_, network, err := net.ParseCIDR(host)
+		if err != nil {
+			return addressRule{}, fmt.Errorf("invalid CIDR range in address rule %s (%w)", rule, err)
+		}
+		result.network = network
+	case net.ParseIP(host) != nil:
+		ip := net.ParseIP(host)
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		result.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	case strings.ContainsAny(host, "*: \t"):
+		return addressRule{}, fmt.Errorf("invalid host in address rule: %s", rule)
+	default:
+		result.hostname = strings.ToLower(strings.TrimSuffix(host, "."))
+	}
+	return result, nil
+}
+
+// parsePortRange parses a single port or a range of ports separated by a dash.
+func parsePortRange(ports string) (uint32, uint32, error) {
+	parts := strings.SplitN(ports, "-", 2)
+	minPort, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port: %s", parts[0])
+	}
+	maxPort := minPort
+	if len(parts) == 2 {
+		if maxPort, err = strconv.ParseUint(parts[1], 10, 16); err != nil {
+			return 0, 0, fmt.Errorf("invalid port: %s", parts[1])
+		}
+	}
+	if minPort > maxPort {
+		return 0, 0, fmt.Errorf("invalid port range: %s", ports)
+	}
+	return uint32(minPort), uint32(maxPort), nil
+}
+
+// match returns true if the host and port are covered by the rule.
+func (a addressRule) match(host string, port uint32) bool {
+	if port < a.minPort || port > a.maxPort {
+		return false
+	}
+	if a.network != nil {
+		ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"))
+		return ip != nil && a.network.Contains(ip)
+	}
+	if a.hostname != "" {
+		return a.hostname == strings.ToLower(strings.TrimSuffix(host, "."))
+	}
+	return true
+}
+
+// matchAddressRules returns true if the host and port are covered by any of the rules. Invalid rules are ignored,
+// they are reported when the configuration is validated.
+func matchAddressRules(rules []string, host string, port uint32) bool {
+	for _, rule := range rules {
+		parsed, err := parseAddressRule(rule)
+		if err != nil {
+			continue
+		}
+		if parsed.match(host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// directTCPIPPayload is the extra data of a direct-tcpip channel as described in RFC 4254 section 7.2.
+type directTCPIPPayload struct {
+	Host           string
+	Port           uint32
+	OriginatorHost string
+	OriginatorPort uint32
+}
+
+// EvaluateForwarding evaluates a local port forwarding request to the specified destination.
+func (e *Evaluator) EvaluateForwarding(host string, port uint32) Decision {
+	payload := map[string]string{"host": host, "port": strconv.FormatUint(uint64(port), 10)}
+	return e.decide("direct-tcpip", payload, e.config.Forwarding.Mode, func(mode ExecutionPolicy) Decision {
+		return e.evaluateForwarding(mode, host, port)
+	})
+}
+
+func (e *Evaluator) evaluateForwarding(mode ExecutionPolicy, host string, port uint32) Decision {
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "port forwarding is disabled")
+	case ExecutionPolicyFilter:
+		if matchAddressRules(e.config.Forwarding.Allow, host, port) {
+			return allow(mode, DecisionReasonInAllowList, "the destination is on the allow list")
+		}
+		return deny(mode, DecisionReasonNotInAllowList, "the destination is not on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		if matchAddressRules(e.config.Forwarding.Deny, host, port) {
+			return deny(mode, DecisionReasonMatchedDenyList, "the destination is on the deny list")
+		}
+		return allow(mode, DecisionReasonModeEnabled, "port forwarding is enabled")
+	}
+}
+
+// allowForwarding decides whether a direct-tcpip channel is passed to the backend. Channels with a malformed
+// payload are rejected.
+func (s *sshConnectionHandler) allowForwarding(channelID uint64, extraData []byte) bool {
+	payload := directTCPIPPayload{}
+	if err := ssh.Unmarshal(extraData, &payload); err != nil {
+		return false
+	}
+	evaluator := &Evaluator{config: s.config, username: s.username, hooks: s.hooks}
+	decision := evaluator.EvaluateForwarding(payload.Host, payload.Port)
+	if !decision.Allowed {
+		return false
+	}
+	return s.auditPayload(decision.Mode, channelID, "direct-tcpip", map[string]string{
+		"host":           payload.Host,
+		"port":           strconv.FormatUint(uint64(payload.Port), 10),
+		"originatorHost": payload.OriginatorHost,
+		"originatorPort": strconv.FormatUint(uint64(payload.OriginatorPort), 10),
+	}) == nil
+}
+
+// tcpipForwardPayload is the payload of a tcpip-forward global request as described in RFC 4254 section 7.1.
+type tcpipForwardPayload struct {
+	Address string
+	Port    uint32
+}
+
+// isWildcardAddress returns true if the bind address listens on all interfaces.
+func isWildcardAddress(address string) bool {
+	switch address {
+	case "":
+		fallthrough
+	case "*":
+		fallthrough
+	case "0.0.0.0":
+		return true
+	default:
+		ip := net.ParseIP(address)
+		return ip != nil && ip.IsUnspecified()
+	}
+}
+
+// EvaluateReverseForwarding evaluates a remote port forwarding request binding the specified address and port.
+func (e *Evaluator) EvaluateReverseForwarding(address string, port uint32) Decision {
+	payload := map[string]string{"address": address, "port": strconv.FormatUint(uint64(port), 10)}
+	return e.decide("tcpip-forward", payload, e.config.ReverseForwarding.Mode, func(mode ExecutionPolicy) Decision {
+		return e.evaluateReverseForwarding(mode, address, port)
+	})
+}
+
+func (e *Evaluator) evaluateReverseForwarding(mode ExecutionPolicy, address string, port uint32) Decision {
+	config := e.config.ReverseForwarding
+	if mode != ExecutionPolicyDisable && config.ForbidWildcard && isWildcardAddress(address) {
+		return deny(mode, DecisionReasonWildcardBind, "binding all interfaces is not allowed")
+	}
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "remote port forwarding is disabled")
+	case ExecutionPolicyFilter:
+		if matchAddressRules(config.Allow, address, port) {
+			return allow(mode, DecisionReasonInAllowList, "the bind address is on the allow list")
+		}
+		return deny(mode, DecisionReasonNotInAllowList, "the bind address is not on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		if matchAddressRules(config.Deny, address, port) {
+			return deny(mode, DecisionReasonMatchedDenyList, "the bind address is on the deny list")
+		}
+		return allow(mode, DecisionReasonModeEnabled, "remote port forwarding is enabled")
+	}
+}
+
+// allowReverseForwarding decides whether a tcpip-forward request is passed to the backend. Requests with a malformed
+// payload are rejected.
+func (s *sshConnectionHandler) allowReverseForwarding(requestID uint64, payload []byte) bool {
+	request := tcpipForwardPayload{}
+	if err := ssh.Unmarshal(payload, &request); err != nil {
+		return false
+	}
+	evaluator := &Evaluator{config: s.config, username: s.username, hooks: s.hooks}
+	decision := evaluator.EvaluateReverseForwarding(request.Address, request.Port)
+	if !decision.Allowed {
+		return false
+	}
+	return s.auditPayload(decision.Mode, requestID, "tcpip-forward", map[string]string{
+		"address": request.Address,
+		"port":    strconv.FormatUint(uint64(request.Port), 10),
+	}) == nil
+}
+
+// streamLocalPayload is the beginning of the payload of direct-streamlocal@openssh.com channels and
+// streamlocal-forward@openssh.com requests. Both start with the socket path, the remaining fields are ignored.
+type streamLocalPayload struct {
+	SocketPath string
+	Rest       []byte `ssh:"rest"`
+}
+
+// EvaluateStreamLocal evaluates a Unix domain socket forwarding request of the specified type (e.g.
+// direct-streamlocal@openssh.com) for the socket path.
+func (e *Evaluator) EvaluateStreamLocal(requestType string, socketPath string) Decision {
+	payload := map[string]string{"socketPath": socketPath}
+	return e.decide(requestType, payload, e.config.StreamLocalForwarding.Mode, func(mode ExecutionPolicy) Decision {
+		return e.evaluateStreamLocal(mode, path.Clean(socketPath))
+	})
+}
+
+func (e *Evaluator) evaluateStreamLocal(mode ExecutionPolicy, socketPath string) Decision {
+	config := e.config.StreamLocalForwarding
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "Unix domain socket forwarding is disabled")
+	case ExecutionPolicyFilter:
+		if e.config.patterns.matchList(config.MatchMode, config.Allow, socketPath) {
+			return allow(mode, DecisionReasonInAllowList, "the socket is on the allow list")
+		}
+		return deny(mode, DecisionReasonNotInAllowList, "the socket is not on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		if e.config.patterns.matchList(config.MatchMode, config.Deny, socketPath) {
+			return deny(mode, DecisionReasonMatchedDenyList, "the socket is on the deny list")
+		}
+		return allow(mode, DecisionReasonModeEnabled, "Unix domain socket forwarding is enabled")
+	}
+}
+
+// allowStreamLocal decides whether a Unix domain socket forwarding channel or request is passed to the backend.
+// Channels and requests with a malformed payload are rejected.
+func (s *sshConnectionHandler) allowStreamLocal(id uint64, requestType string, payload []byte) bool {
+	request := streamLocalPayload{}
+	if err := ssh.Unmarshal(payload, &request); err != nil {
+		return false
+	}
+	evaluator := &Evaluator{config: s.config, username: s.username, hooks: s.hooks}
+	decision := evaluator.EvaluateStreamLocal(requestType, request.SocketPath)
+	if !decision.Allowed {
+		return false
+	}
+	return s.auditPayload(decision.Mode, id, requestType, map[string]string{"socketPath": request.SocketPath}) == nil
+}
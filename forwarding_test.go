@@ -0,0 +1,133 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestForwarding(t *testing.T) {
+	backend := &channelBackend{}
+	connection := &sshConnectionHandler{
+		config: Config{
+			Forwarding: ForwardingConfig{
+				Mode:  ExecutionPolicyFilter,
+				Allow: []string{"10.0.0.0/8:5432", "db.internal:5432"},
+			},
+		},
+		backend: backend,
+		lock:    &sync.Mutex{},
+	}
+	assert.NoError(t, connection.config.Validate())
+
+	forward := func(channelID uint64, host string, port uint32) {
+		connection.OnUnsupportedChannel(channelID, "direct-tcpip", ssh.Marshal(directTCPIPPayload{
+			Host: host,
+			Port: port,
+		}))
+	}
+	forward(1, "10.1.2.3", 5432)
+	forward(2, "10.1.2.3", 22)
+	forward(3, "192.168.0.1", 5432)
+	forward(4, "DB.internal", 5432)
+	connection.OnUnsupportedChannel(5, "direct-tcpip", []byte{1})
+	assert.Equal(t, []string{"direct-tcpip", "direct-tcpip"}, backend.channels)
+}
+
+func TestEvaluateForwarding(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		Forwarding: ForwardingConfig{
+			Deny: []string{"169.254.169.254", "*:25", "[fd00::/8]:8000-9000"},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.True(t, evaluator.EvaluateForwarding("example.com", 443).Allowed)
+	assert.Equal(t, DecisionReasonMatchedDenyList, evaluator.EvaluateForwarding("169.254.169.254", 80).Reason)
+	assert.Equal(t, DecisionReasonMatchedDenyList, evaluator.EvaluateForwarding("example.com", 25).Reason)
+	assert.False(t, evaluator.EvaluateForwarding("fd00::1", 8080).Allowed)
+	assert.True(t, evaluator.EvaluateForwarding("fd00::1", 22).Allowed)
+}
+
+func TestForwardingValidation(t *testing.T) {
+	for _, rule := range []string{"10.0.0.0/8:5432", "10.0.0.1", "[::1]:22", "fd00::/8", "*:8000-9000", "db:1"} {
+		assert.NoError(t, ForwardingConfig{Allow: []string{rule}}.Validate(), rule)
+	}
+	for _, rule := range []string{"", "10.0.0.0/33", "host:99999", "host:9-8", "[::1", "*.example.com:22", ":22"} {
+		assert.Error(t, ForwardingConfig{Allow: []string{rule}}.Validate(), rule)
+	}
+}
+
+func TestReverseForwarding(t *testing.T) {
+	backend := &globalRequestBackend{}
+	connection := &sshConnectionHandler{
+		config: Config{
+			ReverseForwarding: ReverseForwardingConfig{
+				Mode:           ExecutionPolicyFilter,
+				Allow:          []string{"127.0.0.1:8000-9000", "*:2222"},
+				ForbidWildcard: true,
+			},
+		},
+		backend: backend,
+		lock:    &sync.Mutex{},
+	}
+	assert.NoError(t, connection.config.Validate())
+
+	bind := func(requestID uint64, address string, port uint32) {
+		connection.OnUnsupportedGlobalRequest(requestID, "tcpip-forward", ssh.Marshal(tcpipForwardPayload{
+			Address: address,
+			Port:    port,
+		}))
+	}
+	bind(1, "127.0.0.1", 8080)
+	bind(2, "127.0.0.1", 22)
+	bind(3, "0.0.0.0", 2222)
+	bind(4, "", 2222)
+	bind(5, "::", 2222)
+	bind(6, "localhost", 2222)
+	connection.OnUnsupportedGlobalRequest(7, "tcpip-forward", []byte{})
+	assert.Equal(t, []string{"tcpip-forward", "tcpip-forward"}, backend.requests)
+
+	evaluator, err := NewEvaluator(connection.config)
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionReasonWildcardBind, evaluator.EvaluateReverseForwarding("0.0.0.0", 2222).Reason)
+}
+
+func TestStreamLocalForwarding(t *testing.T) {
+	channels := &channelBackend{}
+	requests := &globalRequestBackend{}
+	connection := &sshConnectionHandler{
+		config: Config{
+			StreamLocalForwarding: StreamLocalConfig{
+				MatchMode: MatchModeGlob,
+				Deny:      []string{"/var/run/docker.sock", "/run/*"},
+			},
+		},
+		backend: channels,
+		lock:    &sync.Mutex{},
+	}
+	assert.NoError(t, connection.config.Validate())
+
+	socket := func(socketPath string) []byte {
+		return ssh.Marshal(struct {
+			SocketPath string
+			Reserved   string
+			Port       uint32
+		}{SocketPath: socketPath})
+	}
+	connection.OnUnsupportedChannel(1, "direct-streamlocal@openssh.com", socket("/var/run/docker.sock"))
+	connection.OnUnsupportedChannel(2, "direct-streamlocal@openssh.com", socket("/var/run/../run/docker.sock"))
+	connection.OnUnsupportedChannel(3, "direct-streamlocal@openssh.com", socket("/tmp/app.sock"))
+	assert.Equal(t, []string{"direct-streamlocal@openssh.com"}, channels.channels)
+
+	connection.backend = requests
+	connection.OnUnsupportedGlobalRequest(4, "streamlocal-forward@openssh.com", ssh.Marshal(struct {
+		SocketPath string
+	}{"/run/user/1000/agent.sock"}))
+	connection.OnUnsupportedGlobalRequest(5, "streamlocal-forward@openssh.com", ssh.Marshal(struct {
+		SocketPath string
+	}{"/home/foo/agent.sock"}))
+	assert.Equal(t, []string{"streamlocal-forward@openssh.com"}, requests.requests)
+}
@@ -6,5 +6,5 @@ require (
 	github.com/containerssh/sshserver v0.9.16
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
-	golang.org/x/sys v0.0.0-20210113181707-4bcb84eeeb78 // indirect
+	golang.org/x/sys v0.0.0-20210113181707-4bcb84eeeb78
 )
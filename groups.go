@@ -0,0 +1,54 @@
+package security
+
+import (
+	"fmt"
+)
+
+// GroupResolver determines the groups of a user for the policies in Groups, e.g. from a static map or a directory
+// service such as LDAP.
+type GroupResolver interface {
+	// Groups returns the groups of the user. The overrides of the groups are applied in the returned order, later
+	// groups take precedence. If an error is returned the connection is rejected.
+	Groups(username string) ([]string, error)
+}
+
+// StaticGroupResolver is a GroupResolver backed by a map from usernames to their groups.
+type StaticGroupResolver map[string][]string
+
+// Groups returns the groups of the user from the map.
+func (s StaticGroupResolver) Groups(username string) ([]string, error) {
+	return s[username], nil
+}
+
+// ErrGroupResolutionFailed indicates that the groups of the user could not be determined.
+type ErrGroupResolutionFailed struct {
+	Cause error
+}
+
+// Error contains the error for the logs.
+func (e *ErrGroupResolutionFailed) Error() string {
+	return fmt.Sprintf("failed to resolve groups (%v)", e.Cause)
+}
+
+// Unwrap returns the error returned by the GroupResolver.
+func (e *ErrGroupResolutionFailed) Unwrap() error {
+	return e.Cause
+}
+
+// resolveGroups returns the groups of the user, or none if no resolver is set or no group policies are configured.
+func resolveGroups(resolver GroupResolver, config Config, username string) ([]string, error) {
+	if resolver == nil || len(config.Groups) == 0 {
+		return nil, nil
+	}
+	groups, err := resolver.Groups(username)
+	if err != nil {
+		return nil, &ErrGroupResolutionFailed{Cause: err}
+	}
+	return groups, nil
+}
+
+func (h *handler) SetGroupResolver(resolver GroupResolver) {
+	h.configLock.Lock()
+	defer h.configLock.Unlock()
+	h.groupResolver = resolver
+}
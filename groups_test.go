@@ -0,0 +1,65 @@
+package security
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroups(t *testing.T) {
+	config := Config{
+		DefaultMode:         ExecutionPolicyDisable,
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: -1,
+		Groups: map[string]Config{
+			"sftp-users": {
+				Subsystem: SubsystemConfig{Mode: ExecutionPolicyFilter, Allow: []string{"sftp"}},
+			},
+			"admins": {
+				Shell:     ShellConfig{Mode: ExecutionPolicyEnable},
+				Subsystem: SubsystemConfig{Mode: ExecutionPolicyEnable},
+			},
+		},
+		Users: map[string]Config{
+			"bob": {Shell: ShellConfig{Mode: ExecutionPolicyDisable}},
+		},
+	}
+	evaluator, err := NewEvaluator(config)
+	assert.NoError(t, err)
+	sftp := evaluator.WithGroups("sftp-users")
+	assert.True(t, sftp.EvaluateSubsystem("sftp").Allowed)
+	assert.False(t, sftp.EvaluateSubsystem("netconf").Allowed)
+	assert.False(t, sftp.EvaluateShell().Allowed)
+	admin := evaluator.WithGroups("sftp-users", "admins")
+	assert.True(t, admin.EvaluateSubsystem("netconf").Allowed)
+	assert.True(t, admin.EvaluateShell().Allowed)
+	assert.False(t, admin.WithUsername("bob").EvaluateShell().Allowed)
+	assert.True(t, admin.WithUsername("bob").EvaluateSubsystem("netconf").Allowed)
+
+	h, err := NewHandler(config, &dummyHandler{})
+	assert.NoError(t, err)
+	h.SetGroupResolver(StaticGroupResolver{"alice": {"admins"}})
+	client := net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+	network, err := h.OnNetworkConnection(client, "1")
+	assert.NoError(t, err)
+	connection, err := network.OnHandshakeSuccess("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, ExecutionPolicyEnable, connection.(*sshConnectionHandler).config.Shell.Mode)
+	connection, err = network.OnHandshakeSuccess("mallory")
+	assert.NoError(t, err)
+	assert.Equal(t, ExecutionPolicyUnconfigured, connection.(*sshConnectionHandler).config.Shell.Mode)
+
+	h.SetGroupResolver(failingGroupResolver{})
+	network, err = h.OnNetworkConnection(client, "2")
+	assert.NoError(t, err)
+	_, err = network.OnHandshakeSuccess("alice")
+	assert.IsType(t, &ErrGroupResolutionFailed{}, err)
+}
+
+type failingGroupResolver struct{}
+
+func (f failingGroupResolver) Groups(_ string) ([]string, error) {
+	return nil, errors.New("directory unavailable")
+}
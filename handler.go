@@ -0,0 +1,82 @@
+package security
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/containerssh/sshserver"
+)
+
+type handler struct {
+	config        Config
+	configLock    *sync.RWMutex
+	store         StateStore
+	backend       sshserver.Handler
+	ipConnections *counterStore
+	ipRate        *rateLimiter
+	userRate      *rateLimiter
+	firstSeen     *firstSeenStore
+	sampler       *sampler
+	groupResolver GroupResolver
+	canonicalizer UserCanonicalizer
+	*sessionTracker
+	*decisionHooks
+}
+
+func (h *handler) OnReady() error {
+	return h.backend.OnReady()
+}
+
+func (h *handler) OnShutdown(shutdownContext context.Context) {
+	h.backend.OnShutdown(shutdownContext)
+}
+
+func (h *handler) OnNetworkConnection(
+	client net.TCPAddr,
+	connectionID string,
+) (sshserver.NetworkConnectionHandler, error) {
+	h.configLock.RLock()
+	config := h.config
+	sampler := h.sampler
+	groupResolver := h.groupResolver
+	canonicalizer := h.canonicalizer
+	h.configLock.RUnlock()
+	ipKey := aggregateIP(client, config.IPv4Aggregation, config.IPv6Aggregation)
+	if !h.ipRate.allow(ipKey) {
+		return nil, &ErrRateLimited{}
+	}
+	if !h.ipConnections.increment(ipKey, config.MaxConnectionsPerIP) {
+		return nil, &ErrTooManyConnections{}
+	}
+	backend, err := h.backend.OnNetworkConnection(client, connectionID)
+	if err != nil {
+		h.ipConnections.decrement(ipKey)
+		return nil, err
+	}
+	return &networkHandler{
+		config:        config,
+		backend:       backend,
+		connectionID:  connectionID,
+		remoteIP:      client.IP.String(),
+		userRate:      h.userRate,
+		firstSeen:     h.firstSeen,
+		tracker:       h.sessionTracker,
+		hooks:         h.decisionHooks,
+		sampler:       sampler,
+		groupResolver: groupResolver,
+		canonicalizer: canonicalizer,
+		onDisconnect: func() {
+			h.ipConnections.decrement(ipKey)
+		},
+	}, nil
+}
+
+// ErrTooManyConnections indicates that too many connections were opened from the same IP address or network.
+type ErrTooManyConnections struct {
+}
+
+// Error contains the error for the logs.
+func (e *ErrTooManyConnections) Error() string {
+	return "too many connections"
+}
@@ -1,7 +1,7 @@
 package security
 
 import (
-	"fmt"
+	"sync"
 
 	"github.com/containerssh/sshserver"
 )
@@ -12,11 +12,72 @@ func New(
 	config Config,
 	backend sshserver.NetworkConnectionHandler,
 ) (sshserver.NetworkConnectionHandler, error) {
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid security configuration (%w)", err)
+	config, err := config.prepareForServer()
+	if err != nil {
+		return nil, err
 	}
 	return &networkHandler{
 		config:  config,
 		backend: backend,
+		sampler: newSampler(config.Sampling),
+	}, nil
+}
+
+// Handler is a security proxy on the server handler level.
+type Handler interface {
+	sshserver.Handler
+	SessionTracker
+	DecisionHooks
+
+	// Reload replaces the configuration for new connections. The new configuration is validated and self-tested
+	// before it is put into effect. If any of the checks fail an error is returned and the current configuration
+	// stays in effect. Connections that are already open keep the configuration they were opened with.
+	Reload(config Config) error
+
+	// SetGroupResolver sets the resolver determining the groups of users for the policies in Groups. Without a
+	// resolver Groups is not applied. Connections that are already open keep the groups they were opened with.
+	SetGroupResolver(resolver GroupResolver)
+
+	// SetUserCanonicalizer sets the canonicalizer applied to usernames before the policy is resolved. Without a
+	// canonicalizer usernames are used as sent by the client. Connections that are already open keep the username
+	// they were opened with.
+	SetUserCanonicalizer(canonicalizer UserCanonicalizer)
+}
+
+// NewHandler creates a new security proxy on the server handler level. In addition to the features provided by New
+// this enables limits spanning multiple network connections, such as MaxConnectionsPerIP, and terminating sessions.
+//goland:noinspection GoUnusedExportedFunction
+func NewHandler(
+	config Config,
+	backend sshserver.Handler,
+) (Handler, error) {
+	return NewHandlerWithStateStore(config, backend, NewMemoryStateStore())
+}
+
+// NewHandlerWithStateStore creates a new security proxy on the server handler level like NewHandler, but keeps the
+// connection counters and first-seen times in the specified store. Rate limits and sessions are always tracked in
+// memory.
+//goland:noinspection GoUnusedExportedFunction
+func NewHandlerWithStateStore(
+	config Config,
+	backend sshserver.Handler,
+	store StateStore,
+) (Handler, error) {
+	config, err := config.prepareForServer()
+	if err != nil {
+		return nil, err
+	}
+	return &handler{
+		config:         config,
+		configLock:     &sync.RWMutex{},
+		store:          store,
+		backend:        backend,
+		ipConnections:  newCounterStore(store, "connections/ip/"),
+		ipRate:         newRateLimiter(config.ConnectionRate, config.ConnectionRate.PerIP),
+		userRate:       newRateLimiter(config.ConnectionRate, config.ConnectionRate.PerUser),
+		firstSeen:      newFirstSeenStore(store),
+		sampler:        newSampler(config.Sampling),
+		sessionTracker: newSessionTracker(config),
+		decisionHooks:  newDecisionHooks(),
 	}, nil
 }
@@ -3,13 +3,27 @@ package security
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/containerssh/sshserver"
 )
 
 type networkHandler struct {
-	config  Config
-	backend sshserver.NetworkConnectionHandler
+	config        Config
+	backend       sshserver.NetworkConnectionHandler
+	connectionID  string
+	remoteIP      string
+	userRate      *rateLimiter
+	firstSeen     *firstSeenStore
+	tracker       *sessionTracker
+	hooks         *decisionHooks
+	sampler       *sampler
+	onDisconnect  func()
+	groupResolver GroupResolver
+	canonicalizer UserCanonicalizer
+	// publicKeys are the fingerprints of all public keys accepted on this connection.
+	publicKeys []string
+	authMethod AuthMethod
 }
 
 func (n *networkHandler) OnAuthKeyboardInteractive(
@@ -19,10 +33,15 @@ func (n *networkHandler) OnAuthKeyboardInteractive(
 		questions sshserver.KeyboardInteractiveQuestions,
 	) (answers sshserver.KeyboardInteractiveAnswers, err error),
 ) (response sshserver.AuthResponse, reason error) {
-	return n.backend.OnAuthKeyboardInteractive(
+	response, reason = n.backend.OnAuthKeyboardInteractive(
 		user,
 		challenge,
 	)
+	if response == sshserver.AuthResponseSuccess {
+		n.publicKeys = nil
+		n.authMethod = AuthMethodKeyboardInteractive
+	}
+	return response, reason
 }
 
 func (n *networkHandler) OnShutdown(shutdownContext context.Context) {
@@ -33,11 +52,23 @@ func (n *networkHandler) OnAuthPassword(username string, password []byte) (
 	response sshserver.AuthResponse,
 	reason error,
 ) {
-	return n.backend.OnAuthPassword(username, password)
+	response, reason = n.backend.OnAuthPassword(username, password)
+	if response == sshserver.AuthResponseSuccess {
+		n.publicKeys = nil
+		n.authMethod = AuthMethodPassword
+	}
+	return response, reason
 }
 
 func (n *networkHandler) OnAuthPubKey(username string, pubKey string) (response sshserver.AuthResponse, reason error) {
-	return n.backend.OnAuthPubKey(username, pubKey)
+	response, reason = n.backend.OnAuthPubKey(username, pubKey)
+	// The key is also passed for queries that are not signed by the client, and keys accepted once are not passed
+	// again when the client signs with them, so any of the accepted keys may be the one the client authenticates with.
+	if response == sshserver.AuthResponseSuccess {
+		n.publicKeys = append(n.publicKeys, fingerprint(pubKey))
+		n.authMethod = AuthMethodPublicKey
+	}
+	return response, reason
 }
 
 func (n *networkHandler) OnHandshakeFailed(reason error) {
@@ -48,17 +79,69 @@ func (n *networkHandler) OnHandshakeSuccess(username string) (
 	connection sshserver.SSHConnectionHandler,
 	failureReason error,
 ) {
-	backend, failureReason := n.backend.OnHandshakeSuccess(username)
+	clientUsername := username
+	username, err := canonicalize(n.canonicalizer, username)
+	if err != nil {
+		return nil, err
+	}
+	if n.userRate != nil && !n.userRate.allow(username) {
+		return nil, &ErrRateLimited{}
+	}
+	groups, err := resolveGroups(n.groupResolver, n.config, username)
+	if err != nil {
+		return nil, err
+	}
+	config := n.config.forPrincipal(username, groups, n.authMethod, func(quarantine QuarantineConfig) bool {
+		if n.firstSeen == nil {
+			return false
+		}
+		principals := []string{"user:" + username}
+		for _, publicKey := range n.publicKeys {
+			if publicKey != "" {
+				principals = append(principals, "key:"+publicKey)
+			}
+		}
+		return n.firstSeen.inQuarantine(quarantine, principals, time.Now())
+	})
+	notice := ""
+	if recertificationExpired(n.config.Recertification, username, time.Now()) {
+		switch n.config.Recertification.Action {
+		case RecertificationActionRestrict:
+			config = restrictToSFTP(config)
+		case RecertificationActionWarn:
+			notice = recertificationWarning
+		default:
+			return nil, &ErrRecertificationExpired{}
+		}
+	}
+	backend, failureReason := n.backend.OnHandshakeSuccess(clientUsername)
 	if failureReason != nil {
 		return nil, failureReason
 	}
-	return &sshConnectionHandler{
-		config:  n.config,
-		backend: backend,
-		lock:    &sync.Mutex{},
-	}, nil
+	sshConnection := &sshConnectionHandler{
+		config:       config,
+		backend:      backend,
+		connectionID: n.connectionID,
+		remoteIP:     n.remoteIP,
+		username:     username,
+		notice:       notice,
+		hooks:        n.hooks,
+		sampler:      n.sampler,
+		lock:         &sync.Mutex{},
+		sleep:        time.Sleep,
+	}
+	if n.tracker != nil {
+		n.tracker.register(n.connectionID, sshConnection)
+	}
+	return sshConnection, nil
 }
 
 func (n *networkHandler) OnDisconnect() {
+	if n.tracker != nil {
+		n.tracker.unregister(n.connectionID)
+	}
 	n.backend.OnDisconnect()
+	if n.onDisconnect != nil {
+		n.onDisconnect()
+	}
 }
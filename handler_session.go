@@ -2,7 +2,10 @@ package security
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/containerssh/sshserver"
 )
@@ -10,19 +13,46 @@ import (
 type sessionHandler struct {
 	config        Config
 	backend       sshserver.SessionChannelHandler
+	channelID     uint64
+	channel       *backendChannel
 	sshConnection *sshConnectionHandler
+	pty           bool
+	envCount      int
+
+	approvedEnv     map[string]string
+	approvedProgram string
+	programApproved bool
 }
 
 func (s *sessionHandler) OnClose() {
+	if s.channel != nil {
+		s.channel.exit()
+	}
+	s.sshConnection.onSessionClosed(s.channelID)
 	s.backend.OnClose()
 }
 
-func (s *sessionHandler) OnShutdown(shutdownContext context.Context) {
-	s.backend.OnShutdown(shutdownContext)
+// terminate writes the notice to the standard error of the session, records the termination in the audit log and
+// closes the channel.
+func (s *sessionHandler) terminate(notice string, reason TerminationReason) {
+	if s.channel == nil {
+		return
+	}
+	if auditHandler, ok := s.backend.(AuditHandler); ok {
+		// The session is closed even if the record is rejected, the termination can't be undone by the backend.
+		_ = auditHandler.OnAuditedRequest(AuditedRequest{
+			SchemaVersion: AuditSchemaVersion,
+			Username:      s.sshConnection.username,
+			RequestType:   "terminate",
+			Payload:       map[string]string{"reason": string(reason)},
+		})
+	}
+	_, _ = s.channel.Stderr().Write([]byte(notice))
+	_ = s.channel.Close()
 }
 
-func (s *sessionHandler) OnUnsupportedChannelRequest(requestID uint64, requestType string, payload []byte) {
-	s.backend.OnUnsupportedChannelRequest(requestID, requestType, payload)
+func (s *sessionHandler) OnShutdown(shutdownContext context.Context) {
+	s.backend.OnShutdown(shutdownContext)
 }
 
 func (s *sessionHandler) OnFailedDecodeChannelRequest(
@@ -34,14 +64,19 @@ func (s *sessionHandler) OnFailedDecodeChannelRequest(
 	s.backend.OnFailedDecodeChannelRequest(requestID, requestType, payload, reason)
 }
 
-func (s *sessionHandler) getPolicy(primary ExecutionPolicy) ExecutionPolicy {
-	if primary != ExecutionPolicyUnconfigured {
-		return primary
-	}
-	if s.config.DefaultMode != ExecutionPolicyUnconfigured {
-		return s.config.DefaultMode
+// deny returns a rejection error for the client after padding the response time, see padDenial.
+func (s *sessionHandler) deny(start time.Time, message string) error {
+	s.sshConnection.padDenial(start)
+	return errors.New(message)
+}
+
+// evaluator returns the policy evaluator for this session.
+func (s *sessionHandler) evaluator() *Evaluator {
+	return &Evaluator{
+		config:   s.config,
+		username: s.sshConnection.username,
+		hooks:    s.sshConnection.hooks,
 	}
-	return ExecutionPolicyEnable
 }
 
 func (s *sessionHandler) contains(items []string, item string) bool {
@@ -53,24 +88,122 @@ func (s *sessionHandler) contains(items []string, item string) bool {
 	return false
 }
 
-func (s *sessionHandler) OnEnvRequest(requestID uint64, name string, value string) error {
-	mode := s.getPolicy(s.config.Env.Mode)
-	switch mode {
-	case ExecutionPolicyDisable:
-		return fmt.Errorf("environment variable rejected")
-	case ExecutionPolicyFilter:
-		if s.contains(s.config.Env.Allow, name) {
-			return s.backend.OnEnvRequest(requestID, name, value)
+// hardenedEnvironment contains the variables forced for every program when Env.HardenEnvironment is enabled.
+var hardenedEnvironment = []struct {
+	name  string
+	value string
+}{
+	{"PATH", "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+	{"IFS", " \t\n"},
+	{"SHELLOPTS", ""},
+	{"BASH_ENV", ""},
+	{"ENV", ""},
+}
+
+// dangerousEnvironment contains the variables rejected unless Env.AllowDangerous is set. Entries ending in * match
+// all variables with that prefix.
+var dangerousEnvironment = []string{
+	"LD_*",
+	"DYLD_*",
+	"BASH_ENV",
+	"ENV",
+	"BASH_FUNC_*",
+	"SHELLOPTS",
+	"BASHOPTS",
+	"IFS",
+	"PS4",
+	"PROMPT_COMMAND",
+	"GCONV_PATH",
+	"HOSTALIASES",
+	"LOCALDOMAIN",
+	"RES_OPTIONS",
+	"NLSPATH",
+	"MALLOC_*",
+	"GLIBC_TUNABLES",
+	"PYTHONSTARTUP",
+	"PYTHONPATH",
+	"PYTHONHOME",
+	"PYTHONINSPECT",
+	"PERL5LIB",
+	"PERL5OPT",
+	"PERLLIB",
+	"RUBYLIB",
+	"RUBYOPT",
+	"NODE_OPTIONS",
+	"NODE_PATH",
+	"JAVA_TOOL_OPTIONS",
+	"_JAVA_OPTIONS",
+	"GIT_SSH",
+	"GIT_SSH_COMMAND",
+	"GIT_EXEC_PATH",
+	"GIT_CONFIG_GLOBAL",
+	"GIT_CONFIG_SYSTEM",
+}
+
+// dangerousEnvironmentPatterns holds the compiled patterns of dangerousEnvironment.
+var dangerousEnvironmentPatterns = newPatternSet()
+
+func isDangerousEnv(name string) bool {
+	return dangerousEnvironmentPatterns.matchList(MatchModeGlob, dangerousEnvironment, name)
+}
+
+func isHardenedEnv(name string) bool {
+	for _, env := range hardenedEnvironment {
+		if env.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotice writes the connection notice, if any, to the standard error of the session before a program is started.
+func (s *sessionHandler) writeNotice() {
+	if s.sshConnection.notice == "" || s.channel == nil {
+		return
+	}
+	_, _ = s.channel.Stderr().Write([]byte(s.sshConnection.notice))
+}
+
+func (s *sessionHandler) applyForcedEnv(requestID uint64) error {
+	if s.config.Env.HardenEnvironment {
+		for _, env := range hardenedEnvironment {
+			if err := s.setEnv(requestID, env.name, env.value); err != nil {
+				return fmt.Errorf("failed to set up environment")
+			}
+		}
+	}
+	names := make([]string, 0, len(s.config.Env.Set))
+	for name := range s.config.Env.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	data := s.templateData()
+	for _, name := range names {
+		value, err := formatTemplate(s.config.Env.Set[name], data)
+		if err != nil {
+			return fmt.Errorf("failed to set up environment")
 		}
-		return fmt.Errorf("environment variable rejected")
-	case ExecutionPolicyEnable:
-		fallthrough
-	default:
-		if !s.contains(s.config.Env.Deny, name) {
-			return s.backend.OnEnvRequest(requestID, name, value)
+		if err := s.setEnv(requestID, name, value); err != nil {
+			return fmt.Errorf("failed to set up environment")
 		}
-		return fmt.Errorf("environment variable rejected")
 	}
+	return nil
+}
+
+func (s *sessionHandler) OnEnvRequest(requestID uint64, name string, value string) error {
+	start := time.Now()
+	s.envCount++
+	if s.config.Env.MaxCount > 0 && s.envCount > s.config.Env.MaxCount {
+		return s.deny(start, "too many environment variables")
+	}
+	decision := s.evaluator().EvaluateEnv(name, value)
+	if !decision.Allowed {
+		return s.deny(start, "environment variable rejected")
+	}
+	if err := s.audit(decision.Mode, requestID, "env", map[string]string{"name": name, "value": value}); err != nil {
+		return s.deny(start, "environment variable rejected")
+	}
+	return s.setEnv(requestID, name, value)
 }
 
 func (s *sessionHandler) OnPtyRequest(
@@ -82,108 +215,162 @@ func (s *sessionHandler) OnPtyRequest(
 	height uint32,
 	modeList []byte,
 ) error {
-	mode := s.getPolicy(s.config.TTY.Mode)
-	switch mode {
-	case ExecutionPolicyDisable:
-		return fmt.Errorf("TTY request rejected")
-	case ExecutionPolicyFilter:
-		return fmt.Errorf("TTY request rejected")
-	case ExecutionPolicyEnable:
-		fallthrough
-	default:
-		return s.backend.OnPtyRequest(requestID, term, columns, rows, width, height, modeList)
+	start := time.Now()
+	decision := s.evaluator().EvaluatePTY(term)
+	if !decision.Allowed {
+		return s.deny(start, "TTY request rejected")
+	}
+	if err := s.audit(decision.Mode, requestID, "pty", map[string]string{"term": term}); err != nil {
+		return s.deny(start, "TTY request rejected")
+	}
+	if err := s.backend.OnPtyRequest(requestID, term, columns, rows, width, height, modeList); err != nil {
+		return err
 	}
+	s.pty = true
+	return nil
 }
 
 func (s *sessionHandler) OnExecRequest(
 	requestID uint64,
 	program string,
 ) error {
-	mode := s.getPolicy(s.config.Command.Mode)
-	switch mode {
-	case ExecutionPolicyDisable:
-		return fmt.Errorf("command execution rejected")
-	case ExecutionPolicyFilter:
-		if !s.contains(s.config.Command.Allow, program) {
-			return fmt.Errorf("command execution rejected")
-		}
-	case ExecutionPolicyEnable:
-		fallthrough
-	default:
+	start := time.Now()
+	decision := s.evaluator().EvaluateExec(program)
+	if !decision.Allowed {
+		return s.deny(start, "command execution rejected")
+	}
+	mode := decision.Mode
+	payload := map[string]string{"command": program, "commandHash": CommandHash(program)}
+	if err := s.audit(mode, requestID, "exec", payload); err != nil {
+		return s.deny(start, "command execution rejected")
+	}
+	if err := s.prompt(mode); err != nil {
+		return s.deny(start, "command execution rejected")
+	}
+	s.writeNotice()
+	if err := s.applyForcedEnv(requestID); err != nil {
+		return err
 	}
-	if s.config.ForceCommand == "" {
+	if err := s.applyEgressPolicy(requestID); err != nil {
+		return err
+	}
+	forced := s.config.forcedFor("exec")
+	if !forced.set() {
+		program = s.config.Command.rewrite(s.config.rsyncCommand(program))
+		s.approveProgram(program)
+		if err := s.applySecurityContext(requestID, "exec"); err != nil {
+			return err
+		}
 		return s.backend.OnExecRequest(requestID, program)
 	}
-	if err := s.backend.OnEnvRequest(requestID, "SSH_ORIGINAL_COMMAND", program); err != nil {
+	if err := s.setEnv(requestID, "SSH_ORIGINAL_COMMAND", program); err != nil {
 		return fmt.Errorf("failed to execute command")
 	}
-	return s.backend.OnExecRequest(requestID, s.config.ForceCommand)
+	return s.execForceCommand(requestID, forced)
 }
 
 func (s *sessionHandler) OnShell(
 	requestID uint64,
 ) error {
-	mode := s.getPolicy(s.config.Shell.Mode)
-	switch mode {
-	case ExecutionPolicyDisable:
-		return fmt.Errorf("shell execution rejected")
-	case ExecutionPolicyFilter:
-		return fmt.Errorf("shell execution rejected")
-	case ExecutionPolicyEnable:
-		fallthrough
-	default:
-	}
-	if s.config.ForceCommand == "" {
+	start := time.Now()
+	decision := s.evaluator().EvaluateShell()
+	if !decision.Allowed {
+		return s.deny(start, "shell execution rejected")
+	}
+	mode := decision.Mode
+	if err := s.audit(mode, requestID, "shell", map[string]string{}); err != nil {
+		return s.deny(start, "shell execution rejected")
+	}
+	if err := s.prompt(mode); err != nil {
+		return s.deny(start, "shell execution rejected")
+	}
+	s.writeSummary()
+	s.writeNotice()
+	if err := s.applyForcedEnv(requestID); err != nil {
+		return err
+	}
+	if err := s.applyEgressPolicy(requestID); err != nil {
+		return err
+	}
+	forced := s.config.forcedFor("shell")
+	if !forced.set() {
+		s.approveProgram("")
+		if err := s.applySecurityContext(requestID, "shell"); err != nil {
+			return err
+		}
 		return s.backend.OnShell(requestID)
 	}
-	return s.backend.OnExecRequest(requestID, s.config.ForceCommand)
+	return s.execForceCommand(requestID, forced)
 }
 
 func (s *sessionHandler) OnSubsystem(
 	requestID uint64,
 	subsystem string,
 ) error {
-	mode := s.getPolicy(s.config.Subsystem.Mode)
-	switch mode {
-	case ExecutionPolicyDisable:
-		return fmt.Errorf("subsystem execution rejected")
-	case ExecutionPolicyFilter:
-		if !s.contains(s.config.Subsystem.Allow, subsystem) {
-			return fmt.Errorf("subsystem execution rejected")
+	start := time.Now()
+	decision := s.evaluator().EvaluateSubsystem(subsystem)
+	if !decision.Allowed {
+		return s.deny(start, "subsystem execution rejected")
+	}
+	mode := decision.Mode
+	if err := s.audit(mode, requestID, "subsystem", map[string]string{"subsystem": subsystem}); err != nil {
+		return s.deny(start, "subsystem execution rejected")
+	}
+	if err := s.prompt(mode); err != nil {
+		return s.deny(start, "subsystem execution rejected")
+	}
+	s.writeNotice()
+	if err := s.applyForcedEnv(requestID); err != nil {
+		return err
+	}
+	if err := s.applyEgressPolicy(requestID); err != nil {
+		return err
+	}
+	forced := s.config.forcedFor("subsystem")
+	if !forced.set() {
+		if subsystem == "sftp" {
+			sftp, err := s.config.sftpFor(s.sshConnection.username)
+			if err != nil {
+				return s.deny(start, "subsystem execution rejected")
+			}
+			if sftp.enabled() {
+				if err := s.filterSFTP(sftp); err != nil {
+					return err
+				}
+			}
 		}
-	case ExecutionPolicyEnable:
-		if s.contains(s.config.Subsystem.Deny, subsystem) {
-			return fmt.Errorf("subsystem execution rejected")
+		s.approveProgram(subsystem)
+		if err := s.applySecurityContext(requestID, "subsystem"); err != nil {
+			return err
 		}
-	default:
-	}
-	if s.config.ForceCommand == "" {
 		return s.backend.OnSubsystem(requestID, subsystem)
 	}
-	if err := s.backend.OnEnvRequest(requestID, "SSH_ORIGINAL_COMMAND", subsystem); err != nil {
+	if err := s.setEnv(requestID, "SSH_ORIGINAL_COMMAND", subsystem); err != nil {
 		return fmt.Errorf("failed to execute command")
 	}
-	return s.backend.OnExecRequest(requestID, s.config.ForceCommand)
+	return s.execForceCommand(requestID, forced)
 }
 
 func (s *sessionHandler) OnSignal(requestID uint64, signal string) error {
-	mode := s.getPolicy(s.config.Shell.Mode)
-	switch mode {
-	case ExecutionPolicyDisable:
-		return fmt.Errorf("signal rejected")
-	case ExecutionPolicyFilter:
-		if s.contains(s.config.Signal.Allow, signal) {
-			return s.backend.OnSignal(requestID, signal)
-		}
-		return fmt.Errorf("signal rejected")
-	case ExecutionPolicyEnable:
-		fallthrough
-	default:
-		if !s.contains(s.config.Signal.Deny, signal) {
-			return s.backend.OnSignal(requestID, signal)
-		}
-		return fmt.Errorf("signal rejected")
+	start := time.Now()
+	decision := s.evaluator().EvaluateSignal(signal)
+	if !decision.Allowed {
+		return s.deny(start, "signal rejected")
+	}
+	translated := s.translateSignal(normalizeSignal(signal))
+	if err := s.audit(
+		decision.Mode,
+		requestID,
+		"signal",
+		map[string]string{"signal": signal, "delivered": translated},
+	); err != nil {
+		return s.deny(start, "signal rejected")
+	}
+	if err := s.backend.OnSignal(requestID, translated); err != nil {
+		return err
 	}
+	s.escalate(decision.Mode, requestID, translated)
+	return nil
 }
 
 func (s *sessionHandler) OnWindow(requestID uint64, columns uint32, rows uint32, width uint32, height uint32) error {
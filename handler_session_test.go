@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -171,6 +172,85 @@ func TestSubsystem(t *testing.T) {
 	assert.Equal(t, map[string]string{"SSH_ORIGINAL_COMMAND": "sftp"}, backend.env)
 }
 
+func TestHardenEnvironment(t *testing.T) {
+	backend := &dummyBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Env: EnvConfig{
+				HardenEnvironment: true,
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+
+	backend.env = map[string]string{}
+	assert.Error(t, session.OnEnvRequest(1, "PATH", "/tmp"))
+	assert.Error(t, session.OnEnvRequest(2, "BASH_ENV", "/tmp/evil"))
+	assert.Error(t, session.OnEnvRequest(3, "ENV", "/tmp/evil"))
+	assert.NoError(t, session.OnEnvRequest(4, "LANG", "C"))
+	assert.Equal(t, map[string]string{"LANG": "C"}, backend.env)
+
+	assert.NoError(t, session.OnShell(5))
+	assert.Equal(t, "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin", backend.env["PATH"])
+	assert.Equal(t, " \t\n", backend.env["IFS"])
+	assert.Equal(t, "", backend.env["SHELLOPTS"])
+	assert.Equal(t, "", backend.env["BASH_ENV"])
+	assert.Equal(t, "", backend.env["ENV"])
+	assert.Equal(t, "C", backend.env["LANG"])
+}
+
+func TestDenyDelay(t *testing.T) {
+	config := Config{
+		MaxSessions: -1,
+		DenyDelay: DenyDelayConfig{
+			Min: time.Hour,
+		},
+		Channels: map[string]ChannelConfig{
+			"direct-tcpip": {Mode: ExecutionPolicyDisable},
+		},
+		GlobalRequests: map[string]RequestConfig{
+			"tcpip-forward": {Mode: ExecutionPolicyDisable},
+		},
+		Requests: map[string]RequestConfig{
+			"break": {Mode: ExecutionPolicyDisable},
+		},
+	}
+	var delays int
+	connection := &sshConnectionHandler{
+		config:  config,
+		backend: &dummySSHBackend{exitChannel: make(chan struct{})},
+		lock:    &sync.Mutex{},
+		sleep: func(delay time.Duration) {
+			assert.Greater(t, int64(delay), int64(59*time.Minute))
+			delays++
+		},
+	}
+	channel, err := connection.OnSessionChannel(1, []byte{}, &sessionChannel{})
+	assert.Nil(t, err)
+	session := channel.(*sessionHandler)
+
+	assert.NoError(t, session.OnExecRequest(1, "/bin/bash"))
+	assert.Equal(t, 0, delays)
+
+	session.config.Command.Mode = ExecutionPolicyDisable
+	assert.Error(t, session.OnExecRequest(2, "/bin/bash"))
+	assert.Equal(t, 1, delays)
+
+	session.OnUnsupportedChannelRequest(3, "break", []byte{})
+	assert.Equal(t, 2, delays)
+	connection.OnUnsupportedChannel(2, "direct-tcpip", []byte{})
+	assert.Equal(t, 3, delays)
+	connection.OnUnsupportedGlobalRequest(1, "tcpip-forward", []byte{})
+	assert.Equal(t, 4, delays)
+	connection.config.MaxSessions = 1
+	_, err = connection.OnSessionChannel(3, []byte{}, &sessionChannel{})
+	assert.NotNil(t, err)
+	assert.Equal(t, 5, delays)
+}
+
 // region Dummy backend
 type dummyBackend struct {
 	exit             chan struct{}
@@ -260,3 +340,33 @@ func (d *dummyBackend) OnWindow(_ uint64, _ uint32, _ uint32, _ uint32, _ uint32
 }
 
 // endregion
+
+func TestEnvSet(t *testing.T) {
+	backend := &dummyBackend{env: map[string]string{}}
+	session := &sessionHandler{
+		config: Config{
+			Env: EnvConfig{
+				HardenEnvironment: true,
+				Set: map[string]string{
+					"AUDIT_SESSION": "{{ .SessionID }}",
+					"SSH_POLICY_ID": "restricted",
+					"PATH":          "/opt/bin",
+				},
+			},
+		},
+		backend:   backend,
+		channelID: 2,
+		sshConnection: &sshConnectionHandler{
+			connectionID: "abc",
+			lock:         &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnEnvRequest(1, "SSH_POLICY_ID", "unrestricted"))
+	assert.NoError(t, session.OnShell(2))
+	assert.Equal(t, "abc-2", backend.env["AUDIT_SESSION"])
+	assert.Equal(t, "restricted", backend.env["SSH_POLICY_ID"])
+	assert.Equal(t, "/opt/bin", backend.env["PATH"])
+
+	assert.Error(t, EnvConfig{Set: map[string]string{"A=B": "c"}}.Validate())
+	assert.Error(t, EnvConfig{Set: map[string]string{"A": "{{ .Username"}}.Validate())
+}
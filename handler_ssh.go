@@ -2,51 +2,179 @@ package security
 
 import (
 	"context"
+	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/containerssh/sshserver"
 	"golang.org/x/crypto/ssh"
 )
 
 type sshConnectionHandler struct {
-	config       Config
-	backend      sshserver.SSHConnectionHandler
-	sessionCount uint
-	lock         *sync.Mutex
+	config        Config
+	backend       sshserver.SSHConnectionHandler
+	connectionID  string
+	remoteIP      string
+	username      string
+	notice        string
+	sessionCount  uint
+	sessions      map[uint64]*sessionHandler
+	terminated    bool
+	channelCounts map[string]uint
+	channelRates  map[string]*rateLimiter
+	hooks         *decisionHooks
+	sampler       *sampler
+	lock          *sync.Mutex
+	// sleep waits for the DenyDelay of rejected requests. It defaults to time.Sleep.
+	sleep func(time.Duration)
 }
 
 func (s *sshConnectionHandler) OnShutdown(shutdownContext context.Context) {
 	s.backend.OnShutdown(shutdownContext)
 }
 
+// padDenial delays a rejection to DenyDelay.Min since start plus a random jitter, so the response time doesn't reveal
+// which check rejected the request. All rejecting handlers call it.
+func (s *sshConnectionHandler) padDenial(start time.Time) {
+	delay := s.config.DenyDelay.Min - time.Since(start)
+	if s.config.DenyDelay.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(s.config.DenyDelay.Jitter)))
+	}
+	if delay <= 0 {
+		return
+	}
+	if s.sleep == nil {
+		time.Sleep(delay)
+		return
+	}
+	s.sleep(delay)
+}
+
 func (s *sshConnectionHandler) OnUnsupportedGlobalRequest(requestID uint64, requestType string, payload []byte) {
+	start := time.Now()
+	if !s.allowGlobalRequest(requestID, requestType, payload) {
+		s.padDenial(start)
+		return
+	}
 	s.backend.OnUnsupportedGlobalRequest(requestID, requestType, payload)
 }
 
+// allowGlobalRequest returns true if the backend should be notified of the global request.
+func (s *sshConnectionHandler) allowGlobalRequest(requestID uint64, requestType string, payload []byte) bool {
+	config := s.config.GlobalRequests[requestType]
+	mode := s.config.effectivePolicy(config.Mode, s.config.Defaults.GlobalRequests)
+	if !allowRequest(mode, config, payload) {
+		return false
+	}
+	if err := s.audit(mode, requestID, requestType, payload); err != nil {
+		return false
+	}
+	// The SSH server rejects all global requests, the policies only decide if the backend is notified.
+	if requestType == "tcpip-forward" && !s.allowReverseForwarding(requestID, payload) {
+		return false
+	}
+	if requestType == "streamlocal-forward@openssh.com" && !s.allowStreamLocal(requestID, requestType, payload) {
+		return false
+	}
+	return true
+}
+
 func (s *sshConnectionHandler) OnUnsupportedChannel(channelID uint64, channelType string, extraData []byte) {
+	start := time.Now()
+	if !s.allowChannel(channelID, channelType, extraData) {
+		s.padDenial(start)
+		return
+	}
 	s.backend.OnUnsupportedChannel(channelID, channelType, extraData)
 }
 
+// allowChannel returns true if the backend should be notified of the channel.
+func (s *sshConnectionHandler) allowChannel(channelID uint64, channelType string, extraData []byte) bool {
+	if rejection := s.openChannel(channelType); rejection != nil {
+		return false
+	}
+	// The SSH server rejects all channels other than session channels, the policies only decide if the backend is
+	// notified.
+	if channelType == "direct-tcpip" && !s.allowForwarding(channelID, extraData) {
+		return false
+	}
+	if channelType == "direct-streamlocal@openssh.com" && !s.allowStreamLocal(channelID, channelType, extraData) {
+		return false
+	}
+	return true
+}
+
 func (s *sshConnectionHandler) OnSessionChannel(
 	channelID uint64,
 	extraData []byte,
 	session sshserver.SessionChannel,
 ) (channel sshserver.SessionChannelHandler, failureReason sshserver.ChannelRejection) {
+	start := time.Now()
+	channel, failureReason = s.openSessionChannel(channelID, extraData, session)
+	if failureReason != nil {
+		s.padDenial(start)
+	}
+	return channel, failureReason
+}
+
+func (s *sshConnectionHandler) openSessionChannel(
+	channelID uint64,
+	extraData []byte,
+	session sshserver.SessionChannel,
+) (sshserver.SessionChannelHandler, sshserver.ChannelRejection) {
+	if rejection := s.openChannel("session"); rejection != nil {
+		return nil, rejection
+	}
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	if s.terminated {
+		s.releaseChannelLocked("session")
+		return nil, &ErrSessionTerminated{}
+	}
 	if s.config.MaxSessions > -1 && s.sessionCount >= uint(s.config.MaxSessions) {
+		s.releaseChannelLocked("session")
 		return nil, &ErrTooManySessions{}
 	}
-	backend, err := s.backend.OnSessionChannel(channelID, extraData, session)
+	proxyChannel := newBackendChannel(session)
+	backend, err := s.backend.OnSessionChannel(channelID, extraData, proxyChannel)
 	if err != nil {
+		s.releaseChannelLocked("session")
 		return nil, err
 	}
 	s.sessionCount++
-	return &sessionHandler{
+	handler := &sessionHandler{
 		config:        s.config,
 		backend:       backend,
+		channelID:     channelID,
+		channel:       proxyChannel,
 		sshConnection: s,
-	}, nil
+	}
+	proxyChannel.session = handler
+	if s.sessions == nil {
+		s.sessions = map[uint64]*sessionHandler{}
+	}
+	s.sessions[channelID] = handler
+	return handler, nil
+}
+
+func (s *sshConnectionHandler) onSessionClosed(channelID uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.sessions, channelID)
+}
+
+// terminate writes the notice to all open sessions, closes them and prevents new sessions from being opened.
+func (s *sshConnectionHandler) terminate(notice string, reason TerminationReason) {
+	s.lock.Lock()
+	s.terminated = true
+	sessions := make([]*sessionHandler, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.lock.Unlock()
+	for _, session := range sessions {
+		session.terminate(notice, reason)
+	}
 }
 
 // ErrTooManySessions indicates that too many sessions were opened in the same connection.
@@ -0,0 +1,127 @@
+package security
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/containerssh/sshserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxConnectionsPerIP(t *testing.T) {
+	h, err := NewHandler(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: 2,
+		IPv4Aggregation:     24,
+	}, &dummyHandler{})
+	assert.NoError(t, err)
+
+	client1 := net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+	client2 := net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 2222}
+	client3 := net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 2222}
+
+	connection1, err := h.OnNetworkConnection(client1, "1")
+	assert.NoError(t, err)
+	_, err = h.OnNetworkConnection(client2, "2")
+	assert.NoError(t, err)
+	_, err = h.OnNetworkConnection(client1, "3")
+	assert.Error(t, err)
+	_, err = h.OnNetworkConnection(client3, "4")
+	assert.NoError(t, err)
+
+	connection1.OnDisconnect()
+	_, err = h.OnNetworkConnection(client1, "5")
+	assert.NoError(t, err)
+}
+
+func TestAggregateIP(t *testing.T) {
+	for _, testCase := range []struct {
+		ip       string
+		zone     string
+		ipv4     int
+		ipv6     int
+		expected string
+	}{
+		{"192.0.2.1", "", 0, 0, "192.0.2.1"},
+		{"192.0.2.1", "", 32, 0, "192.0.2.1"},
+		{"192.0.2.1", "", 24, 0, "192.0.2.0/24"},
+		{"::ffff:192.0.2.1", "", 24, 64, "192.0.2.0/24"},
+		{"2001:db8::1", "", 24, 0, "2001:db8::1"},
+		{"2001:db8::1", "", 24, 128, "2001:db8::1"},
+		{"2001:db8:0:1:2:3:4:5", "", 0, 64, "2001:db8:0:1::/64"},
+		{"2001:db8:0:1:2:3:4:5", "", 0, 48, "2001:db8::/48"},
+		{"fe80::1", "eth0", 0, 0, "fe80::1%eth0"},
+		{"fe80::1", "eth1", 0, 64, "fe80::/64%eth1"},
+	} {
+		assert.Equal(
+			t,
+			testCase.expected,
+			aggregateIP(net.TCPAddr{IP: net.ParseIP(testCase.ip), Zone: testCase.zone}, testCase.ipv4, testCase.ipv6),
+		)
+	}
+}
+
+func TestMaxConnectionsPerIPv6(t *testing.T) {
+	h, err := NewHandler(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: 1,
+		IPv6Aggregation:     64,
+	}, &dummyHandler{})
+	assert.NoError(t, err)
+
+	_, err = h.OnNetworkConnection(net.TCPAddr{IP: net.ParseIP("2001:db8::1")}, "1")
+	assert.NoError(t, err)
+	_, err = h.OnNetworkConnection(net.TCPAddr{IP: net.ParseIP("2001:db8::2")}, "2")
+	assert.Error(t, err)
+	_, err = h.OnNetworkConnection(net.TCPAddr{IP: net.ParseIP("2001:db8:0:1::1")}, "3")
+	assert.NoError(t, err)
+}
+
+type dummyHandler struct {
+}
+
+func (d *dummyHandler) OnReady() error {
+	return nil
+}
+
+func (d *dummyHandler) OnShutdown(_ context.Context) {
+}
+
+func (d *dummyHandler) OnNetworkConnection(_ net.TCPAddr, _ string) (sshserver.NetworkConnectionHandler, error) {
+	return &dummyNetworkBackend{}, nil
+}
+
+type dummyNetworkBackend struct {
+}
+
+func (d *dummyNetworkBackend) OnAuthPassword(_ string, _ []byte) (sshserver.AuthResponse, error) {
+	return sshserver.AuthResponseSuccess, nil
+}
+
+func (d *dummyNetworkBackend) OnAuthPubKey(_ string, _ string) (sshserver.AuthResponse, error) {
+	return sshserver.AuthResponseSuccess, nil
+}
+
+func (d *dummyNetworkBackend) OnAuthKeyboardInteractive(
+	_ string,
+	_ func(
+		instruction string,
+		questions sshserver.KeyboardInteractiveQuestions,
+	) (answers sshserver.KeyboardInteractiveAnswers, err error),
+) (sshserver.AuthResponse, error) {
+	return sshserver.AuthResponseSuccess, nil
+}
+
+func (d *dummyNetworkBackend) OnHandshakeFailed(_ error) {
+}
+
+func (d *dummyNetworkBackend) OnHandshakeSuccess(_ string) (sshserver.SSHConnectionHandler, error) {
+	return &dummySSHBackend{}, nil
+}
+
+func (d *dummyNetworkBackend) OnDisconnect() {
+}
+
+func (d *dummyNetworkBackend) OnShutdown(_ context.Context) {
+}
@@ -0,0 +1,98 @@
+package security
+
+import (
+	"sync"
+)
+
+// DecisionRequest describes the request being evaluated.
+type DecisionRequest struct {
+	// Username is the name of the authenticated user, if known.
+	Username string `json:"username"`
+	// RequestType is the type of the request, e.g. env, pty, exec, shell, subsystem or signal.
+	RequestType string `json:"requestType"`
+	// Payload contains the request parameters, e.g. the name and value of an environment variable.
+	Payload map[string]string `json:"payload"`
+}
+
+// DecisionEvent is a decision in the form written to decision logs, e.g. by an OnAfterDecision hook. The schema is
+// defined in proto/security/v1/events.proto.
+type DecisionEvent struct {
+	// SchemaVersion is the AuditSchemaVersion the event was created with.
+	SchemaVersion int `json:"schemaVersion"`
+	// Request is the evaluated request.
+	Request DecisionRequest `json:"request"`
+	// Decision is the result of the evaluation.
+	Decision Decision `json:"decision"`
+}
+
+// NewDecisionEvent creates a decision event with the current AuditSchemaVersion.
+//goland:noinspection GoUnusedExportedFunction
+func NewDecisionEvent(request DecisionRequest, decision Decision) DecisionEvent {
+	return DecisionEvent{
+		SchemaVersion: AuditSchemaVersion,
+		Request:       request,
+		Decision:      decision,
+	}
+}
+
+// DecisionHooks lets callers observe and veto policy decisions without wrapping the backend.
+type DecisionHooks interface {
+	// OnBeforeDecision registers a hook called before a request is evaluated. If the hook returns an error the request
+	// is denied with the error as the message and the policy is not evaluated.
+	OnBeforeDecision(hook func(request DecisionRequest) error)
+	// OnAfterDecision registers a hook called with the decision after a request has been evaluated.
+	OnAfterDecision(hook func(request DecisionRequest, decision Decision))
+}
+
+// decisionHooks holds the registered hooks. It is shared by all evaluators derived from the same handler.
+type decisionHooks struct {
+	lock   *sync.RWMutex
+	before []func(request DecisionRequest) error
+	after  []func(request DecisionRequest, decision Decision)
+}
+
+func newDecisionHooks() *decisionHooks {
+	return &decisionHooks{
+		lock: &sync.RWMutex{},
+	}
+}
+
+func (d *decisionHooks) OnBeforeDecision(hook func(request DecisionRequest) error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.before = append(d.before, hook)
+}
+
+func (d *decisionHooks) OnAfterDecision(hook func(request DecisionRequest, decision Decision)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.after = append(d.after, hook)
+}
+
+// decide runs the before hooks, evaluates the request and runs the after hooks.
+func (d *decisionHooks) decide(request DecisionRequest, mode ExecutionPolicy, evaluate func() Decision) Decision {
+	if d == nil {
+		return evaluate()
+	}
+	d.lock.RLock()
+	before := d.before
+	after := d.after
+	d.lock.RUnlock()
+
+	var decision Decision
+	vetoed := false
+	for _, hook := range before {
+		if err := hook(request); err != nil {
+			decision = deny(mode, DecisionReasonVetoed, err.Error())
+			vetoed = true
+			break
+		}
+	}
+	if !vetoed {
+		decision = evaluate()
+	}
+	for _, hook := range after {
+		hook(request, decision)
+	}
+	return decision
+}
@@ -0,0 +1,58 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecisionHooks(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{})
+	assert.NoError(t, err)
+
+	var decisions []Decision
+	evaluator.OnBeforeDecision(func(request DecisionRequest) error {
+		if request.RequestType == "exec" && request.Payload["command"] == "/bin/sudo" {
+			return fmt.Errorf("sudo is not permitted")
+		}
+		return nil
+	})
+	evaluator.OnAfterDecision(func(request DecisionRequest, decision Decision) {
+		assert.Equal(t, "foo", request.Username)
+		decisions = append(decisions, decision)
+	})
+
+	userEvaluator := evaluator.WithUsername("foo")
+	assert.True(t, userEvaluator.EvaluateExec("/bin/ls").Allowed)
+	assert.Equal(t, Decision{
+		Allowed: false,
+		Mode:    ExecutionPolicyEnable,
+		Reason:  DecisionReasonVetoed,
+		Message: "sudo is not permitted",
+	}, userEvaluator.EvaluateExec("/bin/sudo"))
+	assert.Len(t, decisions, 2)
+}
+
+func TestHandlerDecisionHooks(t *testing.T) {
+	h, err := NewHandler(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: -1,
+	}, &dummyHandler{})
+	assert.NoError(t, err)
+	var requests []string
+	h.OnAfterDecision(func(request DecisionRequest, decision Decision) {
+		requests = append(requests, request.RequestType)
+	})
+
+	networkConnection, err := h.OnNetworkConnection(net.TCPAddr{IP: net.ParseIP("192.0.2.1")}, "1")
+	assert.NoError(t, err)
+	sshConnection, err := networkConnection.OnHandshakeSuccess("foo")
+	assert.NoError(t, err)
+	session, err := sshConnection.OnSessionChannel(1, []byte{}, &sessionChannel{})
+	assert.NoError(t, err)
+	assert.NoError(t, session.OnEnvRequest(1, "LANG", "C"))
+	assert.NoError(t, session.OnShell(2))
+	assert.Equal(t, []string{"env", "shell"}, requests)
+}
@@ -0,0 +1,30 @@
+package security
+
+import (
+	"net"
+)
+
+// aggregateIP returns the key used to count connections from the client address. Addresses are aggregated to the
+// network with the specified prefix length per address family, 0 disables the aggregation. IPv4-mapped IPv6
+// addresses are treated as IPv4. The zone of link-local addresses is kept since the same address on different
+// interfaces belongs to different hosts.
+func aggregateIP(addr net.TCPAddr, ipv4Prefix int, ipv6Prefix int) string {
+	var key string
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		key = aggregateNetwork(ip4, ipv4Prefix, 32)
+	} else {
+		key = aggregateNetwork(addr.IP.To16(), ipv6Prefix, 128)
+	}
+	if addr.Zone != "" {
+		key += "%" + addr.Zone
+	}
+	return key
+}
+
+func aggregateNetwork(ip net.IP, prefix int, bits int) string {
+	if prefix <= 0 || prefix >= bits {
+		return ip.String()
+	}
+	mask := net.CIDRMask(prefix, bits)
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
@@ -0,0 +1,85 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LegacyActionType is the type of an action of a legacy protocol gateway, e.g. a telnet or serial console server.
+type LegacyActionType string
+
+const (
+	// LegacyActionLogin is an interactive login, e.g. a telnet session. It is mapped onto a pty and a shell request.
+	LegacyActionLogin LegacyActionType = "login"
+
+	// LegacyActionCommand is the execution of a single command, e.g. by a telnet gateway running a command on behalf
+	// of the user. It is mapped onto an exec request.
+	LegacyActionCommand LegacyActionType = "command"
+
+	// LegacyActionSerialConsole is access to a serial console. It is mapped onto a subsystem request for serial/ followed
+	// by the name of the device, e.g. serial/ttyS0, so access can be allowed using subsystem patterns like serial/*.
+	LegacyActionSerialConsole LegacyActionType = "serial-console"
+)
+
+// LegacyAction is an action of a legacy protocol gateway to be evaluated against the policy.
+type LegacyAction struct {
+	// Type is the type of the action.
+	Type LegacyActionType `json:"type"`
+	// Username is the name of the user performing the action, if known.
+	Username string `json:"username,omitempty"`
+	// Terminal is the terminal type of the user for LegacyActionLogin and LegacyActionSerialConsole, if known.
+	Terminal string `json:"terminal,omitempty"`
+	// Command is the command to execute for LegacyActionCommand.
+	Command string `json:"command,omitempty"`
+	// Device is the name of the serial device for LegacyActionSerialConsole, e.g. ttyS0.
+	Device string `json:"device,omitempty"`
+}
+
+// Requests maps the action onto the requests of the transport-independent request model, see Evaluate.
+func (l LegacyAction) Requests() ([]DecisionRequest, error) {
+	request := func(requestType string, payload map[string]string) DecisionRequest {
+		return DecisionRequest{Username: l.Username, RequestType: requestType, Payload: payload}
+	}
+	switch l.Type {
+	case LegacyActionLogin:
+		return []DecisionRequest{
+			request("pty", map[string]string{"term": l.Terminal}),
+			request("shell", map[string]string{}),
+		}, nil
+	case LegacyActionCommand:
+		if l.Command == "" {
+			return nil, fmt.Errorf("no command specified")
+		}
+		return []DecisionRequest{
+			request("exec", map[string]string{"command": l.Command}),
+		}, nil
+	case LegacyActionSerialConsole:
+		device := strings.TrimPrefix(l.Device, "/dev/")
+		if device == "" || strings.Contains(device, "/") {
+			return nil, fmt.Errorf("invalid serial device: %s", l.Device)
+		}
+		return []DecisionRequest{
+			request("pty", map[string]string{"term": l.Terminal}),
+			request("subsystem", map[string]string{"subsystem": "serial/" + device}),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported legacy action: %s", l.Type)
+	}
+}
+
+// EvaluateLegacyAction evaluates the requests the action is mapped onto. The action is permitted if all requests are
+// permitted, the decision of the first denied request or of the last request is returned. The requests pass through
+// the registered DecisionHooks like the requests of SSH sessions.
+func (e *Evaluator) EvaluateLegacyAction(action LegacyAction) (Decision, error) {
+	requests, err := action.Requests()
+	if err != nil {
+		return Decision{}, err
+	}
+	var decision Decision
+	for _, request := range requests {
+		if decision, err = e.Evaluate(request); err != nil || !decision.Allowed {
+			return decision, err
+		}
+	}
+	return decision, nil
+}
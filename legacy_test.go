@@ -0,0 +1,47 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyActions(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		DefaultMode: ExecutionPolicyDisable,
+		TTY:         TTYConfig{Mode: ExecutionPolicyEnable},
+		Subsystem: SubsystemConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"serial/*"},
+		},
+		Users: map[string]Config{
+			"operator": {Shell: ShellConfig{Mode: ExecutionPolicyEnable}},
+		},
+	})
+	assert.NoError(t, err)
+	var requests []string
+	evaluator.OnAfterDecision(func(request DecisionRequest, _ Decision) {
+		requests = append(requests, request.RequestType)
+	})
+
+	decision, err := evaluator.EvaluateLegacyAction(LegacyAction{Type: LegacyActionSerialConsole, Device: "/dev/ttyS0"})
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, []string{"pty", "subsystem"}, requests)
+
+	decision, err = evaluator.EvaluateLegacyAction(LegacyAction{Type: LegacyActionLogin})
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	decision, err = evaluator.EvaluateLegacyAction(LegacyAction{Type: LegacyActionLogin, Username: "operator"})
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = evaluator.EvaluateLegacyAction(LegacyAction{Type: LegacyActionCommand, Command: "show running"})
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	_, err = evaluator.EvaluateLegacyAction(LegacyAction{Type: LegacyActionSerialConsole, Device: "../ttyS0"})
+	assert.Error(t, err)
+	_, err = evaluator.EvaluateLegacyAction(LegacyAction{Type: "ftp"})
+	assert.Error(t, err)
+}
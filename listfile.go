@@ -0,0 +1,242 @@
+package security
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// ListFile references a CSV or TSV file containing allow list entries, one per row in the first column. Rows
+// starting with # are ignored.
+type ListFile struct {
+	// Path is the path of the file.
+	Path string `json:"path" yaml:"path"`
+	// Format is the format of the file. If not set it is determined from the file extension, .tsv files are read as
+	// TSV, everything else as CSV.
+	Format ListFormat `json:"format" yaml:"format" default:""`
+	// Header skips the first row of the file.
+	Header bool `json:"header" yaml:"header"`
+	// Checksum pins the contents of the file in the form sha256:<hex>. If set, the file is rejected if its checksum
+	// doesn't match.
+	Checksum string `json:"checksum" yaml:"checksum"`
+}
+
+// ListFormat is the format of a list file.
+type ListFormat string
+
+const (
+	// ListFormatCSV is a comma-separated file.
+	ListFormatCSV ListFormat = "csv"
+	// ListFormatTSV is a tab-separated file.
+	ListFormatTSV ListFormat = "tsv"
+)
+
+// Validate validates the list format.
+func (l ListFormat) Validate() error {
+	switch l {
+	case "":
+	case ListFormatCSV:
+	case ListFormatTSV:
+	default:
+		return fmt.Errorf("invalid format: %s", l)
+	}
+	return nil
+}
+
+func (l ListFile) validate(v *validator, path string) {
+	if l.Path == "" {
+		v.fail(field(path, "path"), ValidationCodeInvalidFormat, "no path specified")
+	}
+	v.check(field(path, "format"), ValidationCodeInvalidMode, l.Format.Validate())
+	if l.Checksum != "" {
+		if _, err := parseChecksum(l.Checksum); err != nil {
+			v.check(field(path, "checksum"), ValidationCodeInvalidFormat, err)
+		}
+	}
+}
+
+func validateListFiles(v *validator, path string, files []ListFile) {
+	for i, file := range files {
+		file.validate(v, index(path, i))
+	}
+}
+
+func parseChecksum(checksum string) ([]byte, error) {
+	if !strings.HasPrefix(checksum, "sha256:") {
+		return nil, fmt.Errorf("unsupported checksum: %s", checksum)
+	}
+	sum, err := hex.DecodeString(strings.TrimPrefix(checksum, "sha256:"))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("invalid checksum: %s", checksum)
+	}
+	return sum, nil
+}
+
+func (l ListFile) format() ListFormat {
+	if l.Format != "" {
+		return l.Format
+	}
+	if strings.EqualFold(filepath.Ext(l.Path), ".tsv") {
+		return ListFormatTSV
+	}
+	return ListFormatCSV
+}
+
+// load reads the entries of the file, verifying the checksum if set.
+func (l ListFile) load() ([]string, error) {
+	data, err := ioutil.ReadFile(l.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s (%w)", l.Path, err)
+	}
+	if l.Checksum != "" {
+		expected, err := parseChecksum(l.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		actual := sha256.Sum256(data)
+		if !bytes.Equal(expected, actual[:]) {
+			return nil, fmt.Errorf("checksum mismatch for %s", l.Path)
+		}
+	}
+	entries, err := ImportList(bytes.NewReader(data), l.format(), l.Header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s (%w)", l.Path, err)
+	}
+	return entries, nil
+}
+
+// ImportList reads list entries from the first column of a CSV or TSV file. Rows starting with # and empty rows are
+// ignored. If header is true the first row is skipped.
+func ImportList(reader io.Reader, format ListFormat, header bool) ([]string, error) {
+	csvReader := newListReader(reader, format)
+	var entries []string
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header {
+			header = false
+			continue
+		}
+		entry := strings.TrimSpace(record[0])
+		if entry == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// ExportList writes list entries as a CSV or TSV file with a single column. If header is not empty it is written as
+// the first row.
+func ExportList(writer io.Writer, format ListFormat, header string, entries []string) error {
+	csvWriter := csv.NewWriter(writer)
+	if format == ListFormatTSV {
+		csvWriter.Comma = '\t'
+	}
+	if header != "" {
+		if err := csvWriter.Write([]string{header}); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := csvWriter.Write([]string{entry}); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func newListReader(reader io.Reader, format ListFormat) *csv.Reader {
+	csvReader := csv.NewReader(reader)
+	if format == ListFormatTSV {
+		csvReader.Comma = '\t'
+		csvReader.LazyQuotes = true
+	}
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	return csvReader
+}
+
+func loadListFiles(files []ListFile, entries []string) ([]string, error) {
+	if len(files) == 0 {
+		return entries, nil
+	}
+	result := append([]string{}, entries...)
+	for _, file := range files {
+		loaded, err := file.load()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, loaded...)
+	}
+	return result, nil
+}
+
+// loadListFiles returns a copy of the configuration with the entries of all list files added to the allow lists.
+func (c Config) loadListFiles() (Config, error) {
+	var err error
+	if c.Command.Allow, err = loadListFiles(c.Command.AllowFiles, c.Command.Allow); err != nil {
+		return c, fmt.Errorf("failed to load command allow list (%w)", err)
+	}
+	if c.Env.Allow, err = loadListFiles(c.Env.AllowFiles, c.Env.Allow); err != nil {
+		return c, fmt.Errorf("failed to load env allow list (%w)", err)
+	}
+	if c.Subsystem.Allow, err = loadListFiles(c.Subsystem.AllowFiles, c.Subsystem.Allow); err != nil {
+		return c, fmt.Errorf("failed to load subsystem allow list (%w)", err)
+	}
+	if len(c.Keys) > 0 {
+		keys := make(map[string]Config, len(c.Keys))
+		for keyFingerprint, keyConfig := range c.Keys {
+			if keys[keyFingerprint], err = keyConfig.loadListFiles(); err != nil {
+				return c, fmt.Errorf("failed to load lists for key %s (%w)", keyFingerprint, err)
+			}
+		}
+		c.Keys = keys
+	}
+	if len(c.Groups) > 0 {
+		groups := make(map[string]Config, len(c.Groups))
+		for group, groupConfig := range c.Groups {
+			if groups[group], err = groupConfig.loadListFiles(); err != nil {
+				return c, fmt.Errorf("failed to load lists for group %s (%w)", group, err)
+			}
+		}
+		c.Groups = groups
+	}
+	if len(c.Users) > 0 {
+		users := make(map[string]Config, len(c.Users))
+		for username, userConfig := range c.Users {
+			if users[username], err = userConfig.loadListFiles(); err != nil {
+				return c, fmt.Errorf("failed to load lists for user %s (%w)", username, err)
+			}
+		}
+		c.Users = users
+	}
+	if len(c.Profiles) > 0 {
+		profiles := make(map[string]Config, len(c.Profiles))
+		for name, profileConfig := range c.Profiles {
+			if profiles[name], err = profileConfig.loadListFiles(); err != nil {
+				return c, fmt.Errorf("failed to load lists for profile %s (%w)", name, err)
+			}
+		}
+		c.Profiles = profiles
+	}
+	if c.Quarantine.Policy != nil {
+		policy, err := c.Quarantine.Policy.loadListFiles()
+		if err != nil {
+			return c, fmt.Errorf("failed to load lists for the quarantine policy (%w)", err)
+		}
+		c.Quarantine.Policy = &policy
+	}
+	return c, nil
+}
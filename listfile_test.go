@@ -0,0 +1,64 @@
+package security
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportExportList(t *testing.T) {
+	entries, err := ImportList(
+		bytes.NewReader([]byte("command,ticket\n# comment\n/bin/ls,OPS-1\n\n\"/usr/bin/rsync --server, .\",OPS-2\n")),
+		ListFormatCSV,
+		true,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/bin/ls", "/usr/bin/rsync --server, ."}, entries)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ExportList(buf, ListFormatTSV, "command", entries))
+	assert.Equal(t, "command\n/bin/ls\n/usr/bin/rsync --server, .\n", buf.String())
+	imported, err := ImportList(buf, ListFormatTSV, true)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, imported)
+}
+
+func TestListFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "security")
+	assert.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	data := []byte("/bin/ls\n/bin/date\n")
+	file := filepath.Join(dir, "commands.csv")
+	assert.NoError(t, ioutil.WriteFile(file, data, 0600))
+	sum := sha256.Sum256(data)
+
+	config := Config{
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"/bin/true"},
+			AllowFiles: []ListFile{
+				{Path: file, Checksum: "sha256:" + hex.EncodeToString(sum[:])},
+			},
+		},
+	}
+	evaluator, err := NewEvaluator(config)
+	assert.NoError(t, err)
+	assert.True(t, evaluator.EvaluateExec("/bin/true").Allowed)
+	assert.True(t, evaluator.EvaluateExec("/bin/date").Allowed)
+	assert.False(t, evaluator.EvaluateExec("/bin/bash").Allowed)
+
+	config.Command.AllowFiles[0].Checksum = "sha256:" + hex.EncodeToString(make([]byte, sha256.Size))
+	_, err = NewEvaluator(config)
+	assert.Error(t, err)
+
+	config.Command.AllowFiles[0].Checksum = "md5:abc"
+	assert.Error(t, config.Validate())
+}
@@ -0,0 +1,192 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ManifestVersion is the version of the manifest format produced by ExportManifest. It is increased whenever fields
+// are removed or change their meaning.
+const ManifestVersion = 1
+
+// Manifest describes everything a security policy permits in a form intended for compliance scanners and asset
+// inventories.
+type Manifest struct {
+	// Version is the version of the manifest format, see ManifestVersion.
+	Version int `json:"version"`
+	// Fingerprint identifies the configuration the manifest was generated from.
+	Fingerprint string `json:"fingerprint"`
+	// Principal is the principal the manifest was generated for. It is empty for the global policy.
+	Principal ManifestPrincipal `json:"principal"`
+	// Tenant is the tenant the policy belongs to, see Config.Tenant.
+	Tenant string `json:"tenant,omitempty"`
+	// GeneratedAt is the time the manifest was generated.
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Capabilities contains the effective execution policy of the session request types.
+	Capabilities Capabilities `json:"capabilities"`
+	// Rules contains the effective execution policy and the allow and deny lists of each section, keyed by the
+	// configuration key of the section, e.g. env or forwarding.
+	Rules map[string]ManifestRule `json:"rules"`
+	// CommandRules contains the command rules allowing commands in addition to the allow list of the command
+	// section, see CommandConfig.Rules.
+	CommandRules []CommandRule `json:"commandRules,omitempty"`
+	// Channels, Requests and GlobalRequests contain the effective execution policy of the explicitly configured
+	// types. Other types are treated according to Defaults.
+	Channels       map[string]ExecutionPolicy `json:"channels"`
+	Requests       map[string]ExecutionPolicy `json:"requests"`
+	GlobalRequests map[string]ExecutionPolicy `json:"globalRequests"`
+	// Defaults contains the effective execution policy of each category of request types not configured explicitly.
+	Defaults DefaultsConfig `json:"defaults"`
+	// MaxSessions is the number of sessions permitted per connection, -1 means unlimited.
+	MaxSessions int `json:"maxSessions"`
+	// DryRun is true if the policy is not enforced, see Config.DryRun.
+	DryRun bool `json:"dryRun"`
+}
+
+// ManifestRule contains the effective execution policy and the lists of a section. For the command section Deny
+// contains Command.DenyHashes.
+type ManifestRule struct {
+	Mode  ExecutionPolicy `json:"mode"`
+	Allow []string        `json:"allow,omitempty"`
+	Deny  []string        `json:"deny,omitempty"`
+}
+
+// ManifestPrincipal identifies the connections a manifest describes the policy of. The policy is resolved the same
+// way as for a connection: the overrides in Groups for each of Groups and in Users for Username are applied, followed
+// by the profile in Downgrade for AuthMethod and, if Quarantined is set, the quarantine policy.
+type ManifestPrincipal struct {
+	// Username is the name of the user after canonicalization.
+	Username string `json:"username,omitempty"`
+	// Groups are the groups of the user in the order they are applied.
+	Groups []string `json:"groups,omitempty"`
+	// AuthMethod is the method the user authenticated with.
+	AuthMethod AuthMethod `json:"authMethod,omitempty"`
+	// Quarantined is true if the user or one of the public keys they offered is in quarantine, see Config.Quarantine.
+	Quarantined bool `json:"quarantined,omitempty"`
+}
+
+// SignedManifest is the document produced by ExportManifest. The signature is calculated over the bytes of Manifest.
+type SignedManifest struct {
+	Manifest json.RawMessage `json:"manifest"`
+	// Signature is the base64-encoded Ed25519 signature of Manifest.
+	Signature string `json:"signature"`
+}
+
+// ExportManifest produces a signed JSON manifest of everything the configuration permits for the principal. A zero
+// principal describes the global policy.
+//goland:noinspection GoUnusedExportedFunction
+func ExportManifest(config Config, principal ManifestPrincipal, key ed25519.PrivateKey) ([]byte, error) {
+	config, err := config.prepare()
+	if err != nil {
+		return nil, err
+	}
+	config = config.forPrincipal(
+		principal.Username,
+		principal.Groups,
+		principal.AuthMethod,
+		func(_ QuarantineConfig) bool {
+			return principal.Quarantined
+		},
+	)
+	manifest, err := json.Marshal(newManifest(config, principal))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest (%w)", err)
+	}
+	return json.Marshal(SignedManifest{
+		Manifest:  manifest,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)),
+	})
+}
+
+// VerifyManifest checks the signature of a manifest produced by ExportManifest and returns its contents.
+//goland:noinspection GoUnusedExportedFunction
+func VerifyManifest(data []byte, key ed25519.PublicKey) (Manifest, error) {
+	signed := SignedManifest{}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return Manifest{}, fmt.Errorf("failed to decode manifest (%w)", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil || !ed25519.Verify(key, signed.Manifest, signature) {
+		return Manifest{}, fmt.Errorf("invalid manifest signature")
+	}
+	manifest := Manifest{}
+	if err := json.Unmarshal(signed.Manifest, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to decode manifest (%w)", err)
+	}
+	if manifest.Version != ManifestVersion {
+		return Manifest{}, fmt.Errorf("unsupported manifest version: %d", manifest.Version)
+	}
+	return manifest, nil
+}
+
+func newManifest(config Config, principal ManifestPrincipal) Manifest {
+	session := func(mode ExecutionPolicy) ExecutionPolicy {
+		return config.effectivePolicy(mode, config.Defaults.SessionRequests)
+	}
+	forwarding := func(mode ExecutionPolicy) ExecutionPolicy {
+		return config.effectivePolicy(mode, config.Defaults.Forwarding)
+	}
+	rsync := config.Rsync.Mode
+	if rsync == ExecutionPolicyUnconfigured {
+		rsync = config.Command.Mode
+	}
+	manifest := Manifest{
+		Version:      ManifestVersion,
+		Fingerprint:  config.Fingerprint(),
+		Principal:    principal,
+		Tenant:       config.Tenant,
+		GeneratedAt:  time.Now().UTC(),
+		Capabilities: config.EffectiveCapabilities(),
+		Rules: map[string]ManifestRule{
+			"env":        {session(config.Env.Mode), config.Env.Allow, config.Env.Deny},
+			"command":    {session(config.Command.Mode), config.Command.Allow, config.Command.DenyHashes},
+			"rsync":      {session(rsync), config.Rsync.Allow, config.Rsync.Deny},
+			"shell":      {Mode: session(config.Shell.Mode)},
+			"subsystem":  {session(config.Subsystem.Mode), config.Subsystem.Allow, config.Subsystem.Deny},
+			"tty":        {session(config.TTY.Mode), config.TTY.Allow, config.TTY.Deny},
+			"signal":     {session(config.Signal.Mode), config.Signal.Allow, config.Signal.Deny},
+			"x11":        {Mode: session(config.X11.Mode), Allow: config.X11.Allow},
+			"break":      {Mode: session(config.Break.Mode)},
+			"forwarding": {forwarding(config.Forwarding.Mode), config.Forwarding.Allow, config.Forwarding.Deny},
+			"reverseForwarding": {
+				forwarding(config.ReverseForwarding.Mode),
+				config.ReverseForwarding.Allow,
+				config.ReverseForwarding.Deny,
+			},
+			"streamLocalForwarding": {
+				forwarding(config.StreamLocalForwarding.Mode),
+				config.StreamLocalForwarding.Allow,
+				config.StreamLocalForwarding.Deny,
+			},
+		},
+		CommandRules:   config.Command.Rules,
+		Channels:       map[string]ExecutionPolicy{},
+		Requests:       map[string]ExecutionPolicy{},
+		GlobalRequests: map[string]ExecutionPolicy{},
+		Defaults: DefaultsConfig{
+			SessionRequests: session(""),
+			ChannelRequests: config.effectivePolicy("", config.Defaults.ChannelRequests),
+			GlobalRequests:  config.effectivePolicy("", config.Defaults.GlobalRequests),
+			Channels:        config.effectivePolicy("", config.Defaults.Channels),
+			Forwarding:      forwarding(""),
+		},
+		MaxSessions: config.MaxSessions,
+		DryRun:      config.DryRun,
+	}
+	for channelType, channelConfig := range config.Channels {
+		manifest.Channels[channelType] = config.effectivePolicy(channelConfig.Mode, config.Defaults.Channels)
+	}
+	for requestType, requestConfig := range config.Requests {
+		manifest.Requests[requestType] = config.effectivePolicy(requestConfig.Mode, config.Defaults.ChannelRequests)
+	}
+	for requestType, requestConfig := range config.GlobalRequests {
+		manifest.GlobalRequests[requestType] = config.effectivePolicy(
+			requestConfig.Mode,
+			config.Defaults.GlobalRequests,
+		)
+	}
+	return manifest
+}
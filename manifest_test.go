@@ -0,0 +1,91 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	config := Config{
+		DefaultMode: ExecutionPolicyDisable,
+		MaxSessions: 2,
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"/usr/bin/uptime"},
+			Rules: []CommandRule{{Program: "kubectl", Args: []ArgumentPattern{{Value: "logs"}}, MoreArgs: true}},
+		},
+		Subsystem: SubsystemConfig{
+			ForceCommand: "/usr/lib/sftp-server",
+		},
+		Forwarding: ForwardingConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"10.0.0.0/8:5432"},
+		},
+	}
+
+	data, err := ExportManifest(config, ManifestPrincipal{}, privateKey)
+	assert.NoError(t, err)
+	manifest, err := VerifyManifest(data, publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, ManifestVersion, manifest.Version)
+	assert.Equal(t, config.Fingerprint(), manifest.Fingerprint)
+	assert.Equal(t, ManifestRule{
+		Mode:  ExecutionPolicyFilter,
+		Allow: []string{"/usr/bin/uptime"},
+	}, manifest.Rules["command"])
+	assert.Equal(t, ExecutionPolicyDisable, manifest.Rules["shell"].Mode)
+	assert.Equal(t, []string{"10.0.0.0/8:5432"}, manifest.Rules["forwarding"].Allow)
+	assert.Equal(t, ExecutionPolicyDisable, manifest.Defaults.Channels)
+	assert.Equal(t, config.Command.Rules, manifest.CommandRules)
+	assert.Equal(t, "/usr/lib/sftp-server", manifest.Capabilities.SubsystemForceCommand)
+
+	principal := ManifestPrincipal{Username: "foo", AuthMethod: AuthMethodPublicKey}
+	data, err = ExportManifest(config, principal, privateKey)
+	assert.NoError(t, err)
+	manifest, err = VerifyManifest(data, publicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, principal, manifest.Principal)
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	_, err = VerifyManifest(data, otherKey)
+	assert.Error(t, err)
+}
+
+func TestManifestPrincipal(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	config := Config{
+		MaxSessions: -1,
+		Groups: map[string]Config{
+			"admins": {MaxSessions: 10, Shell: ShellConfig{Mode: ExecutionPolicyEnable}},
+		},
+		Users: map[string]Config{
+			"foo": {MaxSessions: 5},
+		},
+		Profiles: map[string]Config{
+			"weak": {MaxSessions: 1},
+		},
+		Downgrade: map[AuthMethod]string{AuthMethodPassword: "weak"},
+		Shell:     ShellConfig{Mode: ExecutionPolicyDisable},
+	}
+	manifest := func(principal ManifestPrincipal) Manifest {
+		data, err := ExportManifest(config, principal, privateKey)
+		assert.NoError(t, err)
+		result, err := VerifyManifest(data, publicKey)
+		assert.NoError(t, err)
+		return result
+	}
+
+	admin := manifest(ManifestPrincipal{Username: "bar", Groups: []string{"admins"}})
+	assert.Equal(t, 10, admin.MaxSessions)
+	assert.Equal(t, ExecutionPolicyEnable, admin.Capabilities.Shell)
+	assert.Equal(t, 5, manifest(ManifestPrincipal{Username: "foo", Groups: []string{"admins"}}).MaxSessions)
+	assert.Equal(t, 1, manifest(ManifestPrincipal{Username: "foo", AuthMethod: AuthMethodPassword}).MaxSessions)
+	quarantined := manifest(ManifestPrincipal{Username: "foo", Groups: []string{"admins"}, Quarantined: true})
+	assert.Equal(t, ExecutionPolicyDisable, quarantined.Capabilities.Shell)
+}
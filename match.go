@@ -0,0 +1,186 @@
+package security
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MatchMode configures how the entries of allow and deny lists are matched. Individual entries may select a different
+// match mode by starting with exact:, glob:, re: or prefix:, e.g. re:GIT_.* in a list matched in exact mode. The
+// prefix is removed before the entry is matched. Literal values starting with one of these prefixes can be matched
+// using the exact: prefix, e.g. exact:re:value matches re:value.
+type MatchMode string
+
+const (
+	// MatchModeExact matches entries exactly. This is the default.
+	MatchModeExact MatchMode = "exact"
+
+	// MatchModeGlob matches entries as glob patterns. * matches any sequence of characters, including /, spaces and
+	// newlines, and ? matches a single character. For example, GIT_* matches all variables starting with GIT_, and
+	// "/usr/bin/rsync *" matches rsync with any arguments.
+	MatchModeGlob MatchMode = "glob"
+
+	// MatchModeRegex matches entries as regular expressions in the Go syntax. The expression must match the whole
+	// value, e.g. GIT_.* matches all variables starting with GIT_. The s flag is set, so . also matches newlines.
+	MatchModeRegex MatchMode = "regex"
+
+	// MatchModePrefix matches entries as literal prefixes, e.g. /usr/bin/ matches all programs in /usr/bin.
+	MatchModePrefix MatchMode = "prefix"
+)
+
+// Validate validates the match mode.
+func (m MatchMode) Validate() error {
+	switch m {
+	case "":
+	case MatchModeExact:
+	case MatchModeGlob:
+	case MatchModeRegex:
+	case MatchModePrefix:
+	default:
+		return fmt.Errorf("invalid match mode: %s", m)
+	}
+	return nil
+}
+
+// entryPrefixes are the prefixes selecting the match mode of individual list entries.
+var entryPrefixes = []struct {
+	prefix string
+	mode   MatchMode
+}{
+	{"exact:", MatchModeExact},
+	{"glob:", MatchModeGlob},
+	{"re:", MatchModeRegex},
+	{"prefix:", MatchModePrefix},
+}
+
+// parseEntry returns the match mode and the pattern of a list entry matched in the specified mode. The third return
+// value is true if the entry selects its match mode with a prefix.
+func parseEntry(mode MatchMode, entry string) (MatchMode, string, bool) {
+	for _, p := range entryPrefixes {
+		if strings.HasPrefix(entry, p.prefix) {
+			return p.mode, strings.TrimPrefix(entry, p.prefix), true
+		}
+	}
+	if mode == "" {
+		mode = MatchModeExact
+	}
+	return mode, entry, false
+}
+
+// patternSet holds the compiled patterns of a configuration. It is filled when the configuration is prepared, so
+// requests don't have to compile patterns, and is discarded together with the configuration.
+type patternSet struct {
+	lock     *sync.RWMutex
+	compiled map[patternKey]*regexp.Regexp
+}
+
+type patternKey struct {
+	mode    MatchMode
+	pattern string
+}
+
+func newPatternSet() *patternSet {
+	return &patternSet{
+		lock:     &sync.RWMutex{},
+		compiled: map[patternKey]*regexp.Regexp{},
+	}
+}
+
+// compile returns the compiled form of a non-exact pattern. Patterns not compiled yet are added to the set, a nil set
+// compiles the pattern without storing it.
+func (p *patternSet) compile(mode MatchMode, pattern string) (*regexp.Regexp, error) {
+	if p == nil {
+		return compilePattern(mode, pattern)
+	}
+	key := patternKey{mode, pattern}
+	p.lock.RLock()
+	compiled, ok := p.compiled[key]
+	p.lock.RUnlock()
+	if ok {
+		return compiled, nil
+	}
+	compiled, err := compilePattern(mode, pattern)
+	if err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	p.compiled[key] = compiled
+	p.lock.Unlock()
+	return compiled, nil
+}
+
+// compilePattern compiles a non-exact pattern.
+func compilePattern(mode MatchMode, pattern string) (*regexp.Regexp, error) {
+	var expression string
+	switch mode {
+	case MatchModeGlob:
+		expression = globToRegexp(pattern)
+	case MatchModeRegex:
+		expression = "(?s)^(?:" + pattern + ")$"
+	case MatchModePrefix:
+		expression = "^" + regexp.QuoteMeta(pattern)
+	default:
+		return nil, fmt.Errorf("match mode %s does not use patterns", mode)
+	}
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s (%w)", pattern, err)
+	}
+	return compiled, nil
+}
+
+func globToRegexp(pattern string) string {
+	var builder strings.Builder
+	builder.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
+
+// validatePatterns compiles the entries of a list so invalid patterns are rejected at load time.
+func validatePatterns(v *validator, path string, mode MatchMode, entries []string) {
+	for i, entry := range entries {
+		entryMode, pattern, _ := parseEntry(mode, entry)
+		if entryMode == MatchModeExact {
+			continue
+		}
+		_, err := v.patterns.compile(entryMode, pattern)
+		v.check(index(path, i), ValidationCodeInvalidPattern, err)
+	}
+}
+
+// matchList returns true if value matches any of the entries. Entries are matched according to mode unless they select
+// a different match mode with a prefix, see MatchMode.
+func (p *patternSet) matchList(mode MatchMode, entries []string, value string) bool {
+	for _, entry := range entries {
+		entryMode, pattern, _ := parseEntry(mode, entry)
+		if p.matchPattern(entryMode, pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern returns true if value matches a single pattern according to mode. Unlike matchList the pattern can't
+// select its match mode with a prefix. Invalid patterns never match.
+func (p *patternSet) matchPattern(mode MatchMode, pattern string, value string) bool {
+	if mode == "" || mode == MatchModeExact {
+		return pattern == value
+	}
+	compiled, err := p.compile(mode, pattern)
+	if err != nil {
+		return false
+	}
+	return compiled.MatchString(value)
+}
@@ -0,0 +1,108 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchList(t *testing.T) {
+	patterns := newPatternSet()
+	assert.True(t, patterns.matchList(MatchModeExact, []string{"GIT_DIR"}, "GIT_DIR"))
+	assert.False(t, patterns.matchList(MatchModeExact, []string{"GIT_*"}, "GIT_DIR"))
+	assert.True(t, patterns.matchList(MatchModeGlob, []string{"GIT_*"}, "GIT_DIR"))
+	assert.False(t, patterns.matchList(MatchModeGlob, []string{"GIT_*"}, "XGIT_DIR"))
+	assert.True(t, patterns.matchList(MatchModeGlob, []string{"/usr/bin/rsync *"}, "/usr/bin/rsync -a /src host:/dst"))
+	assert.False(t, patterns.matchList(MatchModeGlob, []string{"/usr/bin/rsync *"}, "/usr/bin/rsync"))
+	assert.True(t, patterns.matchList(MatchModeGlob, []string{"USR?"}, "USR1"))
+	assert.True(t, patterns.matchList(MatchModeGlob, []string{"a.b"}, "a.b"))
+	assert.False(t, patterns.matchList(MatchModeGlob, []string{"a.b"}, "axb"))
+	assert.True(t, patterns.matchList(MatchModeRegex, []string{"GIT_.*"}, "GIT_DIR"))
+	assert.False(t, patterns.matchList(MatchModeRegex, []string{"GIT_.*"}, "XGIT_DIR"))
+	assert.False(t, patterns.matchList(MatchModeRegex, []string{"ls|cat"}, "ls; rm -rf /"))
+	assert.True(t, patterns.matchList(MatchModeRegex, []string{"ls|cat"}, "cat"))
+	assert.True(t, patterns.matchList(MatchModeGlob, []string{"*sftp*"}, "x\nsftp"))
+	assert.True(t, patterns.matchList(MatchModeGlob, []string{"a?b"}, "a\nb"))
+	assert.True(t, patterns.matchList(MatchModeExact, []string{"re:.*sftp"}, "x\nsftp"))
+}
+
+func TestPatternsCompiledOnPrepare(t *testing.T) {
+	pattern := "/usr/bin/git-(upload|receive)-pack '[a-z/]+'"
+	config := Config{Command: CommandConfig{MatchMode: MatchModeRegex, Allow: []string{pattern}}}
+	assert.NoError(t, config.Validate())
+	assert.Nil(t, config.patterns)
+
+	evaluator, err := NewEvaluator(config)
+	assert.NoError(t, err)
+	_, compiled := evaluator.config.patterns.compiled[patternKey{MatchModeRegex, pattern}]
+	assert.True(t, compiled)
+	assert.Len(t, evaluator.config.patterns.compiled, 1)
+
+	other, err := NewEvaluator(Config{Env: EnvConfig{MatchMode: MatchModeGlob, Allow: []string{"LC_*"}}})
+	assert.NoError(t, err)
+	assert.Len(t, other.config.patterns.compiled, 1)
+	assert.Same(t, other.config.patterns, other.WithUsername("foo").config.patterns)
+
+	assert.Error(t, Config{Env: EnvConfig{MatchMode: MatchModeRegex, Deny: []string{"GIT_("}}}.Validate())
+}
+
+func TestGlobFilter(t *testing.T) {
+	session := &sessionHandler{
+		config: Config{
+			Env: EnvConfig{
+				Mode:      ExecutionPolicyFilter,
+				MatchMode: MatchModeGlob,
+				Allow:     []string{"GIT_*", "LC_*"},
+			},
+			Command: CommandConfig{
+				Mode:      ExecutionPolicyFilter,
+				MatchMode: MatchModeGlob,
+				Allow:     []string{"/usr/bin/rsync *"},
+			},
+			Subsystem: SubsystemConfig{
+				Mode:      ExecutionPolicyEnable,
+				MatchMode: MatchModeGlob,
+				Deny:      []string{"*@example.com"},
+			},
+		},
+		backend: &dummyBackend{},
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.config.Validate())
+
+	assert.NoError(t, session.OnEnvRequest(1, "GIT_DIR", "/tmp"))
+	assert.Error(t, session.OnEnvRequest(2, "LD_PRELOAD", "/tmp/evil.so"))
+	assert.NoError(t, session.OnExecRequest(3, "/usr/bin/rsync --server ."))
+	assert.Error(t, session.OnExecRequest(4, "/bin/bash"))
+	assert.NoError(t, session.OnSubsystem(5, "sftp"))
+	assert.Error(t, session.OnSubsystem(6, "backup@example.com"))
+
+	assert.Error(t, Config{Env: EnvConfig{MatchMode: "fuzzy"}}.Validate())
+}
+
+func TestMatchListEntryPrefixes(t *testing.T) {
+	var patterns *patternSet
+	assert.True(t, patterns.matchList(MatchModeExact, []string{"re:GIT_.*"}, "GIT_DIR"))
+	assert.True(t, patterns.matchList(MatchModeExact, []string{"glob:LC_*"}, "LC_ALL"))
+	assert.True(t, patterns.matchList(MatchModeExact, []string{"prefix:/usr/bin/"}, "/usr/bin/ls"))
+	assert.False(t, patterns.matchList(MatchModeExact, []string{"prefix:/usr/bin/"}, "/bin/ls"))
+	assert.False(t, patterns.matchList(MatchModeExact, []string{"prefix:a.b"}, "axb"))
+	assert.True(t, patterns.matchList(MatchModeGlob, []string{"exact:GIT_*"}, "GIT_*"))
+	assert.False(t, patterns.matchList(MatchModeGlob, []string{"exact:GIT_*"}, "GIT_DIR"))
+	assert.True(t, patterns.matchList(MatchModeExact, []string{"exact:re:value"}, "re:value"))
+	assert.False(t, patterns.matchList(MatchModeExact, []string{"re:value"}, "re:value"))
+	assert.True(t, patterns.matchList(MatchModePrefix, []string{"LC_"}, "LC_ALL"))
+
+	assert.True(t, patterns.matchSubsystem(MatchModeExact, []string{"internal/*", "re:sftp|scp"}, "scp"))
+	assert.True(t, patterns.matchSubsystem(MatchModeExact, []string{"internal/*", "re:sftp|scp"}, "internal/backup"))
+	assert.False(t, patterns.matchSubsystem(MatchModeExact, []string{"re:sftp|scp"}, "rsync"))
+
+	assert.NoError(t, Config{Subsystem: SubsystemConfig{Allow: []string{"sftp@3+", "re:internal-.*"}}}.Validate())
+	assert.Error(t, Config{Subsystem: SubsystemConfig{Allow: []string{"re:internal-("}}}.Validate())
+	assert.Error(t, Config{Env: EnvConfig{Deny: []string{"re:GIT_("}}}.Validate())
+	assert.NoError(t, Config{Env: EnvConfig{MatchMode: MatchModeRegex, Deny: []string{"exact:GIT_("}}}.Validate())
+	assert.NoError(t, Config{Env: EnvConfig{MatchMode: MatchModePrefix, Allow: []string{"LC_"}}}.Validate())
+}
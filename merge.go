@@ -0,0 +1,184 @@
+package security
+
+import (
+	"reflect"
+	"strings"
+)
+
+// forUser returns the policy with the overrides for the groups in Groups and the user in Users merged onto it.
+func (c Config) forUser(username string, groups []string) Config {
+	result := c
+	for _, group := range groups {
+		if override, ok := c.Groups[group]; ok {
+			result = mergeConfig(result, override.withoutOverrides(), false)
+		}
+	}
+	if override, ok := c.Users[username]; ok {
+		result = mergeConfig(result, override.withoutOverrides(), false)
+	}
+	return result
+}
+
+// forPrincipal returns the policy applied to a connection of the user in groups that authenticated with authMethod.
+// quarantined is called with the Quarantine settings of the resulting policy and returns true if the connection is in
+// quarantine, in which case the quarantine policy is returned.
+func (c Config) forPrincipal(
+	username string,
+	groups []string,
+	authMethod AuthMethod,
+	quarantined func(config QuarantineConfig) bool,
+) Config {
+	policy := c.forUser(username, groups)
+	policy = c.downgrade(policy, authMethod)
+	if quarantined(policy.Quarantine) {
+		return policy.quarantinePolicy()
+	}
+	return policy
+}
+
+// withoutOverrides returns the configuration without the policies for keys, groups, users and profiles.
+func (c Config) withoutOverrides() Config {
+	c.Keys = nil
+	c.Groups = nil
+	c.Users = nil
+	c.Profiles = nil
+	c.Downgrade = nil
+	return c
+}
+
+// ListMerge configures how the lists of a ConfigOverlay are combined with the lists of the layers below it.
+type ListMerge string
+
+const (
+	// ListMergeReplace replaces the lists of the layers below with the lists configured in the overlay. This is the
+	// default.
+	ListMergeReplace ListMerge = "replace"
+
+	// ListMergeAppend appends the lists configured in the overlay to the lists of the layers below.
+	ListMergeAppend ListMerge = "append"
+)
+
+// ConfigOverlay is a layer of a hierarchical policy, e.g. the policy of a group, a user or a connection.
+type ConfigOverlay struct {
+	// Config contains the settings of the layer.
+	Config Config
+	// Lists configures how the lists configured in this layer are combined with the lists of the layers below.
+	Lists ListMerge
+}
+
+// Merge merges the overlays onto each other in the specified order, e.g. global, group, user and connection policies.
+// Settings configured in a layer take precedence over the layers below it, settings left at their zero value (e.g. an
+// unconfigured mode, false or 0) fall through unless they are listed in Config.Explicit. Map entries are added or
+// replaced individually, lists are combined according to the Lists setting of the layer.
+//goland:noinspection GoUnusedExportedFunction
+func Merge(overlays ...ConfigOverlay) Config {
+	result := Config{}
+	for _, overlay := range overlays {
+		result = mergeConfig(result, overlay.Config, overlay.Lists == ListMergeAppend)
+	}
+	return result
+}
+
+// mergeConfig merges override onto base. Fields of override that are not at their zero value or are listed in
+// override.Explicit take precedence, lists replace or are appended to the lists of base and map entries are added or
+// replaced individually.
+func mergeConfig(base Config, override Config, appendLists bool) Config {
+	explicit := map[string]bool{}
+	for _, p := range override.Explicit {
+		explicit[p] = true
+	}
+	merge := &merger{appendLists: appendLists, explicit: explicit}
+	result := merge.value("", reflect.ValueOf(base), reflect.ValueOf(override)).Interface().(Config)
+	result.Explicit = base.Explicit
+	return result
+}
+
+// merger merges the values of an override onto the values of a base configuration.
+type merger struct {
+	appendLists bool
+	// explicit contains the paths of the settings of the override that take precedence at their zero value.
+	explicit map[string]bool
+}
+
+func (m *merger) value(path string, base reflect.Value, override reflect.Value) reflect.Value {
+	if m.explicit[path] {
+		return override
+	}
+	appendLists := m.appendLists
+	switch base.Kind() {
+	case reflect.Struct:
+		// Unexported fields contain state derived from base, e.g. compiled patterns, and are kept.
+		result := reflect.New(base.Type()).Elem()
+		result.Set(base)
+		for i := 0; i < base.NumField(); i++ {
+			if base.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			fieldPath := field(path, settingName(base.Type().Field(i)))
+			result.Field(i).Set(m.value(fieldPath, base.Field(i), override.Field(i)))
+		}
+		return result
+	case reflect.Map:
+		if override.IsNil() {
+			return base
+		}
+		result := reflect.MakeMapWithSize(base.Type(), base.Len()+override.Len())
+		for _, k := range base.MapKeys() {
+			result.SetMapIndex(k, base.MapIndex(k))
+		}
+		for _, k := range override.MapKeys() {
+			result.SetMapIndex(k, override.MapIndex(k))
+		}
+		return result
+	case reflect.Slice:
+		if override.IsNil() {
+			return base
+		}
+		if appendLists && !base.IsNil() {
+			result := reflect.MakeSlice(base.Type(), 0, base.Len()+override.Len())
+			return reflect.AppendSlice(reflect.AppendSlice(result, base), override)
+		}
+		return override
+	case reflect.Ptr:
+		if override.IsNil() {
+			return base
+		}
+		return override
+	default:
+	}
+	if override.IsZero() {
+		return base
+	}
+	return override
+}
+
+// settingName returns the key of a configuration field.
+func settingName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+// validateExplicit checks that the entries of Explicit reference settings of the configuration.
+func validateExplicit(v *validator, path string, explicit []string) {
+	for i, setting := range explicit {
+		t := reflect.TypeOf(Config{})
+		for _, name := range strings.Split(setting, ".") {
+			found := false
+			if t.Kind() == reflect.Struct {
+				for j := 0; j < t.NumField(); j++ {
+					if t.Field(j).PkgPath == "" && settingName(t.Field(j)) == name {
+						t = t.Field(j).Type
+						found = true
+						break
+					}
+				}
+			}
+			if !found {
+				v.fail(index(path, i), ValidationCodeInvalidFormat, "unknown setting: %s", setting)
+				break
+			}
+		}
+	}
+}
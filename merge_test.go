@@ -0,0 +1,133 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserOverrides(t *testing.T) {
+	config := Config{
+		DefaultMode: ExecutionPolicyDisable,
+		MaxSessions: 4,
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"/bin/ls"},
+		},
+		Channels: map[string]ChannelConfig{
+			"direct-tcpip": {Mode: ExecutionPolicyDisable},
+		},
+		Users: map[string]Config{
+			"admin": {
+				Shell: ShellConfig{Mode: ExecutionPolicyEnable},
+				Command: CommandConfig{
+					Allow: []string{"/bin/ls", "/usr/bin/top"},
+				},
+				Channels: map[string]ChannelConfig{
+					"x11": {Mode: ExecutionPolicyEnable},
+				},
+			},
+		},
+	}
+	merged := config.forUser("admin", nil)
+	assert.Equal(t, ExecutionPolicyEnable, merged.Shell.Mode)
+	assert.Equal(t, ExecutionPolicyDisable, merged.DefaultMode)
+	assert.Equal(t, ExecutionPolicyFilter, merged.Command.Mode)
+	assert.Equal(t, []string{"/bin/ls", "/usr/bin/top"}, merged.Command.Allow)
+	assert.Equal(t, 4, merged.MaxSessions)
+	assert.Len(t, merged.Channels, 2)
+	assert.Len(t, config.Channels, 1)
+
+	evaluator, err := NewEvaluator(config)
+	assert.NoError(t, err)
+	assert.False(t, evaluator.EvaluateShell().Allowed)
+	admin := evaluator.WithUsername("admin")
+	assert.True(t, admin.EvaluateShell().Allowed)
+	assert.True(t, admin.EvaluateExec("/usr/bin/top").Allowed)
+	assert.False(t, admin.WithUsername("guest").EvaluateShell().Allowed)
+
+	network := &networkHandler{config: config, backend: &dummyNetworkBackend{}}
+	connection, err := network.OnHandshakeSuccess("admin")
+	assert.NoError(t, err)
+	assert.Equal(t, ExecutionPolicyEnable, connection.(*sshConnectionHandler).config.Shell.Mode)
+
+	assert.Error(t, Config{Users: map[string]Config{"foo": {DefaultMode: "sometimes"}}}.Validate())
+}
+
+func TestMerge(t *testing.T) {
+	global := Config{
+		DefaultMode: ExecutionPolicyDisable,
+		MaxSessions: 10,
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"/bin/ls"},
+		},
+		Env: EnvConfig{Deny: []string{"LD_PRELOAD"}},
+	}
+	group := Config{
+		Command: CommandConfig{Allow: []string{"/usr/bin/git"}},
+		Env:     EnvConfig{Deny: []string{"BASH_ENV"}},
+	}
+	user := Config{
+		Shell:   ShellConfig{Mode: ExecutionPolicyEnable},
+		Command: CommandConfig{Allow: []string{"/usr/bin/top"}},
+	}
+	connection := Config{MaxSessions: 1}
+
+	merged := Merge(
+		ConfigOverlay{Config: global},
+		ConfigOverlay{Config: group, Lists: ListMergeAppend},
+		ConfigOverlay{Config: user},
+		ConfigOverlay{Config: connection},
+	)
+	assert.Equal(t, ExecutionPolicyDisable, merged.DefaultMode)
+	assert.Equal(t, ExecutionPolicyEnable, merged.Shell.Mode)
+	assert.Equal(t, ExecutionPolicyFilter, merged.Command.Mode)
+	assert.Equal(t, []string{"/usr/bin/top"}, merged.Command.Allow)
+	assert.Equal(t, []string{"LD_PRELOAD", "BASH_ENV"}, merged.Env.Deny)
+	assert.Equal(t, 1, merged.MaxSessions)
+	assert.Equal(t, []string{"/bin/ls"}, global.Command.Allow)
+	assert.Equal(t, Config{}, Merge())
+}
+
+func TestExplicitOverride(t *testing.T) {
+	config := Config{
+		ConfineToHome: true,
+		DryRun:        true,
+		MaxSessions:   4,
+		Env:           EnvConfig{HardenEnvironment: true, Deny: []string{"LD_PRELOAD"}},
+		Users: map[string]Config{
+			"admin": {
+				Explicit: []string{"confineToHome", "dryRun", "maxSessions", "env.hardenEnvironment"},
+			},
+			"guest": {
+				ConfineToHome: false,
+				MaxSessions:   0,
+			},
+		},
+	}
+	assert.NoError(t, config.Validate())
+
+	admin := config.forUser("admin", nil)
+	assert.False(t, admin.ConfineToHome)
+	assert.False(t, admin.DryRun)
+	assert.Equal(t, 0, admin.MaxSessions)
+	assert.False(t, admin.Env.HardenEnvironment)
+	assert.Equal(t, []string{"LD_PRELOAD"}, admin.Env.Deny)
+	assert.Nil(t, admin.Explicit)
+
+	guest := config.forUser("guest", nil)
+	assert.True(t, guest.ConfineToHome)
+	assert.True(t, guest.DryRun)
+	assert.Equal(t, 4, guest.MaxSessions)
+
+	merged := Merge(
+		ConfigOverlay{Config: Config{Shell: ShellConfig{Mode: ExecutionPolicyEnable}}},
+		ConfigOverlay{Config: Config{Explicit: []string{"shell"}}},
+	)
+	assert.Equal(t, ExecutionPolicyUnconfigured, merged.Shell.Mode)
+
+	err := Config{Explicit: []string{"confineToHome", "env.unknown", "users.admin"}}.Validate()
+	assert.Error(t, err)
+	assert.Len(t, err.(ValidationErrors), 2)
+}
@@ -0,0 +1,104 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProcessConfig contains constraints for the programs started by the backend. This library doesn't start programs, so
+// the constraints are passed to backends in the SecurityContext. They can be applied by the component starting the
+// program, e.g. the secexec wrapper in cmd/secexec.
+type ProcessConfig struct {
+	// Umask is the file mode creation mask in octal notation, e.g. 077. If empty the umask is left unchanged.
+	Umask string `json:"umask" yaml:"umask"`
+	// WorkingDirectory is a template for the absolute path of the working directory of the program, which may
+	// reference the username as {{ .Username }}, e.g. /home/{{ .Username }}. If empty the working directory is left
+	// unchanged. The SecurityContext contains the resolved path.
+	WorkingDirectory string `json:"workingDirectory" yaml:"workingDirectory"`
+	// MaxOpenFiles limits the number of open file descriptors. 0 means the limit is left unchanged.
+	MaxOpenFiles int64 `json:"maxOpenFiles" yaml:"maxOpenFiles" default:"0"`
+	// MaxProcesses limits the number of processes of the user. 0 means the limit is left unchanged.
+	MaxProcesses int64 `json:"maxProcesses" yaml:"maxProcesses" default:"0"`
+	// MaxFileSize limits the size of the files the program writes in bytes. 0 means the limit is left unchanged.
+	MaxFileSize int64 `json:"maxFileSize" yaml:"maxFileSize" default:"0"`
+	// MaxCPUTime limits the CPU time of the program in seconds. 0 means the limit is left unchanged.
+	MaxCPUTime int64 `json:"maxCpuTime" yaml:"maxCpuTime" default:"0"`
+	// MaxMemory limits the address space of the program in bytes. 0 means the limit is left unchanged.
+	MaxMemory int64 `json:"maxMemory" yaml:"maxMemory" default:"0"`
+}
+
+// Validate validates the process constraints.
+func (p ProcessConfig) Validate() error {
+	v := &validator{}
+	p.validate(v, "")
+	return v.result()
+}
+
+func (p ProcessConfig) validate(v *validator, path string) {
+	if p.Umask != "" {
+		_, err := p.ParseUmask()
+		v.check(field(path, "umask"), ValidationCodeInvalidFormat, err)
+	}
+	if p.WorkingDirectory != "" {
+		if _, err := parseHomeDirectory(p.WorkingDirectory); err != nil {
+			v.check(field(path, "workingDirectory"), ValidationCodeInvalidTemplate, err)
+		}
+	}
+	for name, value := range map[string]int64{
+		"maxOpenFiles": p.MaxOpenFiles,
+		"maxProcesses": p.MaxProcesses,
+		"maxFileSize":  p.MaxFileSize,
+		"maxCpuTime":   p.MaxCPUTime,
+		"maxMemory":    p.MaxMemory,
+	} {
+		if value < 0 {
+			v.fail(field(path, name), ValidationCodeOutOfRange, "invalid %s setting: %d", name, value)
+		}
+	}
+}
+
+// ParseUmask returns the numeric value of Umask.
+func (p ProcessConfig) ParseUmask() (uint32, error) {
+	umask, err := strconv.ParseUint(p.Umask, 8, 32)
+	if err != nil || umask > 0777 {
+		return 0, fmt.Errorf("invalid umask: %s", p.Umask)
+	}
+	return uint32(umask), nil
+}
+
+// forUser returns the process constraints with the working directory resolved for the user.
+func (p ProcessConfig) forUser(username string) (ProcessConfig, error) {
+	if p.WorkingDirectory == "" {
+		return p, nil
+	}
+	workingDirectory, err := resolveHomeDirectory(p.WorkingDirectory, username)
+	if err != nil {
+		return p, err
+	}
+	p.WorkingDirectory = workingDirectory
+	return p, nil
+}
+
+// SanitizeEnvironment returns the environment for the program in the form of os.Environ, applying the environment
+// restrictions of the security context: dangerous variables are removed unless AllowDangerousEnv is set, and the
+// hardened variables are forced if HardenEnvironment is set.
+func (c SecurityContext) SanitizeEnvironment(environment []string) []string {
+	result := make([]string, 0, len(environment)+len(hardenedEnvironment))
+	for _, entry := range environment {
+		name := strings.SplitN(entry, "=", 2)[0]
+		if c.HardenEnvironment && isHardenedEnv(name) {
+			continue
+		}
+		if !c.AllowDangerousEnv && isDangerousEnv(name) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	if c.HardenEnvironment {
+		for _, env := range hardenedEnvironment {
+			result = append(result, env.name+"="+env.value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package security
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const defaultPromptMessage = "This action requires confirmation. Type \"%s\" to continue: "
+const defaultPromptPhrase = "yes"
+const defaultPromptTimeout = time.Minute
+const maxPromptAnswerLength = 256
+
+// Confirm writes message to stdout and reads a line from stdin, echoing the typed characters. It returns nil if the
+// line matches phrase. Since no program is running yet the client terminal is in raw mode, so echoing and backspace
+// handling is done here. Reading stops at the end of the line so the remaining input is left for the program.
+func Confirm(stdin io.Reader, stdout io.Writer, message string, phrase string) error {
+	if _, err := stdout.Write([]byte(message)); err != nil {
+		return fmt.Errorf("failed to write prompt (%w)", err)
+	}
+	var answer []byte
+	buf := make([]byte, 1)
+	for {
+		if _, err := stdin.Read(buf); err != nil {
+			return fmt.Errorf("failed to read prompt answer (%w)", err)
+		}
+		switch buf[0] {
+		case '\r', '\n':
+			_, _ = stdout.Write([]byte("\r\n"))
+			if string(answer) != phrase {
+				return fmt.Errorf("confirmation phrase mismatch")
+			}
+			return nil
+		case 0x03, 0x04:
+			_, _ = stdout.Write([]byte("\r\n"))
+			return fmt.Errorf("confirmation aborted")
+		case 0x7f, 0x08:
+			if len(answer) > 0 {
+				answer = answer[:len(answer)-1]
+				_, _ = stdout.Write([]byte("\b \b"))
+			}
+		default:
+			if len(answer) >= maxPromptAnswerLength {
+				return fmt.Errorf("confirmation answer too long")
+			}
+			answer = append(answer, buf[0])
+			_, _ = stdout.Write(buf)
+		}
+	}
+}
+
+// prompt asks the user for confirmation if mode is ExecutionPolicyPrompt. The confirmation is only possible in
+// interactive sessions, so the request is rejected if no TTY has been requested. If the user doesn't answer within
+// Prompt.Timeout the channel is closed, which also stops reading the answer.
+func (s *sessionHandler) prompt(mode ExecutionPolicy) error {
+	if mode != ExecutionPolicyPrompt {
+		return nil
+	}
+	if !s.pty || s.channel == nil {
+		return fmt.Errorf("confirmation requires an interactive session")
+	}
+	phrase := s.config.Prompt.Phrase
+	if phrase == "" {
+		phrase = defaultPromptPhrase
+	}
+	message := s.config.Prompt.Message
+	if message == "" {
+		message = fmt.Sprintf(defaultPromptMessage, phrase)
+	}
+	timeout := s.config.Prompt.Timeout
+	if timeout <= 0 {
+		timeout = defaultPromptTimeout
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- Confirm(s.channel.Stdin(), s.channel.Stdout(), message, phrase)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		_ = s.channel.Close()
+		return fmt.Errorf("confirmation timed out")
+	}
+}
+
+// validateNoPrompt rejects the prompt policy for sections where no confirmation can be requested.
+func validateNoPrompt(mode ExecutionPolicy) error {
+	if mode == ExecutionPolicyPrompt {
+		return fmt.Errorf("the %s mode is only supported for shell, command and subsystem", mode)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package security
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirm(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stdin := strings.NewReader("yez\x7fs\rremaining")
+	assert.NoError(t, Confirm(stdin, stdout, "Confirm: ", "yes"))
+	assert.Equal(t, "Confirm: yez\b \bs\r\n", stdout.String())
+	assert.Equal(t, 9, stdin.Len())
+
+	assert.Error(t, Confirm(strings.NewReader("no\r"), &bytes.Buffer{}, "Confirm: ", "yes"))
+	assert.Error(t, Confirm(strings.NewReader("ye\x03s\r"), &bytes.Buffer{}, "Confirm: ", "yes"))
+	assert.Error(t, Confirm(strings.NewReader("ye"), &bytes.Buffer{}, "Confirm: ", "yes"))
+}
+
+func TestPromptPolicy(t *testing.T) {
+	channel := &recordingSessionChannel{}
+	session := &sessionHandler{
+		config: Config{
+			Shell: ShellConfig{
+				Mode: ExecutionPolicyPrompt,
+			},
+			Prompt: PromptConfig{
+				Message: "Type CONFIRM: ",
+				Phrase:  "CONFIRM",
+			},
+		},
+		backend: &dummyBackend{},
+		channel: newBackendChannel(channel),
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+
+	channel.stdin.WriteString("CONFIRM\r")
+	assert.Error(t, session.OnShell(1))
+
+	assert.NoError(t, session.OnPtyRequest(2, "xterm", 80, 25, 800, 600, []byte{}))
+	assert.NoError(t, session.OnShell(3))
+	assert.Equal(t, "Type CONFIRM: CONFIRM\r\n", channel.stdout.String())
+
+	channel.stdin.WriteString("nope\r")
+	assert.Error(t, session.OnShell(4))
+}
+
+func TestPromptTimeout(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer func() {
+		_ = writer.Close()
+	}()
+	channel := &blockingSessionChannel{stdin: reader}
+	session := &sessionHandler{
+		config: Config{
+			Shell:  ShellConfig{Mode: ExecutionPolicyPrompt},
+			Prompt: PromptConfig{Timeout: 10 * time.Millisecond},
+		},
+		backend: &dummyBackend{},
+		channel: newBackendChannel(channel),
+		pty:     true,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.Error(t, session.OnShell(1))
+	assert.True(t, channel.closed)
+}
+
+type blockingSessionChannel struct {
+	recordingSessionChannel
+	stdin io.Reader
+}
+
+func (b *blockingSessionChannel) Stdin() io.Reader {
+	return b.stdin
+}
+
+func TestPromptValidation(t *testing.T) {
+	assert.NoError(t, Config{Command: CommandConfig{Mode: ExecutionPolicyPrompt}}.Validate())
+	assert.Error(t, Config{DefaultMode: ExecutionPolicyPrompt}.Validate())
+	assert.Error(t, Config{Env: EnvConfig{Mode: ExecutionPolicyPrompt}}.Validate())
+	assert.Error(t, Config{Prompt: PromptConfig{Phrase: "yes\n"}}.Validate())
+	assert.Error(t, Config{Prompt: PromptConfig{Timeout: -time.Second}}.Validate())
+}
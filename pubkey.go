@@ -0,0 +1,32 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fingerprint returns the SHA256 fingerprint of a public key in the authorized_keys format, or an empty string if the
+// key cannot be parsed.
+func fingerprint(pubKey string) string {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pubKey))
+	if err != nil {
+		return ""
+	}
+	return ssh.FingerprintSHA256(key)
+}
+
+// validateFingerprint checks if keyFingerprint is a SHA256 fingerprint as produced by ssh-keygen -l.
+func validateFingerprint(keyFingerprint string) error {
+	if !strings.HasPrefix(keyFingerprint, "SHA256:") {
+		return fmt.Errorf("fingerprint must start with SHA256: %s", keyFingerprint)
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(keyFingerprint, "SHA256:"))
+	if err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("invalid fingerprint: %s", keyFingerprint)
+	}
+	return nil
+}
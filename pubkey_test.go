@@ -0,0 +1,88 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/containerssh/sshserver"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestValidateFingerprint(t *testing.T) {
+	assert.NoError(t, validateFingerprint("SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"))
+	assert.Error(t, validateFingerprint("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"))
+	assert.Error(t, validateFingerprint("SHA256:47DEQpj8HBSa"))
+	assert.Error(t, validateFingerprint("MD5:d4:1d:8c:d9:8f:00:b2:04:e9:80:09:98:ec:f8:42:7e"))
+}
+
+func TestKeyPolicyUnsupported(t *testing.T) {
+	deployKey := generateAuthorizedKey(t)
+	config := Config{
+		MaxSessions: -1,
+		Keys: map[string]Config{
+			fingerprint(deployKey): {Shell: ShellConfig{Mode: ExecutionPolicyDisable}},
+			"SHA256:invalid":       {},
+		},
+	}
+	err := config.Validate()
+	var validationErrors ValidationErrors
+	assert.True(t, errors.As(err, &validationErrors))
+	paths := map[string]string{}
+	for _, validationError := range validationErrors {
+		paths[validationError.Path] = validationError.Code
+	}
+	assert.Equal(t, map[string]string{
+		"keys":                   ValidationCodeUnsupported,
+		`keys["SHA256:invalid"]`: ValidationCodeInvalidFormat,
+	}, paths)
+
+	_, err = New(config, &dummyNetworkBackend{})
+	assert.Error(t, err)
+}
+
+func TestOfferedKeysQuarantine(t *testing.T) {
+	store := NewMemoryStateStore()
+	seen := []byte(time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339Nano))
+	oldKey := generateAuthorizedKey(t)
+	newKey := generateAuthorizedKey(t)
+	for _, principal := range []string{"user:shared", "key:" + fingerprint(oldKey)} {
+		assert.NoError(t, store.Set("firstSeen/"+principal, seen, 0))
+	}
+	h, err := NewHandlerWithStateStore(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: -1,
+		Quarantine: QuarantineConfig{
+			Period: time.Hour,
+			Policy: &Config{MaxSessions: -1, Shell: ShellConfig{Mode: ExecutionPolicyDisable}},
+		},
+	}, &dummyHandler{}, store)
+	assert.NoError(t, err)
+	networkConnection, err := h.OnNetworkConnection(net.TCPAddr{IP: net.ParseIP("192.0.2.1")}, "1")
+	assert.NoError(t, err)
+
+	// The client queries the new key, then the old one, and finally signs with the new key. The SSH library has
+	// cached the result for the new key, so the last handler built is the one after the query for the old key.
+	var sshConnection sshserver.SSHConnectionHandler
+	for _, key := range []string{newKey, oldKey} {
+		_, err = networkConnection.OnAuthPubKey("shared", key)
+		assert.NoError(t, err)
+		sshConnection, err = networkConnection.OnHandshakeSuccess("shared")
+		assert.NoError(t, err)
+	}
+	session, err := sshConnection.OnSessionChannel(1, []byte{}, &sessionChannel{})
+	assert.NoError(t, err)
+	assert.Error(t, session.OnShell(1))
+}
+
+func generateAuthorizedKey(t *testing.T) string {
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	sshPubKey, err := ssh.NewPublicKey(pubKey)
+	assert.NoError(t, err)
+	return string(ssh.MarshalAuthorizedKey(sshPubKey))
+}
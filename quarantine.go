@@ -0,0 +1,62 @@
+package security
+
+import (
+	"time"
+)
+
+// firstSeenStore records when users and public keys were first seen by a handler.
+type firstSeenStore struct {
+	store StateStore
+}
+
+func newFirstSeenStore(store StateStore) *firstSeenStore {
+	return &firstSeenStore{
+		store: store,
+	}
+}
+
+// firstSeen returns the time principal was first seen, recording now if it has not been seen before. If the store
+// fails now is returned, treating the principal as new.
+func (f *firstSeenStore) firstSeen(principal string, now time.Time) time.Time {
+	stored, _, err := f.store.SetIfAbsent(
+		"firstSeen/"+principal,
+		[]byte(now.UTC().Format(time.RFC3339Nano)),
+		0,
+	)
+	if err != nil {
+		return now
+	}
+	firstSeen, err := time.Parse(time.RFC3339Nano, string(stored))
+	if err != nil {
+		return now
+	}
+	return firstSeen
+}
+
+// inQuarantine returns true if any of the principals was first seen within the quarantine period.
+func (f *firstSeenStore) inQuarantine(config QuarantineConfig, principals []string, now time.Time) bool {
+	if config.Period <= 0 {
+		return false
+	}
+	quarantined := false
+	for _, principal := range principals {
+		if now.Sub(f.firstSeen(principal, now)) < config.Period {
+			quarantined = true
+		}
+	}
+	return quarantined
+}
+
+// quarantinePolicy returns the policy applied to principals in quarantine. The policy shares the compiled patterns
+// of c, which include the patterns of Quarantine.Policy.
+func (c Config) quarantinePolicy() Config {
+	policy := Config{
+		DefaultMode: ExecutionPolicyDisable,
+		MaxSessions: -1,
+	}
+	if c.Quarantine.Policy != nil {
+		policy = *c.Quarantine.Policy
+	}
+	policy.patterns = c.patterns
+	return policy
+}
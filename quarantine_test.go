@@ -0,0 +1,58 @@
+package security
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstSeenStore(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newFirstSeenStore(NewMemoryStateStore())
+	config := QuarantineConfig{Period: time.Hour}
+
+	assert.True(t, store.inQuarantine(config, []string{"user:foo"}, now))
+	assert.True(t, store.inQuarantine(config, []string{"user:foo"}, now.Add(59*time.Minute)))
+	assert.False(t, store.inQuarantine(config, []string{"user:foo"}, now.Add(time.Hour)))
+	assert.True(t, store.inQuarantine(config, []string{"user:foo", "key:bar"}, now.Add(time.Hour)))
+	assert.False(t, store.inQuarantine(QuarantineConfig{}, []string{"user:baz"}, now))
+}
+
+func TestQuarantine(t *testing.T) {
+	h, err := NewHandler(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: -1,
+		Quarantine: QuarantineConfig{
+			Period: time.Hour,
+			Policy: &Config{
+				MaxSessions: -1,
+				Shell: ShellConfig{
+					Mode: ExecutionPolicyDisable,
+				},
+			},
+		},
+	}, &dummyHandler{})
+	assert.NoError(t, err)
+
+	authorizedKey := generateAuthorizedKey(t)
+
+	networkConnection, err := h.OnNetworkConnection(net.TCPAddr{IP: net.ParseIP("192.0.2.1")}, "1")
+	assert.NoError(t, err)
+	_, err = networkConnection.OnAuthPubKey("foo", authorizedKey)
+	assert.NoError(t, err)
+	sshConnection, err := networkConnection.OnHandshakeSuccess("foo")
+	assert.NoError(t, err)
+	session, err := sshConnection.OnSessionChannel(1, []byte{}, &sessionChannel{})
+	assert.NoError(t, err)
+	assert.Error(t, session.OnShell(1))
+	assert.NoError(t, session.OnExecRequest(2, "/bin/true"))
+	store := h.(*handler).firstSeen.store
+	_, ok, err := store.Get("firstSeen/user:foo")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = store.Get("firstSeen/key:" + fingerprint(authorizedKey))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,158 @@
+package security
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket rate limiter keyed by principal (e.g. IP address or username). Principals exceeding
+// the limit are blocked for a penalty period that doubles with each repeated violation.
+type rateLimiter struct {
+	config      RateLimitConfig
+	perMinute   int
+	lock        *sync.Mutex
+	buckets     map[string]*rateBucket
+	lastCleanup time.Time
+	now         func() time.Time
+	sleep       func(time.Duration)
+}
+
+type rateBucket struct {
+	tokens       float64
+	last         time.Time
+	violations   uint
+	blockedUntil time.Time
+	waiting      int
+}
+
+func newRateLimiter(config RateLimitConfig, perMinute int) *rateLimiter {
+	return &rateLimiter{
+		config:    config,
+		perMinute: perMinute,
+		lock:      &sync.Mutex{},
+		buckets:   map[string]*rateBucket{},
+		now:       time.Now,
+		sleep:     time.Sleep,
+	}
+}
+
+// reconfigure replaces the limits. The buckets are kept, so principals don't gain a fresh burst when the configuration
+// is reloaded.
+func (r *rateLimiter) reconfigure(config RateLimitConfig, perMinute int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.config = config
+	r.perMinute = perMinute
+}
+
+func (r *rateLimiter) capacity() float64 {
+	if r.config.Burst < 1 {
+		return 1
+	}
+	return float64(r.config.Burst)
+}
+
+// allow consumes a token for key and returns true if the action is permitted. If throttling is configured and no
+// token is available the call waits for the next token instead of failing, as long as the wait is within MaxDelay and
+// the queue for key is not full.
+func (r *rateLimiter) allow(key string) bool {
+	r.lock.Lock()
+	if r.perMinute <= 0 {
+		r.lock.Unlock()
+		return true
+	}
+	allowed, delay := r.reserve(key)
+	r.lock.Unlock()
+	if !allowed || delay <= 0 {
+		return allowed
+	}
+	r.sleep(delay)
+	r.lock.Lock()
+	r.buckets[key].waiting--
+	r.lock.Unlock()
+	return true
+}
+
+// reserve consumes a token for key and returns how long the caller has to wait before the token becomes valid. The
+// caller must hold the lock.
+func (r *rateLimiter) reserve(key string) (bool, time.Duration) {
+	now := r.now()
+	r.cleanup(now)
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = &rateBucket{
+			tokens: r.capacity(),
+			last:   now,
+		}
+		r.buckets[key] = bucket
+	}
+	r.refill(bucket, now)
+	if now.Before(bucket.blockedUntil) {
+		return false, 0
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	// Throttled callers take tokens in advance, leaving the bucket negative until it refills.
+	delay := time.Duration((1 - bucket.tokens) / float64(r.perMinute) * float64(time.Minute))
+	queueFull := r.config.Throttle.MaxQueue > 0 && bucket.waiting >= r.config.Throttle.MaxQueue
+	if delay <= r.config.Throttle.MaxDelay && !queueFull {
+		bucket.tokens--
+		bucket.waiting++
+		return true, delay
+	}
+	bucket.violations++
+	bucket.blockedUntil = now.Add(r.penalty(bucket.violations))
+	return false, 0
+}
+
+func (r *rateLimiter) refill(bucket *rateBucket, now time.Time) {
+	bucket.tokens += now.Sub(bucket.last).Minutes() * float64(r.perMinute)
+	bucket.last = now
+	if bucket.tokens > r.capacity() {
+		bucket.tokens = r.capacity()
+	}
+	// Forget previous violations once the client stayed quiet for as long as the last penalty after it expired.
+	if bucket.violations > 0 && now.Sub(bucket.blockedUntil) >= r.penalty(bucket.violations) {
+		bucket.violations = 0
+	}
+}
+
+func (r *rateLimiter) penalty(violations uint) time.Duration {
+	penalty := r.config.Penalty
+	for i := uint(1); i < violations && penalty < math.MaxInt64/2; i++ {
+		if r.config.MaxPenalty > 0 && penalty >= r.config.MaxPenalty {
+			break
+		}
+		penalty *= 2
+	}
+	if r.config.MaxPenalty > 0 && penalty > r.config.MaxPenalty {
+		return r.config.MaxPenalty
+	}
+	return penalty
+}
+
+// cleanup removes buckets that are full and carry no violations. These are equivalent to a fresh bucket.
+func (r *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(r.lastCleanup) < time.Minute {
+		return
+	}
+	r.lastCleanup = now
+	for key, bucket := range r.buckets {
+		r.refill(bucket, now)
+		if bucket.tokens >= r.capacity() && bucket.violations == 0 && bucket.waiting == 0 {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// ErrRateLimited indicates that a client opened too many connections in a short amount of time.
+type ErrRateLimited struct {
+}
+
+// Error contains the error for the logs.
+func (e *ErrRateLimited) Error() string {
+	return "connection rate limit exceeded"
+}
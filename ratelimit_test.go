@@ -0,0 +1,95 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(RateLimitConfig{
+		Burst:      2,
+		Penalty:    time.Minute,
+		MaxPenalty: 3 * time.Minute,
+	}, 6)
+	limiter.now = func() time.Time {
+		return now
+	}
+
+	assert.True(t, limiter.allow("foo"))
+	assert.True(t, limiter.allow("foo"))
+	assert.True(t, limiter.allow("bar"))
+	assert.False(t, limiter.allow("foo"))
+
+	// Within the penalty period the client stays blocked even though the bucket refilled.
+	now = now.Add(30 * time.Second)
+	assert.False(t, limiter.allow("foo"))
+
+	now = now.Add(31 * time.Second)
+	assert.True(t, limiter.allow("foo"))
+	assert.True(t, limiter.allow("foo"))
+	assert.False(t, limiter.allow("foo"))
+
+	// The second violation doubles the penalty.
+	now = now.Add(90 * time.Second)
+	assert.False(t, limiter.allow("foo"))
+	now = now.Add(31 * time.Second)
+	assert.True(t, limiter.allow("foo"))
+
+	assert.Equal(t, time.Minute, limiter.penalty(1))
+	assert.Equal(t, 2*time.Minute, limiter.penalty(2))
+	assert.Equal(t, 3*time.Minute, limiter.penalty(3))
+	assert.Equal(t, 3*time.Minute, limiter.penalty(100))
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{}, 0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, limiter.allow("foo"))
+	}
+}
+
+func TestRateLimiterThrottle(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(RateLimitConfig{
+		Burst: 1,
+		Throttle: ThrottleConfig{
+			MaxDelay: 2500 * time.Millisecond,
+			MaxQueue: 2,
+		},
+	}, 60)
+	limiter.now = func() time.Time {
+		return now
+	}
+	var delays []time.Duration
+	limiter.sleep = func(delay time.Duration) {
+		delays = append(delays, delay)
+	}
+
+	assert.True(t, limiter.allow("foo"))
+	assert.True(t, limiter.allow("foo"))
+	assert.True(t, limiter.allow("foo"))
+	// The next token would only be available in 3 seconds.
+	assert.False(t, limiter.allow("foo"))
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, delays)
+
+	// Only MaxQueue requests can wait at the same time.
+	now = now.Add(time.Hour)
+	limiter.config.Throttle.MaxDelay = time.Hour
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	for i := 0; i < 2; i++ {
+		limiter.reserve("bar")
+	}
+	allowed, _ := limiter.reserve("bar")
+	assert.True(t, allowed)
+	allowed, _ = limiter.reserve("bar")
+	assert.False(t, allowed)
+
+	// Without MaxQueue only MaxDelay limits the queue.
+	limiter.config.Throttle.MaxQueue = 0
+	allowed, _ = limiter.reserve("bar")
+	assert.True(t, allowed)
+}
@@ -0,0 +1,74 @@
+package security
+
+import (
+	"time"
+)
+
+const recertificationDateFormat = "2006-01-02"
+
+const recertificationWarning = "Your access has not been recertified in time. Please contact your administrator.\r\n"
+
+// recertificationExpired returns true if the recertification date of the specified user has passed. The access is
+// valid until the end of the configured day in UTC.
+func recertificationExpired(config RecertificationConfig, username string, now time.Time) bool {
+	date, ok := config.Users[username]
+	if !ok {
+		return false
+	}
+	certifiedUntil, err := time.Parse(recertificationDateFormat, date)
+	if err != nil {
+		return true
+	}
+	return !now.Before(certifiedUntil.AddDate(0, 0, 1))
+}
+
+// restrictToSFTP returns a copy of config that only permits session channels and the SFTP subsystem. All sections
+// fall back to the disabled defaults, forced commands are removed and the SFTP restrictions are kept.
+func restrictToSFTP(config Config) Config {
+	config = config.withoutOverrides()
+	config.DefaultMode = ExecutionPolicyDisable
+	config.Defaults = DefaultsConfig{
+		SessionRequests: ExecutionPolicyDisable,
+		ChannelRequests: ExecutionPolicyDisable,
+		GlobalRequests:  ExecutionPolicyDisable,
+		Channels:        ExecutionPolicyDisable,
+		Forwarding:      ExecutionPolicyDisable,
+	}
+	config.DryRun = false
+	config.ForceCommand = ""
+	config.ForceCommandArgs = nil
+	config.Env.Mode = ExecutionPolicyUnconfigured
+	config.Command = CommandConfig{DenyHashes: config.Command.DenyHashes}
+	config.Rsync = RsyncConfig{}
+	config.Shell = ShellConfig{}
+	config.Subsystem = SubsystemConfig{
+		Mode:  ExecutionPolicyFilter,
+		Allow: []string{"sftp"},
+	}
+	config.TTY.Mode = ExecutionPolicyUnconfigured
+	config.Signal.Mode = ExecutionPolicyUnconfigured
+	config.X11.Mode = ExecutionPolicyUnconfigured
+	config.Break.Mode = ExecutionPolicyUnconfigured
+	config.Egress.Mode = ExecutionPolicyUnconfigured
+	config.Forwarding.Mode = ExecutionPolicyUnconfigured
+	config.ReverseForwarding.Mode = ExecutionPolicyUnconfigured
+	config.StreamLocalForwarding.Mode = ExecutionPolicyUnconfigured
+	channels := map[string]ChannelConfig{}
+	if session, ok := config.Channels["session"]; ok {
+		session.Mode = ExecutionPolicyEnable
+		channels["session"] = session
+	}
+	config.Channels = channels
+	config.Requests = nil
+	config.GlobalRequests = nil
+	return config
+}
+
+// ErrRecertificationExpired indicates that the access of the user has not been recertified in time.
+type ErrRecertificationExpired struct {
+}
+
+// Error contains the error for the logs.
+func (e *ErrRecertificationExpired) Error() string {
+	return "access recertification expired"
+}
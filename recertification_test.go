@@ -0,0 +1,125 @@
+package security
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecertificationExpired(t *testing.T) {
+	config := RecertificationConfig{
+		Users: map[string]string{
+			"foo": "2021-01-31",
+		},
+	}
+	assert.False(t, recertificationExpired(config, "foo", time.Date(2021, 1, 31, 23, 59, 0, 0, time.UTC)))
+	assert.True(t, recertificationExpired(config, "foo", time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, recertificationExpired(config, "bar", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestRecertificationAction(t *testing.T) {
+	config := Config{
+		MaxSessions: -1,
+		Recertification: RecertificationConfig{
+			Users: map[string]string{
+				"foo": "2000-01-01",
+			},
+		},
+	}
+	handler := &networkHandler{
+		config:  config,
+		backend: &dummyNetworkBackend{},
+	}
+	_, err := handler.OnHandshakeSuccess("foo")
+	assert.Error(t, err)
+	_, err = handler.OnHandshakeSuccess("bar")
+	assert.NoError(t, err)
+
+	handler.config.Recertification.Action = RecertificationActionRestrict
+	connection, err := handler.OnHandshakeSuccess("foo")
+	assert.NoError(t, err)
+	session, err := connection.OnSessionChannel(1, []byte{}, &sessionChannel{})
+	assert.NoError(t, err)
+	assert.Error(t, session.OnShell(1))
+	assert.Error(t, session.OnExecRequest(2, "/bin/bash"))
+	assert.Error(t, session.OnSubsystem(3, "netconf"))
+	assert.NoError(t, session.OnSubsystem(4, "sftp"))
+
+	assert.Error(t, (Config{Recertification: RecertificationConfig{
+		Users: map[string]string{"foo": "tomorrow"},
+	}}).Validate())
+}
+
+func TestRestrictToSFTP(t *testing.T) {
+	config := Config{
+		DefaultMode:  ExecutionPolicyEnable,
+		MaxSessions:  -1,
+		ForceCommand: "/bin/menu",
+		Command:      CommandConfig{ForceCommand: "/bin/menu"},
+		Shell:        ShellConfig{ForceCommand: "/bin/menu"},
+		Subsystem:    SubsystemConfig{ForceCommand: "/bin/menu"},
+		Channels:     map[string]ChannelConfig{"session": {Max: 2}, "x11": {}},
+		Requests:     map[string]RequestConfig{"custom@example.com": {}},
+		GlobalRequests: map[string]RequestConfig{
+			"tcpip-forward": {},
+		},
+		Users: map[string]Config{"foo": {Shell: ShellConfig{Mode: ExecutionPolicyEnable}}},
+	}
+	sections := reflect.ValueOf(&config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		if sections.Field(i).Kind() != reflect.Struct {
+			continue
+		}
+		if mode := sections.Field(i).FieldByName("Mode"); mode.IsValid() {
+			mode.SetString(string(ExecutionPolicyEnable))
+		}
+	}
+
+	restricted := restrictToSFTP(config)
+	assert.NoError(t, restricted.Validate())
+	assert.Equal(t, ChannelConfig{Mode: ExecutionPolicyEnable, Max: 2}, restricted.Channels["session"])
+	assert.Len(t, restricted.Channels, 1)
+	assert.Empty(t, restricted.Requests)
+	assert.Empty(t, restricted.GlobalRequests)
+	assert.Empty(t, restricted.Users)
+	assert.False(t, restricted.forcedFor("exec").set())
+	assert.False(t, restricted.forcedFor("shell").set())
+	assert.False(t, restricted.forcedFor("subsystem").set())
+	restrictedSections := reflect.ValueOf(restricted)
+	for i := 0; i < restrictedSections.NumField(); i++ {
+		section := restrictedSections.Field(i)
+		if section.Kind() != reflect.Struct || restrictedSections.Type().Field(i).Name == "Subsystem" {
+			continue
+		}
+		if mode := section.FieldByName("Mode"); mode.IsValid() {
+			assert.Equal(
+				t,
+				ExecutionPolicyDisable,
+				restricted.effectivePolicy(ExecutionPolicy(mode.String()), ExecutionPolicyUnconfigured),
+				restrictedSections.Type().Field(i).Name,
+			)
+		}
+	}
+
+	evaluator, err := NewEvaluator(restricted)
+	assert.NoError(t, err)
+	evaluator = evaluator.WithUsername("foo")
+	for name, decision := range map[string]Decision{
+		"env":               evaluator.EvaluateEnv("LANG", "C"),
+		"pty":               evaluator.EvaluatePTY("xterm"),
+		"exec":              evaluator.EvaluateExec("ls"),
+		"shell":             evaluator.EvaluateShell(),
+		"subsystem":         evaluator.EvaluateSubsystem("netconf"),
+		"signal":            evaluator.EvaluateSignal("TERM"),
+		"x11":               evaluator.EvaluateX11("MIT-MAGIC-COOKIE-1", false),
+		"break":             evaluator.EvaluateBreak(0),
+		"forwarding":        evaluator.EvaluateForwarding("127.0.0.1", 80),
+		"reverseForwarding": evaluator.EvaluateReverseForwarding("127.0.0.1", 8080),
+		"streamLocal":       evaluator.EvaluateStreamLocal("direct-streamlocal@openssh.com", "/tmp/socket"),
+	} {
+		assert.False(t, decision.Allowed, name)
+	}
+	assert.True(t, evaluator.EvaluateSubsystem("sftp").Allowed)
+}
@@ -0,0 +1,86 @@
+package security
+
+import (
+	"fmt"
+	"time"
+)
+
+// Reload replaces the configuration for new connections after preparing and self-testing it.
+func (h *handler) Reload(config Config) error {
+	config, err := config.prepareForServer()
+	if err != nil {
+		return err
+	}
+	if err := h.selfTest(config); err != nil {
+		return fmt.Errorf("security configuration failed self-test, keeping the current configuration (%w)", err)
+	}
+	h.configLock.Lock()
+	defer h.configLock.Unlock()
+	h.config = config
+	h.sampler = newSampler(config.Sampling)
+	h.ipRate.reconfigure(config.ConnectionRate, config.ConnectionRate.PerIP)
+	h.userRate.reconfigure(config.ConnectionRate, config.ConnectionRate.PerUser)
+	h.sessionTracker.reconfigure(config)
+	return nil
+}
+
+// selfTest runs every kind of request through an evaluator built from the configuration, renders the templates that
+// are only rendered at runtime and checks that the state store is reachable. Patterns are already compiled by
+// Validate.
+func (h *handler) selfTest(config Config) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("evaluating test requests failed (%v)", recovered)
+		}
+	}()
+	const username = "self-test"
+	evaluator := &Evaluator{config: config, username: username}
+	evaluator.EvaluateEnv("LANG", "C")
+	evaluator.EvaluatePTY("xterm")
+	evaluator.EvaluateExec("true")
+	evaluator.EvaluateShell()
+	evaluator.EvaluateSubsystem("sftp")
+	evaluator.EvaluateSignal("TERM")
+	evaluator.EvaluateX11("MIT-MAGIC-COOKIE-1", true)
+	evaluator.EvaluateBreak(0)
+	evaluator.EvaluateForwarding("127.0.0.1", 22)
+	evaluator.EvaluateReverseForwarding("127.0.0.1", 2222)
+	evaluator.EvaluateStreamLocal("direct-streamlocal@openssh.com", "/tmp/self-test.sock")
+	formatSummary(config)
+
+	if config.ConfineToHome {
+		if _, err := resolveHomeDirectory(config.HomeDirectory, username); err != nil {
+			return fmt.Errorf("failed to render home directory (%w)", err)
+		}
+	}
+	if _, err := config.Process.forUser(username); err != nil {
+		return fmt.Errorf("failed to render working directory (%w)", err)
+	}
+	for name, value := range config.Env.Set {
+		if _, err := formatTemplate(value, forceCommandData{Username: username}); err != nil {
+			return fmt.Errorf("failed to render environment variable %s (%w)", name, err)
+		}
+	}
+	for _, requestType := range []string{"exec", "shell", "subsystem"} {
+		forced := config.forcedFor(requestType)
+		if _, err := formatForceCommand(forced.command, forceCommandData{Username: username}); err != nil {
+			return fmt.Errorf("failed to render forced command for %s requests (%w)", requestType, err)
+		}
+		if _, err := formatForceCommandArgs(forced.args, forceCommandData{Username: username}); err != nil {
+			return fmt.Errorf("failed to render forced command for %s requests (%w)", requestType, err)
+		}
+	}
+	if _, err := formatTerminationNotice(config.TerminationNotice, "self-test"); err != nil {
+		return fmt.Errorf("failed to render termination notice (%w)", err)
+	}
+	if h.store != nil {
+		key := "selfTest/reload"
+		if err := h.store.Set(key, []byte(time.Now().UTC().Format(time.RFC3339Nano)), time.Minute); err != nil {
+			return fmt.Errorf("state store is not writable (%w)", err)
+		}
+		if _, _, err := h.store.Get(key); err != nil {
+			return fmt.Errorf("state store is not readable (%w)", err)
+		}
+	}
+	return nil
+}
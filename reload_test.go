@@ -0,0 +1,62 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReload(t *testing.T) {
+	h, err := NewHandler(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: 1,
+	}, &dummyHandler{})
+	assert.NoError(t, err)
+	client := net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+
+	_, err = h.OnNetworkConnection(client, "1")
+	assert.NoError(t, err)
+	_, err = h.OnNetworkConnection(client, "2")
+	assert.Error(t, err)
+
+	assert.Error(t, h.Reload(Config{MaxSessions: -2}))
+	// The home directory template only fails when it is rendered.
+	assert.Error(t, h.Reload(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: 2,
+		ConfineToHome:       true,
+		HomeDirectory:       "/home/{{ .Group }}",
+	}))
+	_, err = h.OnNetworkConnection(client, "3")
+	assert.Error(t, err)
+
+	assert.NoError(t, h.Reload(Config{MaxSessions: -1, MaxConnectionsPerIP: 2}))
+	_, err = h.OnNetworkConnection(client, "4")
+	assert.NoError(t, err)
+}
+
+func TestReloadStateStoreProbe(t *testing.T) {
+	store := &unavailableStateStore{StateStore: NewMemoryStateStore()}
+	h, err := NewHandlerWithStateStore(Config{MaxSessions: -1, MaxConnectionsPerIP: -1}, &dummyHandler{}, store)
+	assert.NoError(t, err)
+
+	store.unavailable = true
+	assert.Error(t, h.Reload(Config{MaxSessions: -1, MaxConnectionsPerIP: -1}))
+	store.unavailable = false
+	assert.NoError(t, h.Reload(Config{MaxSessions: -1, MaxConnectionsPerIP: -1}))
+}
+
+type unavailableStateStore struct {
+	StateStore
+	unavailable bool
+}
+
+func (u *unavailableStateStore) Set(key string, value []byte, ttl time.Duration) error {
+	if u.unavailable {
+		return fmt.Errorf("connection refused")
+	}
+	return u.StateStore.Set(key, value, ttl)
+}
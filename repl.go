@@ -0,0 +1,172 @@
+package security
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// replPayloadFields contains the payload fields of each request type of the request model in the order they are
+// given on a REPL line.
+var replPayloadFields = map[string][]string{
+	"env":                             {"name", "value"},
+	"pty":                             {"term"},
+	"exec":                            {"command"},
+	"shell":                           {},
+	"subsystem":                       {"subsystem"},
+	"signal":                          {"signal"},
+	"x11-req":                         {"authProtocol", "singleConnection"},
+	"break":                           {"length"},
+	"direct-tcpip":                    {"host", "port"},
+	"tcpip-forward":                   {"address", "port"},
+	"direct-streamlocal@openssh.com":  {"socketPath"},
+	"streamlocal-forward@openssh.com": {"socketPath"},
+}
+
+const replHelp = `Enter one request per line: TYPE [FIELD...] [as user=NAME groups=GROUP,...]
+Request types and their fields:
+  env NAME VALUE
+  pty TERM
+  exec COMMAND
+  shell
+  subsystem NAME
+  signal NAME
+  x11-req AUTH-PROTOCOL [SINGLE-CONNECTION]
+  break LENGTH
+  direct-tcpip HOST PORT
+  tcpip-forward ADDRESS PORT
+  direct-streamlocal@openssh.com SOCKET-PATH
+  streamlocal-forward@openssh.com SOCKET-PATH
+Words are quoted as in a shell, e.g. exec "rsync -a /src host:/dst" as user=backup. Enter quit to exit.
+`
+
+// RunREPL reads pseudo-requests from in, one per line, evaluates them with the evaluator and writes the decisions to
+// out. It is meant for debugging policies interactively. Each line contains a request type of the request model, see
+// Evaluate, followed by the payload fields in the order they are listed there and optionally by the word as and the
+// user and groups to evaluate the request for:
+//
+//	exec "rsync -a /src host:/dst" as user=backup groups=operators,backup
+//
+// The words are quoted as in a POSIX shell, without expansions. The prompt is written before each line. Lines that
+// can't be parsed or evaluated are reported on out. The loop ends when in is exhausted or a quit line is read, an
+// error is only returned if reading or writing fails.
+//goland:noinspection GoUnusedExportedFunction
+func RunREPL(evaluator *Evaluator, in io.Reader, out io.Writer, prompt string) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		if _, err := io.WriteString(out, prompt); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		var result string
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == "quit" || line == "exit":
+			return nil
+		case line == "help":
+			result = replHelp
+		default:
+			result = evaluateREPLLine(evaluator, line)
+		}
+		if _, err := io.WriteString(out, result); err != nil {
+			return err
+		}
+	}
+}
+
+// evaluateREPLLine evaluates a REPL line and returns the decision in the output format of RunREPL.
+func evaluateREPLLine(evaluator *Evaluator, line string) string {
+	request, groups, err := parseREPLLine(line)
+	if err != nil {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+	if len(groups) > 0 {
+		evaluator = evaluator.WithGroups(groups...)
+	}
+	decision, err := evaluator.Evaluate(request)
+	if err != nil {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+	result := &strings.Builder{}
+	outcome := "denied"
+	if decision.Allowed {
+		outcome = "allowed"
+	}
+	_, _ = fmt.Fprintf(result, "%s: %s\n", outcome, request.RequestType)
+	for _, field := range replPayloadFields[request.RequestType] {
+		if value, ok := request.Payload[field]; ok {
+			_, _ = fmt.Fprintf(result, "  %s: %q\n", field, value)
+		}
+	}
+	if request.Username != "" {
+		_, _ = fmt.Fprintf(result, "  user: %s\n", request.Username)
+	}
+	if len(groups) > 0 {
+		_, _ = fmt.Fprintf(result, "  groups: %s\n", strings.Join(groups, ","))
+	}
+	_, _ = fmt.Fprintf(result, "  mode: %s\n", decision.Mode)
+	_, _ = fmt.Fprintf(result, "  reason: %s\n", decision.Reason)
+	if decision.Message != "" {
+		_, _ = fmt.Fprintf(result, "  message: %s\n", decision.Message)
+	}
+	if decision.Flagged {
+		_, _ = fmt.Fprintf(result, "  flagged: denied in filter mode\n")
+	}
+	return result.String()
+}
+
+// parseREPLLine parses a REPL line into a request and the groups of the user. The principal starts at the last as
+// word followed only by name=value words, so fields may contain the word as.
+func parseREPLLine(line string) (DecisionRequest, []string, error) {
+	words, err := splitShellWords(line)
+	if err != nil {
+		return DecisionRequest{}, nil, fmt.Errorf("failed to parse request (%w)", err)
+	}
+	request := DecisionRequest{RequestType: words[0], Payload: map[string]string{}}
+	fields, ok := replPayloadFields[request.RequestType]
+	if !ok {
+		return DecisionRequest{}, nil, fmt.Errorf("unsupported request type: %s", request.RequestType)
+	}
+	args := words[1:]
+	var principal []string
+	for i := len(args) - 2; i >= 0 && strings.Contains(args[i+1], "="); i-- {
+		if args[i] == "as" {
+			args, principal = args[:i], args[i+1:]
+			break
+		}
+	}
+	if len(args) > len(fields) {
+		return DecisionRequest{}, nil, fmt.Errorf(
+			"too many fields for %s, expected %d (quote values containing spaces)",
+			request.RequestType,
+			len(fields),
+		)
+	}
+	for i, arg := range args {
+		request.Payload[fields[i]] = arg
+	}
+	var groups []string
+	for _, attribute := range principal {
+		parts := strings.SplitN(attribute, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return DecisionRequest{}, nil, fmt.Errorf("invalid principal attribute, expected name=value: %s", attribute)
+		}
+		switch parts[0] {
+		case "user":
+			request.Username = parts[1]
+		case "groups":
+			groups = strings.Split(parts[1], ",")
+		default:
+			return DecisionRequest{}, nil, fmt.Errorf(
+				"unsupported principal attribute, the policy only depends on user and groups: %s",
+				parts[0],
+			)
+		}
+	}
+	return request, groups, nil
+}
@@ -0,0 +1,81 @@
+package security
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREPL(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		DefaultMode: ExecutionPolicyDisable,
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"/usr/bin/uptime"},
+		},
+		Users: map[string]Config{
+			"backup": {
+				Command: CommandConfig{Allow: []string{"rsync -a /src host:/dst"}},
+			},
+		},
+		Groups: map[string]Config{
+			"admins": {Shell: ShellConfig{Mode: ExecutionPolicyEnable}},
+		},
+	})
+	assert.NoError(t, err)
+
+	in := strings.Join([]string{
+		`exec "rsync -a /src host:/dst" as user=backup`,
+		`exec "rsync -a /src host:/dst" as user=alice`,
+		"",
+		"# comment",
+		"shell as user=alice groups=admins",
+		"direct-tcpip db 5432",
+		"exec 'echo as' as user=alice",
+		"exec /usr/bin/uptime as ip=10.1.2.3",
+		"exec uptime now",
+		"launch rocket",
+		"quit",
+		"shell",
+	}, "\n")
+	out := &bytes.Buffer{}
+	assert.NoError(t, RunREPL(evaluator, strings.NewReader(in), out, ""))
+	assert.Equal(t, strings.Join([]string{
+		"allowed: exec",
+		`  command: "rsync -a /src host:/dst"`,
+		"  user: backup",
+		"  mode: filter",
+		"  reason: in-allowlist",
+		"  message: the command is on the allow list",
+		"denied: exec",
+		`  command: "rsync -a /src host:/dst"`,
+		"  user: alice",
+		"  mode: filter",
+		"  reason: not-in-allowlist",
+		"  message: the command is not on the allow list",
+		"allowed: shell",
+		"  user: alice",
+		"  groups: admins",
+		"  mode: enable",
+		"  reason: mode-enabled",
+		"  message: shell execution is enabled",
+		"denied: direct-tcpip",
+		`  host: "db"`,
+		`  port: "5432"`,
+		"  mode: disable",
+		"  reason: denied-by-default-mode",
+		"  message: port forwarding is disabled",
+		"denied: exec",
+		`  command: "echo as"`,
+		"  user: alice",
+		"  mode: filter",
+		"  reason: not-in-allowlist",
+		"  message: the command is not on the allow list",
+		"error: unsupported principal attribute, the policy only depends on user and groups: ip",
+		"error: too many fields for exec, expected 1 (quote values containing spaces)",
+		"error: unsupported request type: launch",
+		"",
+	}, "\n"), out.String())
+}
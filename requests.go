@@ -0,0 +1,84 @@
+package security
+
+import (
+	"bytes"
+	"encoding/hex"
+	"time"
+)
+
+// Validate validates a request configuration.
+func (r RequestConfig) Validate() error {
+	v := &validator{}
+	r.validate(v, "")
+	return v.result()
+}
+
+func (r RequestConfig) validate(v *validator, path string) {
+	validateMode(v, field(path, "mode"), r.Mode, false)
+	for i, prefix := range r.Allow {
+		_, err := hex.DecodeString(prefix)
+		v.check(index(field(path, "allow"), i), ValidationCodeInvalidPattern, err)
+	}
+	for i, prefix := range r.Deny {
+		_, err := hex.DecodeString(prefix)
+		v.check(index(field(path, "deny"), i), ValidationCodeInvalidPattern, err)
+	}
+}
+
+// matchPayload returns true if the payload starts with any of the hex-encoded prefixes.
+func matchPayload(prefixes []string, payload []byte) bool {
+	for _, prefix := range prefixes {
+		decoded, err := hex.DecodeString(prefix)
+		if err != nil {
+			continue
+		}
+		if bytes.HasPrefix(payload, decoded) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowRequest decides whether a request of a type not covered by any other section is passed to the backend.
+func allowRequest(policy ExecutionPolicy, config RequestConfig, payload []byte) bool {
+	switch policy {
+	case ExecutionPolicyDisable:
+		return false
+	case ExecutionPolicyFilter:
+		return matchPayload(config.Allow, payload)
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		return !matchPayload(config.Deny, payload)
+	}
+}
+
+func (s *sessionHandler) OnUnsupportedChannelRequest(requestID uint64, requestType string, payload []byte) {
+	start := time.Now()
+	if !s.allowChannelRequest(requestID, requestType, payload) {
+		s.sshConnection.padDenial(start)
+		return
+	}
+	s.backend.OnUnsupportedChannelRequest(requestID, requestType, payload)
+}
+
+// allowChannelRequest returns true if the backend should be notified of the channel request. The SSH server rejects
+// the request towards the client regardless of the policy.
+func (s *sessionHandler) allowChannelRequest(requestID uint64, requestType string, payload []byte) bool {
+	config := s.config.Requests[requestType]
+	mode := s.config.effectivePolicy(config.Mode, s.config.Defaults.ChannelRequests)
+	if !allowRequest(mode, config, payload) {
+		return false
+	}
+	switch requestType {
+	case "x11-req":
+		// The payload contains the X11 authentication cookie, allowX11 audits the request without it.
+		return s.allowX11(requestID, mode, payload)
+	case "break":
+		return s.allowBreak(requestID, mode, payload)
+	}
+	err := s.audit(mode, requestID, requestType, map[string]string{"payload": hex.EncodeToString(payload)})
+	return err == nil
+}
@@ -0,0 +1,80 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomRequests(t *testing.T) {
+	backend := &requestBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Requests: map[string]RequestConfig{
+				"hostkeys-prove-00@openssh.com": {
+					Mode: ExecutionPolicyDisable,
+				},
+				"vendor@example.com": {
+					Mode:  ExecutionPolicyFilter,
+					Allow: []string{"00000004"},
+				},
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.config.Validate())
+
+	session.OnUnsupportedChannelRequest(1, "hostkeys-prove-00@openssh.com", []byte{})
+	session.OnUnsupportedChannelRequest(2, "vendor@example.com", []byte{0, 0, 0, 4, 't', 'e', 's', 't'})
+	session.OnUnsupportedChannelRequest(3, "vendor@example.com", []byte{0, 0, 0, 5, 't', 'e', 's', 't', 's'})
+	session.OnUnsupportedChannelRequest(4, "other@example.com", []byte{})
+	assert.Equal(t, []string{"vendor@example.com", "other@example.com"}, backend.requests)
+
+	backend.requests = nil
+	session.config.DefaultMode = ExecutionPolicyDisable
+	session.OnUnsupportedChannelRequest(5, "other@example.com", []byte{})
+	assert.Empty(t, backend.requests)
+
+	assert.Error(t, Config{Requests: map[string]RequestConfig{"foo": {Allow: []string{"xyz"}}}}.Validate())
+}
+
+func TestGlobalRequests(t *testing.T) {
+	backend := &globalRequestBackend{}
+	connection := &sshConnectionHandler{
+		config: Config{
+			GlobalRequests: map[string]RequestConfig{
+				"tcpip-forward": {
+					Mode: ExecutionPolicyDisable,
+				},
+			},
+		},
+		backend: backend,
+		lock:    &sync.Mutex{},
+	}
+
+	connection.OnUnsupportedGlobalRequest(1, "tcpip-forward", []byte{})
+	connection.OnUnsupportedGlobalRequest(2, "keepalive@openssh.com", []byte{})
+	assert.Equal(t, []string{"keepalive@openssh.com"}, backend.requests)
+}
+
+type globalRequestBackend struct {
+	dummySSHBackend
+	requests []string
+}
+
+func (g *globalRequestBackend) OnUnsupportedGlobalRequest(_ uint64, requestType string, _ []byte) {
+	g.requests = append(g.requests, requestType)
+}
+
+type requestBackend struct {
+	dummyBackend
+	requests []string
+}
+
+func (r *requestBackend) OnUnsupportedChannelRequest(_ uint64, requestType string, _ []byte) {
+	r.requests = append(r.requests, requestType)
+}
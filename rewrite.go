@@ -0,0 +1,38 @@
+package security
+
+import (
+	"strings"
+)
+
+// rewrite replaces the program name of the command according to Rewrite. The command is split into words the way a
+// shell would, so quoting or escaping the program name doesn't bypass the rewrite, and the arguments are quoted again.
+// Commands that can't be split are returned unchanged, EvaluateExec rejects them if Rewrite is configured.
+func (c CommandConfig) rewrite(program string) string {
+	if len(c.Rewrite) == 0 {
+		return program
+	}
+	words, err := splitShellWords(program)
+	if err != nil || len(words) == 0 {
+		return program
+	}
+	replacement, ok := c.Rewrite[words[0]]
+	if !ok {
+		return program
+	}
+	command := replacement
+	for _, argument := range words[1:] {
+		command += " " + shellQuote(argument)
+	}
+	return command
+}
+
+func validateRewrite(v *validator, path string, rewrite map[string]string) {
+	for name, replacement := range rewrite {
+		if name == "" || strings.ContainsAny(name, " \t") {
+			v.fail(key(path, name), ValidationCodeInvalidFormat, "invalid program name: %s", name)
+		}
+		if strings.TrimSpace(replacement) == "" {
+			v.fail(key(path, name), ValidationCodeInvalidFormat, "no replacement specified for %s", name)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandRewrite(t *testing.T) {
+	backend := &dummyBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Command: CommandConfig{
+				Mode: ExecutionPolicyFilter,
+				Allow: []string{
+					"git-upload-pack 'repo.git'",
+					"git-upload-pack;id",
+					"ls",
+					"'git-upload-pack' '/r'",
+					`\git-upload-pack "it's.git"`,
+					"git-upload-pack\nid",
+				},
+				Rewrite: map[string]string{
+					"git-upload-pack": "/usr/local/bin/audited-git-upload-pack",
+				},
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnExecRequest(1, "git-upload-pack 'repo.git'"))
+	assert.Error(t, session.OnExecRequest(2, "git-upload-pack;id"))
+	assert.NoError(t, session.OnExecRequest(3, "ls"))
+	assert.Error(t, session.OnExecRequest(4, "/usr/local/bin/audited-git-upload-pack 'repo.git'"))
+	assert.NoError(t, session.OnExecRequest(5, "'git-upload-pack' '/r'"))
+	assert.NoError(t, session.OnExecRequest(6, `\git-upload-pack "it's.git"`))
+	assert.Error(t, session.OnExecRequest(7, "git-upload-pack\nid"))
+	assert.Equal(t, []string{
+		"/usr/local/bin/audited-git-upload-pack 'repo.git'",
+		"ls",
+		"/usr/local/bin/audited-git-upload-pack '/r'",
+		`/usr/local/bin/audited-git-upload-pack 'it'\''s.git'`,
+	}, backend.commandsExecuted)
+
+	assert.Error(t, CommandConfig{Rewrite: map[string]string{"git upload-pack": "/bin/true"}}.Validate())
+	assert.Error(t, CommandConfig{Rewrite: map[string]string{"git-upload-pack": ""}}.Validate())
+}
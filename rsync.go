@@ -0,0 +1,221 @@
+package security
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// RsyncDirection is the direction of an rsync transfer as seen from the client.
+type RsyncDirection string
+
+const (
+	// RsyncDirectionPush is a transfer from the client to the server.
+	RsyncDirectionPush RsyncDirection = "push"
+
+	// RsyncDirectionPull is a transfer from the server to the client.
+	RsyncDirectionPull RsyncDirection = "pull"
+)
+
+// Validate validates the rsync direction setting.
+func (r RsyncDirection) Validate() error {
+	switch r {
+	case "":
+	case RsyncDirectionPush:
+	case RsyncDirectionPull:
+	default:
+		return fmt.Errorf("invalid rsync direction: %s", r)
+	}
+	return nil
+}
+
+// rsyncUnsafeCharacters are the characters that make the arguments of a command depend on shell parsing.
+const rsyncUnsafeCharacters = "\"'\\`$;|&<>(){}*?[]~\n"
+
+// rsyncPathOptions are the rsync server options whose value is a path on the server. Their values are checked like
+// the transfer paths.
+var rsyncPathOptions = []string{
+	"--backup-dir",
+	"--compare-dest",
+	"--copy-dest",
+	"--exclude-from",
+	"--files-from",
+	"--include-from",
+	"--link-dest",
+	"--log-file",
+	"--only-write-batch",
+	"--partial-dir",
+	"--read-batch",
+	"--temp-dir",
+	"--write-batch",
+}
+
+// rsyncInvocation is a parsed rsync --server command.
+type rsyncInvocation struct {
+	direction RsyncDirection
+	paths     []string
+	daemon    bool
+	// valid is false if the command could not be parsed unambiguously.
+	valid bool
+	// separator is the index of the "." field separating the options from the paths.
+	separator int
+}
+
+// parse parses the command executed on the server by an rsync client. The second return value is false if the
+// command is not an rsync server command. Only commands running a bare rsync or one of Executables are rsync server
+// commands.
+func (r RsyncConfig) parse(program string) (rsyncInvocation, bool) {
+	fields := strings.Fields(program)
+	if len(fields) < 2 || !r.isExecutable(fields[0]) || fields[1] != "--server" {
+		return rsyncInvocation{}, false
+	}
+	invocation := rsyncInvocation{direction: RsyncDirectionPush}
+	if strings.ContainsAny(program, rsyncUnsafeCharacters) {
+		return invocation, true
+	}
+	var optionPaths []string
+	for i := 2; i < len(fields); i++ {
+		arg := fields[i]
+		switch {
+		case arg == "--sender":
+			invocation.direction = RsyncDirectionPull
+		case arg == "--daemon":
+			invocation.daemon = true
+		case arg == ".":
+			invocation.paths = append(fields[i+1:len(fields):len(fields)], optionPaths...)
+			invocation.valid = len(fields) > i+1
+			invocation.separator = i
+			return invocation, true
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := cutOption(arg)
+			if !isRsyncPathOption(name) {
+				continue
+			}
+			if !hasValue {
+				if i+1 >= len(fields) {
+					return invocation, true
+				}
+				i++
+				value = fields[i]
+			}
+			if name != "--files-from" || value != "-" {
+				optionPaths = append(optionPaths, value)
+			}
+		case strings.HasPrefix(arg, "-"):
+			// The short options before e (the protocol details) may be bundled; -T is --temp-dir, the value of which
+			// can't be told apart from further options here.
+			flags := arg[1:]
+			if end := strings.IndexByte(flags, 'e'); end >= 0 {
+				flags = flags[:end]
+			}
+			if strings.ContainsRune(flags, 'T') {
+				return invocation, true
+			}
+		}
+	}
+	return invocation, true
+}
+
+func (r RsyncConfig) isExecutable(program string) bool {
+	if program == "rsync" {
+		return true
+	}
+	for _, executable := range r.Executables {
+		if program == executable {
+			return true
+		}
+	}
+	return false
+}
+
+func cutOption(arg string) (name string, value string, hasValue bool) {
+	if i := strings.IndexByte(arg, '='); i >= 0 {
+		return arg[:i], arg[i+1:], true
+	}
+	return arg, "", false
+}
+
+func isRsyncPathOption(name string) bool {
+	for _, option := range rsyncPathOptions {
+		if name == option {
+			return true
+		}
+	}
+	return false
+}
+
+// withinRsyncPaths returns true if the path is within one of the specified paths.
+func withinRsyncPaths(paths []string, p string) bool {
+	cleaned := path.Clean(p)
+	for _, entry := range paths {
+		entry = path.Clean(entry)
+		switch {
+		case entry == ".":
+			if !path.IsAbs(cleaned) && cleaned != ".." && !strings.HasPrefix(cleaned, "../") {
+				return true
+			}
+		case entry == "/":
+			if path.IsAbs(cleaned) {
+				return true
+			}
+		case cleaned == entry || strings.HasPrefix(cleaned, entry+"/"):
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Evaluator) evaluateRsync(mode ExecutionPolicy, invocation rsyncInvocation) Decision {
+	config := e.config.Rsync
+	if mode == ExecutionPolicyDisable {
+		return deny(mode, DecisionReasonModeDisabled, "rsync is disabled")
+	}
+	if invocation.daemon {
+		return deny(mode, DecisionReasonUnparseableCommand, "rsync daemon mode is not supported")
+	}
+	if !invocation.valid {
+		return deny(mode, DecisionReasonUnparseableCommand, "the rsync command could not be parsed")
+	}
+	if config.Direction != "" && invocation.direction != config.Direction {
+		return deny(mode, DecisionReasonDirectionNotAllowed, fmt.Sprintf("rsync %s is not allowed", invocation.direction))
+	}
+	switch mode {
+	case ExecutionPolicyFilter:
+		for _, p := range invocation.paths {
+			if !withinRsyncPaths(config.Allow, p) {
+				return deny(mode, DecisionReasonNotInAllowList, "the rsync path is not on the allow list")
+			}
+		}
+		return allow(mode, DecisionReasonInAllowList, "the rsync paths are on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		for _, p := range invocation.paths {
+			if withinRsyncPaths(config.Deny, p) {
+				return deny(mode, DecisionReasonMatchedDenyList, "the rsync path is on the deny list")
+			}
+		}
+		return allow(mode, DecisionReasonModeEnabled, "rsync is enabled")
+	}
+}
+
+// rsyncCommand returns the command to pass to the backend for the program, adding --max-size to rsync pushes if
+// Rsync.MaxFileSize is set. The limit is added after the options of the client so that it overrides a --max-size
+// option sent by the client.
+func (c Config) rsyncCommand(program string) string {
+	if c.Rsync.Mode == ExecutionPolicyUnconfigured || c.Rsync.MaxFileSize <= 0 {
+		return program
+	}
+	invocation, ok := c.Rsync.parse(program)
+	if !ok || !invocation.valid || invocation.direction != RsyncDirectionPush {
+		return program
+	}
+	fields := strings.Fields(program)
+	limit := "--max-size=" + strconv.FormatInt(c.Rsync.MaxFileSize, 10)
+	result := append([]string{}, fields[:invocation.separator]...)
+	result = append(result, limit)
+	return strings.Join(append(result, fields[invocation.separator:]...), " ")
+}
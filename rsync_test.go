@@ -0,0 +1,94 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRsync(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		Command: CommandConfig{Mode: ExecutionPolicyDisable},
+		Rsync: RsyncConfig{
+			Mode:        ExecutionPolicyFilter,
+			Executables: []string{"/usr/bin/rsync"},
+			Direction:   RsyncDirectionPush,
+			Allow:       []string{"/srv/upload", "."},
+		},
+	})
+	assert.NoError(t, err)
+	for _, testCase := range []struct {
+		command string
+		reason  DecisionReason
+	}{
+		{"rsync --server -vlogDtpre.iLsfxC . /srv/upload/", DecisionReasonInAllowList},
+		{"/usr/bin/rsync --server -vlogDtpre.iLsfxC . backups/today", DecisionReasonInAllowList},
+		{"rsync --server -vlogDtpre.iLsfxC . /srv/upload/../../etc", DecisionReasonNotInAllowList},
+		{"rsync --server -vlogDtpre.iLsfxC . ../other", DecisionReasonNotInAllowList},
+		{"rsync --server --sender -vlogDtpre.iLsfxC . /srv/upload", DecisionReasonDirectionNotAllowed},
+		{"rsync --server -vlogDtpre.iLsfxC . '/srv/upload/a b'", DecisionReasonUnparseableCommand},
+		{"rsync --server -vlogDtpre.iLsfxC . /srv/upload; id", DecisionReasonUnparseableCommand},
+		{"rsync --server --daemon .", DecisionReasonUnparseableCommand},
+		{"rsync --version", DecisionReasonModeDisabled},
+		{"/tmp/rsync --server -vlogDtpre.iLsfxC . /srv/upload/", DecisionReasonModeDisabled},
+		{"./rsync --server -vlogDtpre.iLsfxC . /srv/upload/", DecisionReasonModeDisabled},
+		{"rsync --server --temp-dir=/srv/upload/tmp -vlogDtpre.iLsfxC . /srv/upload", DecisionReasonInAllowList},
+		{"rsync --server --temp-dir=/tmp -vlogDtpre.iLsfxC . /srv/upload", DecisionReasonNotInAllowList},
+		{"rsync --server --log-file /etc/cron.d/x -vlogDtpre.iLsfxC . /srv/upload", DecisionReasonNotInAllowList},
+		{"rsync --server --files-from=- -vlogDtpre.iLsfxC . /srv/upload", DecisionReasonInAllowList},
+		{"rsync --server --files-from=/etc/shadow -vlogDtpre.iLsfxC . /srv/upload", DecisionReasonNotInAllowList},
+		{"rsync --server -vT/tmp -vlogDtpre.iLsfxC . /srv/upload", DecisionReasonUnparseableCommand},
+	} {
+		assert.Equal(t, testCase.reason, evaluator.EvaluateExec(testCase.command).Reason, testCase.command)
+	}
+
+	assert.Error(t, RsyncConfig{Direction: "sideways"}.Validate())
+	assert.Error(t, RsyncConfig{Executables: []string{"bin/rsync"}}.Validate())
+	assert.Error(t, RsyncConfig{Mode: ExecutionPolicyPrompt}.Validate())
+}
+
+func TestRsyncMaxFileSize(t *testing.T) {
+	backend := &dummyBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Rsync: RsyncConfig{
+				Mode:        ExecutionPolicyEnable,
+				Deny:        []string{"/etc"},
+				MaxFileSize: 1024,
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnExecRequest(1, "rsync --server -logDtpre.iLsfxC . upload"))
+	assert.NoError(t, session.OnExecRequest(2, "rsync --server --sender -logDtpre.iLsfxC . download"))
+	assert.Error(t, session.OnExecRequest(3, "rsync --server --sender -logDtpre.iLsfxC . /etc/shadow"))
+	assert.NoError(t, session.OnExecRequest(4, "rsync --server --max-size=1G -logDtpre.iLsfxC . upload"))
+	assert.Equal(t, []string{
+		"rsync --server -logDtpre.iLsfxC --max-size=1024 . upload",
+		"rsync --server --sender -logDtpre.iLsfxC . download",
+		"rsync --server --max-size=1G -logDtpre.iLsfxC --max-size=1024 . upload",
+	}, backend.commandsExecuted)
+}
+
+func TestRsyncRequiresCommandFilter(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"rsync --server -vlogDtpre.iLsfxC . /srv/upload"},
+		},
+		Rsync: RsyncConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"/srv"},
+		},
+	})
+	assert.NoError(t, err)
+	decision := evaluator.EvaluateExec("rsync --server -vlogDtpre.iLsfxC . /srv/upload")
+	assert.True(t, decision.Allowed)
+	decision = evaluator.EvaluateExec("rsync --server -vlogDtpre.iLsfxC . /srv/other")
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, DecisionReasonNotInAllowList, decision.Reason)
+}
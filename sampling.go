@@ -0,0 +1,82 @@
+package security
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// sampler selects allowed requests to report to the audit stream and keeps track of the byte budget shared by all
+// connections.
+type sampler struct {
+	config      SamplingConfig
+	lock        *sync.Mutex
+	windowStart time.Time
+	bytes       int
+	now         func() time.Time
+	random      func() float64
+}
+
+func newSampler(config SamplingConfig) *sampler {
+	return &sampler{
+		config: config,
+		lock:   &sync.Mutex{},
+		now:    time.Now,
+		random: rand.Float64,
+	}
+}
+
+// sample returns true if the request should be reported.
+func (s *sampler) sample(requestType string, payload map[string]string) bool {
+	if s == nil {
+		return false
+	}
+	rate, ok := s.config.Rates[requestType]
+	if !ok {
+		rate = s.config.Rate
+	}
+	if rate <= 0 || s.random() >= rate {
+		return false
+	}
+	if s.config.BytesPerMinute <= 0 {
+		return true
+	}
+	size := len(requestType)
+	for name, value := range payload {
+		size += len(name) + len(value)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart = now
+		s.bytes = 0
+	}
+	if s.bytes+size > s.config.BytesPerMinute {
+		return false
+	}
+	s.bytes += size
+	return true
+}
+
+// sample reports an allowed request to the backend if it is selected by the sampler. Errors are ignored, sampling
+// never influences the decision.
+func (s *sshConnectionHandler) sample(
+	backend interface{},
+	requestID uint64,
+	requestType string,
+	payload map[string]string,
+) {
+	auditHandler, ok := backend.(AuditHandler)
+	if !ok || !s.sampler.sample(requestType, payload) {
+		return
+	}
+	_ = auditHandler.OnAuditedRequest(AuditedRequest{
+		SchemaVersion: AuditSchemaVersion,
+		RequestID:     requestID,
+		Username:      s.username,
+		RequestType:   requestType,
+		Payload:       payload,
+		Sampled:       true,
+	})
+}
@@ -0,0 +1,56 @@
+package security
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampling(t *testing.T) {
+	now := time.Now()
+	sampler := newSampler(SamplingConfig{
+		Rate:           0.5,
+		Rates:          map[string]float64{"env": 0},
+		BytesPerMinute: 120,
+	})
+	sampler.now = func() time.Time { return now }
+	random := 0.1
+	sampler.random = func() float64 { return random }
+
+	backend := &auditBackend{}
+	session := &sessionHandler{
+		config:  Config{},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			username: "foo",
+			sampler:  sampler,
+			lock:     &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnEnvRequest(1, "LANG", "C"))
+	assert.NoError(t, session.OnExecRequest(2, "/bin/ls"))
+	random = 0.9
+	assert.NoError(t, session.OnExecRequest(3, "/bin/ls"))
+	random = 0.1
+	// The byte budget of 120 is exhausted after the first exec (4 + 7 + 7 + 11 + 71 bytes).
+	assert.NoError(t, session.OnExecRequest(4, "/bin/ls"))
+	assert.Equal(t, []AuditedRequest{
+		{
+			SchemaVersion: AuditSchemaVersion,
+			RequestID:     2,
+			Username:      "foo",
+			RequestType:   "exec",
+			Payload:       map[string]string{"command": "/bin/ls", "commandHash": CommandHash("/bin/ls")},
+			Sampled:       true,
+		},
+	}, backend.requests)
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, session.OnExecRequest(5, "/bin/ls"))
+	assert.Len(t, backend.requests, 2)
+
+	assert.Error(t, SamplingConfig{Rate: 1.5}.Validate())
+	assert.Error(t, SamplingConfig{Rates: map[string]float64{"exec": -1}}.Validate())
+}
@@ -0,0 +1,132 @@
+package security
+
+import (
+	"fmt"
+	"path"
+)
+
+// SecurityContext describes the policy applied to a session. It is handed to session backends implementing
+// SecurityContextHandler and can be serialized and passed on to the components starting the program, e.g. container
+// orchestrators or process spawners, so they can enforce the same limits without reading the policy.
+type SecurityContext struct {
+	// AuditID is the ID of the connection assigned by the SSH server, which identifies it in the audit logs.
+	AuditID string `json:"auditId"`
+	// Username is the name of the authenticated user.
+	Username string `json:"username"`
+	// RequestType is the type of the request the program is started for: exec, shell or subsystem. Forced commands
+	// are started as exec requests.
+	RequestType string `json:"requestType"`
+	// Program is the approved command line for exec requests and the name of the subsystem for subsystem requests.
+	// It is empty for shell requests.
+	Program string `json:"program"`
+	// Tenant is the tenant the policy belongs to, see Config.Tenant.
+	Tenant string `json:"tenant,omitempty"`
+	// Fingerprint identifies the policy applied to the session.
+	Fingerprint string `json:"fingerprint"`
+	// Capabilities contains the effective execution policy of the session request types.
+	Capabilities Capabilities `json:"capabilities"`
+	// MaxSessions is the number of sessions permitted per connection, -1 means unlimited.
+	MaxSessions int `json:"maxSessions"`
+	// ConfineToHome is true if paths referenced by commands are confined to the home directory of the user.
+	ConfineToHome bool `json:"confineToHome"`
+	// SFTP contains the restrictions of the sftp subsystem.
+	SFTP SFTPConfig `json:"sftp"`
+	// Egress contains the network destinations the program may connect to.
+	Egress EgressConfig `json:"egress"`
+	// Process contains the constraints for the program, with the working directory resolved for the user.
+	Process ProcessConfig `json:"process"`
+	// HardenEnvironment is true if the program must be started with the hardened environment, see
+	// EnvConfig.HardenEnvironment and SanitizeEnvironment.
+	HardenEnvironment bool `json:"hardenEnvironment"`
+	// AllowDangerousEnv is true if variables such as LD_PRELOAD may be passed to the program, see
+	// EnvConfig.AllowDangerous and SanitizeEnvironment.
+	AllowDangerousEnv bool `json:"allowDangerousEnv"`
+}
+
+// SecurityContextHandler is an optional interface for session channel backends. Backends implementing it receive the
+// security context of the session before a program is started.
+type SecurityContextHandler interface {
+	// OnSecurityContext receives the security context for the program about to be started. If an error is returned
+	// the program is not started.
+	OnSecurityContext(requestID uint64, context SecurityContext) error
+}
+
+// securityContext returns the security context of the session for the approved program.
+func (s *sessionHandler) securityContext(requestType string) (SecurityContext, error) {
+	process, err := s.config.Process.forUser(s.sshConnection.username)
+	if err != nil {
+		return SecurityContext{}, err
+	}
+	sftp, err := s.config.sftpFor(s.sshConnection.username)
+	if err != nil {
+		return SecurityContext{}, err
+	}
+	return SecurityContext{
+		AuditID:           s.sshConnection.connectionID,
+		Username:          s.sshConnection.username,
+		RequestType:       requestType,
+		Program:           s.approvedProgram,
+		Tenant:            s.config.Tenant,
+		Fingerprint:       s.config.Fingerprint(),
+		Capabilities:      s.config.EffectiveCapabilities(),
+		MaxSessions:       s.config.MaxSessions,
+		ConfineToHome:     s.config.ConfineToHome,
+		SFTP:              sftp,
+		Egress:            s.config.Egress,
+		Process:           process,
+		HardenEnvironment: s.config.Env.HardenEnvironment,
+		AllowDangerousEnv: s.config.Env.AllowDangerous,
+	}, nil
+}
+
+// applySecurityContext hands the security context for the approved program to the backend if it implements
+// SecurityContextHandler.
+func (s *sessionHandler) applySecurityContext(requestID uint64, requestType string) error {
+	contextHandler, ok := s.backend.(SecurityContextHandler)
+	if !ok {
+		return nil
+	}
+	context, err := s.securityContext(requestType)
+	if err != nil {
+		return fmt.Errorf("failed to apply security context")
+	}
+	if err := contextHandler.OnSecurityContext(requestID, context); err != nil {
+		return fmt.Errorf("failed to apply security context")
+	}
+	return nil
+}
+
+// secexecShells are the shells a backend may use to run the approved command line of an exec request.
+var secexecShells = []string{"sh", "bash", "dash", "ash", "ksh", "zsh"}
+
+// Permits returns true if args, the program and its arguments, run the program approved for the request. For exec
+// requests args must be the words of the approved command line or a shell running it with -c. Shell and subsystem
+// requests are always permitted since the backend chooses the program to run.
+func (c SecurityContext) Permits(args []string) bool {
+	switch c.RequestType {
+	case "exec":
+		if len(args) == 3 && args[1] == "-c" && args[2] == c.Program {
+			for _, shell := range secexecShells {
+				if path.Base(args[0]) == shell {
+					return true
+				}
+			}
+		}
+		words, err := splitShellWords(c.Program)
+		if err != nil || len(words) != len(args) {
+			return false
+		}
+		for i, word := range words {
+			if args[i] != word {
+				return false
+			}
+		}
+		return true
+	case "shell":
+		return true
+	case "subsystem":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityContext(t *testing.T) {
+	backend := &securityContextBackend{}
+	config := Config{
+		Tenant:      "acme",
+		MaxSessions: 2,
+		Shell:       ShellConfig{Mode: ExecutionPolicyDisable},
+		SFTP:        SFTPConfig{ReadOnly: true},
+	}
+	session := &sessionHandler{
+		config:  config,
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			connectionID: "0123456789abcdef",
+			username:     "foo",
+			lock:         &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnExecRequest(1, "/bin/ls"))
+	assert.Equal(t, "0123456789abcdef", backend.context.AuditID)
+	assert.Equal(t, "foo", backend.context.Username)
+	assert.Equal(t, "exec", backend.context.RequestType)
+	assert.Equal(t, "/bin/ls", backend.context.Program)
+	assert.Equal(t, "acme", backend.context.Tenant)
+	assert.Equal(t, config.Fingerprint(), backend.context.Fingerprint)
+	assert.Equal(t, ExecutionPolicyDisable, backend.context.Capabilities.Shell)
+	assert.Equal(t, 2, backend.context.MaxSessions)
+	assert.True(t, backend.context.SFTP.ReadOnly)
+
+	data, err := json.Marshal(backend.context)
+	assert.NoError(t, err)
+	decoded := SecurityContext{}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, backend.context, decoded)
+
+	backend.err = fmt.Errorf("no capacity")
+	assert.Error(t, session.OnExecRequest(2, "/bin/ls"))
+	assert.Equal(t, []string{"/bin/ls"}, backend.commandsExecuted)
+}
+
+type securityContextBackend struct {
+	dummyBackend
+	context SecurityContext
+	err     error
+}
+
+func (s *securityContextBackend) OnSecurityContext(_ uint64, context SecurityContext) error {
+	s.context = context
+	return s.err
+}
+
+func TestSecurityContextProcess(t *testing.T) {
+	backend := &securityContextBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Env:           EnvConfig{HardenEnvironment: true},
+			ConfineToHome: true,
+			Process: ProcessConfig{
+				Umask:            "077",
+				WorkingDirectory: "/srv/{{ .Username }}",
+				MaxOpenFiles:     1024,
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			username: "foo",
+			lock:     &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnExecRequest(1, "/bin/ls"))
+	assert.Equal(t, "/srv/foo", backend.context.Process.WorkingDirectory)
+	assert.Equal(t, "/home/foo", backend.context.SFTP.Root)
+	umask, err := backend.context.Process.ParseUmask()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0077), umask)
+	assert.Equal(t, []string{
+		"LANG=C",
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"IFS= \t\n",
+		"SHELLOPTS=",
+		"BASH_ENV=",
+		"ENV=",
+	}, backend.context.SanitizeEnvironment([]string{"LANG=C", "LD_PRELOAD=/tmp/evil.so", "PATH=/tmp"}))
+
+	assert.Error(t, ProcessConfig{Umask: "999"}.Validate())
+	assert.Error(t, ProcessConfig{MaxMemory: -1}.Validate())
+}
+
+func TestSecurityContextPermits(t *testing.T) {
+	context := SecurityContext{RequestType: "exec", Program: "ls -la 'my files'"}
+	assert.True(t, context.Permits([]string{"ls", "-la", "my files"}))
+	assert.True(t, context.Permits([]string{"/bin/sh", "-c", "ls -la 'my files'"}))
+	assert.False(t, context.Permits([]string{"ls", "-la", "my", "files"}))
+	assert.False(t, context.Permits([]string{"/usr/bin/python3", "-c", "ls -la 'my files'"}))
+	assert.False(t, context.Permits([]string{"rm", "-rf", "/"}))
+	assert.True(t, SecurityContext{RequestType: "shell"}.Permits([]string{"/bin/bash"}))
+	assert.False(t, SecurityContext{}.Permits([]string{"/bin/bash"}))
+}
@@ -0,0 +1,375 @@
+package security
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// SFTP packet types as defined in draft-ietf-secsh-filexfer-02.
+const (
+	sftpInit     = 1
+	sftpOpen     = 3
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpSetstat  = 9
+	sftpFsetstat = 10
+	sftpOpendir  = 11
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRmdir    = 15
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpReadlink = 19
+	sftpSymlink  = 20
+	sftpStatus   = 101
+	sftpExtended = 200
+)
+
+const (
+	sftpFlagWrite  = 0x02
+	sftpFlagAppend = 0x04
+	sftpFlagCreate = 0x08
+	sftpFlagTrunc  = 0x10
+
+	sftpStatusPermissionDenied = 3
+	sftpStatusOpUnsupported    = 8
+
+	// sftpMaxPacketLength is the largest packet accepted from the client, matching the limit of OpenSSH.
+	sftpMaxPacketLength = 256 * 1024
+)
+
+// sftpPathCount contains the number of leading path arguments of the packet types that access the file system by
+// path.
+var sftpPathCount = map[byte]int{
+	sftpOpen:     1,
+	sftpLstat:    1,
+	sftpSetstat:  1,
+	sftpOpendir:  1,
+	sftpRemove:   1,
+	sftpMkdir:    1,
+	sftpRmdir:    1,
+	sftpRealpath: 1,
+	sftpStat:     1,
+	sftpRename:   2,
+	sftpReadlink: 1,
+	sftpSymlink:  2,
+}
+
+// sftpExtension describes an extension request known to the filter.
+type sftpExtension struct {
+	paths    int
+	modifies bool
+	link     bool
+	setstat  bool
+	// overwrites is true if the extension replaces an existing file, which deletes it.
+	overwrites bool
+}
+
+var sftpExtensions = map[string]sftpExtension{
+	"posix-rename@openssh.com": {paths: 2, modifies: true, overwrites: true},
+	"hardlink@openssh.com":     {paths: 2, modifies: true, link: true},
+	"lsetstat@openssh.com":     {paths: 1, modifies: true, setstat: true},
+	"statvfs@openssh.com":      {paths: 1},
+	"expand-path@openssh.com":  {paths: 1},
+	"fstatvfs@openssh.com":     {},
+	"fsync@openssh.com":        {},
+	"limits@openssh.com":       {},
+}
+
+func (s SFTPConfig) enabled() bool {
+	return s.ReadOnly || s.NoDelete || s.NoSymlink || s.NoSetstat || s.Root != "" || s.MaxFileSize > 0
+}
+
+// sftpFor returns the SFTP configuration for the user, setting Root to the home directory of the user if
+// ConfineToHome is enabled and no Root is configured.
+func (c Config) sftpFor(username string) (SFTPConfig, error) {
+	sftp := c.SFTP
+	if !c.ConfineToHome || sftp.Root != "" {
+		return sftp, nil
+	}
+	home, err := resolveHomeDirectory(c.HomeDirectory, username)
+	if err != nil {
+		return sftp, err
+	}
+	sftp.Root = home
+	return sftp, nil
+}
+
+// sftpServers are the file names of SFTP server programs that must not be executed directly while SFTP restrictions
+// are configured.
+var sftpServers = []string{"sftp-server", "gesftpserver"}
+
+// runsSFTPServer returns true if any word of the command refers to a known SFTP server program. The check is
+// best-effort, an allow list in Command is the reliable way to prevent running an SFTP server.
+func runsSFTPServer(program string) bool {
+	words := strings.FieldsFunc(program, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("\"'`;|&<>(){}=$\\", r)
+	})
+	for _, word := range words {
+		for _, server := range sftpServers {
+			if path.Base(word) == server {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sftpRestricted returns true if the SFTP filter is applied to the sftp subsystems of the user.
+func (e *Evaluator) sftpRestricted() bool {
+	sftp, err := e.config.sftpFor(e.username)
+	return err != nil || sftp.enabled()
+}
+
+// filterSFTP installs the SFTP filter with the specified configuration on the channel passed to the backend.
+func (s *sessionHandler) filterSFTP(config SFTPConfig) error {
+	if s.channel == nil {
+		return fmt.Errorf("failed to execute subsystem")
+	}
+	filter := newSFTPFilter(config, s.channel.SessionChannel.Stdin(), s.channel.SessionChannel.Stdout())
+	s.channel.stdin = filter
+	s.channel.stdout = &sftpOutput{filter: filter}
+	return nil
+}
+
+// sftpFilter sits between the client and the sftp backend. Packets sent by the client are checked before they are
+// passed to the backend, forbidden ones are answered directly. Output of the backend is only forwarded in whole
+// packets so the answers are never interleaved with a partially written packet.
+type sftpFilter struct {
+	config  SFTPConfig
+	input   io.Reader
+	output  io.Writer
+	lock    *sync.Mutex
+	buffer  []byte
+	pending []byte
+}
+
+func newSFTPFilter(config SFTPConfig, input io.Reader, output io.Writer) *sftpFilter {
+	return &sftpFilter{
+		config: config,
+		input:  input,
+		output: output,
+		lock:   &sync.Mutex{},
+	}
+}
+
+// Read returns the packets of the client permitted by the policy.
+func (f *sftpFilter) Read(p []byte) (int, error) {
+	for len(f.buffer) == 0 {
+		packet, err := f.readPacket()
+		if err != nil {
+			return 0, err
+		}
+		if packet[4] != sftpInit && len(packet) < 9 {
+			return 0, fmt.Errorf("SFTP packet too short")
+		}
+		if status := f.check(packet[4:]); status != 0 {
+			if err := f.reject(packet[4:], status); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		f.buffer = packet
+	}
+	n := copy(p, f.buffer)
+	f.buffer = f.buffer[n:]
+	return n, nil
+}
+
+func (f *sftpFilter) readPacket() ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(f.input, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < 1 || length > sftpMaxPacketLength {
+		return nil, fmt.Errorf("invalid SFTP packet length: %d", length)
+	}
+	packet := make([]byte, 4+length)
+	copy(packet, header)
+	if _, err := io.ReadFull(f.input, packet[4:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return packet, nil
+}
+
+// check returns the status code to reject the packet with, or 0 if the packet is permitted.
+func (f *sftpFilter) check(packet []byte) uint32 {
+	packetType := packet[0]
+	if packetType == sftpInit {
+		return 0
+	}
+	data := &sftpReader{data: packet[1:]}
+	data.uint32()
+	paths := sftpPathCount[packetType]
+	var extension sftpExtension
+	if packetType == sftpExtended {
+		var known bool
+		extension, known = sftpExtensions[data.string()]
+		if !known {
+			return sftpStatusOpUnsupported
+		}
+		paths = extension.paths
+	}
+	for i := 0; i < paths; i++ {
+		if !f.inRoot(data.string()) {
+			return sftpStatusPermissionDenied
+		}
+	}
+	if data.failed {
+		return sftpStatusOpUnsupported
+	}
+	switch packetType {
+	case sftpOpen:
+		flags := data.uint32()
+		if f.config.ReadOnly && flags&(sftpFlagWrite|sftpFlagAppend|sftpFlagCreate|sftpFlagTrunc) != 0 {
+			return sftpStatusPermissionDenied
+		}
+	case sftpWrite:
+		data.string()
+		offset := data.uint64()
+		size := uint64(len(data.string()))
+		if f.config.ReadOnly {
+			return sftpStatusPermissionDenied
+		}
+		if f.config.MaxFileSize > 0 && offset+size > uint64(f.config.MaxFileSize) {
+			return sftpStatusPermissionDenied
+		}
+	case sftpSetstat:
+		fallthrough
+	case sftpFsetstat:
+		if f.config.ReadOnly || f.config.NoSetstat {
+			return sftpStatusPermissionDenied
+		}
+	case sftpRemove:
+		fallthrough
+	case sftpRmdir:
+		if f.config.ReadOnly || f.config.NoDelete {
+			return sftpStatusPermissionDenied
+		}
+	case sftpSymlink:
+		if f.config.ReadOnly || f.config.NoSymlink {
+			return sftpStatusPermissionDenied
+		}
+	case sftpMkdir:
+		fallthrough
+	case sftpRename:
+		if f.config.ReadOnly {
+			return sftpStatusPermissionDenied
+		}
+	case sftpExtended:
+		if (f.config.ReadOnly && extension.modifies) ||
+			(f.config.NoDelete && extension.overwrites) ||
+			(f.config.NoSymlink && extension.link) ||
+			(f.config.NoSetstat && extension.setstat) {
+			return sftpStatusPermissionDenied
+		}
+	}
+	if data.failed {
+		return sftpStatusOpUnsupported
+	}
+	return 0
+}
+
+// inRoot returns true if the path is within the configured root.
+func (f *sftpFilter) inRoot(p string) bool {
+	if f.config.Root == "" {
+		return true
+	}
+	cleaned := path.Clean(p)
+	if !path.IsAbs(cleaned) {
+		return cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+	}
+	root := path.Clean(f.config.Root)
+	return root == "/" || cleaned == root || strings.HasPrefix(cleaned, root+"/")
+}
+
+// reject answers the packet with a status packet.
+func (f *sftpFilter) reject(packet []byte, status uint32) error {
+	message := "operation not permitted by the security policy"
+	reply := make([]byte, 4+1+4+4+4+len(message)+4)
+	binary.BigEndian.PutUint32(reply, uint32(len(reply)-4))
+	reply[4] = sftpStatus
+	copy(reply[5:9], packet[1:5])
+	binary.BigEndian.PutUint32(reply[9:], status)
+	binary.BigEndian.PutUint32(reply[13:], uint32(len(message)))
+	copy(reply[17:], message)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	_, err := f.output.Write(reply)
+	return err
+}
+
+// sftpOutput forwards the output of the backend to the client in whole packets.
+type sftpOutput struct {
+	filter *sftpFilter
+}
+
+func (o *sftpOutput) Write(p []byte) (int, error) {
+	f := o.filter
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.pending = append(f.pending, p...)
+	complete := 0
+	for len(f.pending)-complete >= 4 {
+		length := int(binary.BigEndian.Uint32(f.pending[complete:]))
+		if len(f.pending)-complete-4 < length {
+			break
+		}
+		complete += 4 + length
+	}
+	if complete == 0 {
+		return len(p), nil
+	}
+	if _, err := f.output.Write(f.pending[:complete]); err != nil {
+		return 0, err
+	}
+	f.pending = append([]byte(nil), f.pending[complete:]...)
+	return len(p), nil
+}
+
+// sftpReader decodes the fields of an SFTP packet. Reading past the end of the packet sets failed.
+type sftpReader struct {
+	data   []byte
+	failed bool
+}
+
+func (r *sftpReader) uint32() uint32 {
+	if len(r.data) < 4 {
+		r.failed = true
+		return 0
+	}
+	value := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return value
+}
+
+func (r *sftpReader) uint64() uint64 {
+	if len(r.data) < 8 {
+		r.failed = true
+		return 0
+	}
+	value := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return value
+}
+
+func (r *sftpReader) string() string {
+	length := r.uint32()
+	if r.failed || uint32(len(r.data)) < length {
+		r.failed = true
+		return ""
+	}
+	value := string(r.data[:length])
+	r.data = r.data[length:]
+	return value
+}
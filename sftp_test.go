@@ -0,0 +1,165 @@
+package security
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sftpPacket(packetType byte, requestID uint32, fields ...interface{}) []byte {
+	body := []byte{packetType}
+	body = append(body, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(body[1:], requestID)
+	for _, f := range fields {
+		switch value := f.(type) {
+		case string:
+			length := make([]byte, 4)
+			binary.BigEndian.PutUint32(length, uint32(len(value)))
+			body = append(body, length...)
+			body = append(body, value...)
+		case uint32:
+			encoded := make([]byte, 4)
+			binary.BigEndian.PutUint32(encoded, value)
+			body = append(body, encoded...)
+		case uint64:
+			encoded := make([]byte, 8)
+			binary.BigEndian.PutUint64(encoded, value)
+			body = append(body, encoded...)
+		}
+	}
+	packet := make([]byte, 4)
+	binary.BigEndian.PutUint32(packet, uint32(len(body)))
+	return append(packet, body...)
+}
+
+func TestSFTPFilter(t *testing.T) {
+	permitted := [][]byte{
+		{0, 0, 0, 5, sftpInit, 0, 0, 0, 3},
+		sftpPacket(sftpRealpath, 1, "."),
+		sftpPacket(sftpOpen, 2, "/srv/data/report.csv", uint32(0x01), uint32(0)),
+		sftpPacket(sftpWrite, 3, "handle", uint64(0), "0123456789"),
+		sftpPacket(sftpRename, 4, "/srv/data/a", "/srv/data/b"),
+		sftpPacket(sftpExtended, 5, "statvfs@openssh.com", "/srv/data"),
+	}
+	rejected := [][]byte{
+		sftpPacket(sftpOpen, 10, "/etc/passwd", uint32(0x01), uint32(0)),
+		sftpPacket(sftpStat, 11, "/srv/data/../../etc"),
+		sftpPacket(sftpStat, 12, "../.."),
+		sftpPacket(sftpRemove, 13, "/srv/data/report.csv"),
+		sftpPacket(sftpSymlink, 14, "/srv/data/link", "/srv/data/target"),
+		sftpPacket(sftpSetstat, 15, "/srv/data/report.csv", uint32(0)),
+		sftpPacket(sftpWrite, 16, "handle", uint64(95), "0123456789"),
+		sftpPacket(sftpExtended, 17, "hardlink@openssh.com", "/srv/data/a", "/srv/data/b"),
+		sftpPacket(sftpExtended, 18, "copy-data", "handle"),
+	}
+	input := &bytes.Buffer{}
+	expected := &bytes.Buffer{}
+	for i, packet := range permitted {
+		input.Write(packet)
+		input.Write(rejected[i])
+		expected.Write(packet)
+	}
+	for _, packet := range rejected[len(permitted):] {
+		input.Write(packet)
+	}
+
+	output := &bytes.Buffer{}
+	filter := newSFTPFilter(SFTPConfig{
+		NoDelete:    true,
+		NoSymlink:   true,
+		NoSetstat:   true,
+		Root:        "/srv/data",
+		MaxFileSize: 100,
+	}, input, output)
+	received, err := ioutil.ReadAll(filter)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.Bytes(), received)
+
+	for i := range rejected {
+		length := binary.BigEndian.Uint32(output.Bytes())
+		assert.Equal(t, byte(sftpStatus), output.Bytes()[4])
+		assert.Equal(t, uint32(10+i), binary.BigEndian.Uint32(output.Bytes()[5:]))
+		output.Next(int(4 + length))
+	}
+	assert.Equal(t, 0, output.Len())
+}
+
+func TestSFTPReadOnly(t *testing.T) {
+	filter := newSFTPFilter(SFTPConfig{ReadOnly: true}, nil, nil)
+	assert.Zero(t, filter.check(sftpPacket(sftpOpen, 1, "file", uint32(0x01), uint32(0))[4:]))
+	assert.NotZero(t, filter.check(sftpPacket(sftpOpen, 1, "file", uint32(0x1a), uint32(0))[4:]))
+	assert.NotZero(t, filter.check(sftpPacket(sftpMkdir, 1, "dir", uint32(0))[4:]))
+	assert.NotZero(t, filter.check(sftpPacket(sftpExtended, 1, "posix-rename@openssh.com", "a", "b")[4:]))
+	assert.NotZero(t, filter.check(sftpPacket(sftpOpen, 1, "truncated")[4:]))
+}
+
+func TestSFTPNoDelete(t *testing.T) {
+	filter := newSFTPFilter(SFTPConfig{NoDelete: true}, nil, nil)
+	assert.NotZero(t, filter.check(sftpPacket(sftpRemove, 1, "file")[4:]))
+	assert.NotZero(t, filter.check(sftpPacket(sftpExtended, 1, "posix-rename@openssh.com", "a", "b")[4:]))
+	assert.Zero(t, filter.check(sftpPacket(sftpRename, 1, "a", "b")[4:]))
+}
+
+func TestSFTPServerExec(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{SFTP: SFTPConfig{ReadOnly: true}})
+	assert.NoError(t, err)
+	for _, command := range []string{
+		"/usr/lib/openssh/sftp-server",
+		"sftp-server -R",
+		"sh -c '/usr/libexec/sftp-server'",
+		"env A=1 /usr/lib/gesftpserver",
+	} {
+		decision := evaluator.EvaluateExec(command)
+		assert.False(t, decision.Allowed, command)
+		assert.Equal(t, DecisionReasonSFTPServerExec, decision.Reason, command)
+	}
+	assert.True(t, evaluator.EvaluateExec("ls -la").Allowed)
+
+	evaluator, err = NewEvaluator(Config{})
+	assert.NoError(t, err)
+	assert.True(t, evaluator.EvaluateExec("/usr/lib/openssh/sftp-server").Allowed)
+}
+
+func TestSFTPOutput(t *testing.T) {
+	output := &bytes.Buffer{}
+	filter := newSFTPFilter(SFTPConfig{ReadOnly: true}, nil, output)
+	writer := &sftpOutput{filter: filter}
+	packet := sftpPacket(sftpStatus, 1, uint32(0), "ok", "")
+	_, err := writer.Write(packet[:7])
+	assert.NoError(t, err)
+	assert.Equal(t, 0, output.Len())
+	assert.NoError(t, filter.reject(sftpPacket(sftpRemove, 2, "file")[4:], sftpStatusPermissionDenied))
+	_, err = writer.Write(packet[7:])
+	assert.NoError(t, err)
+	assert.Equal(t, packet, output.Bytes()[output.Len()-len(packet):])
+}
+
+func TestSFTPSubsystem(t *testing.T) {
+	channel := newBackendChannel(&recordingSessionChannel{})
+	session := &sessionHandler{
+		config: Config{
+			SFTP: SFTPConfig{ReadOnly: true},
+		},
+		backend: &dummyBackend{},
+		channel: channel,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnSubsystem(1, "sftp"))
+	assert.IsType(t, &sftpFilter{}, channel.Stdin())
+	assert.IsType(t, &sftpOutput{}, channel.Stdout())
+
+	assert.Error(t, SFTPConfig{Root: "relative"}.Validate())
+
+	sftp, err := Config{ConfineToHome: true}.sftpFor("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/foo", sftp.Root)
+	assert.True(t, sftp.enabled())
+	_, err = Config{ConfineToHome: true}.sftpFor("")
+	assert.Error(t, err)
+}
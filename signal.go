@@ -0,0 +1,129 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// signals contains the signal names defined in RFC 4254 section 6.10.
+var signals = []string{
+	"ABRT", "ALRM", "FPE", "HUP", "ILL", "INT", "KILL", "PIPE", "QUIT", "SEGV", "TERM", "USR1", "USR2",
+}
+
+// signalNumbers maps the signal numbers that are the same on all POSIX systems to the signal names. USR1 and USR2
+// differ between systems and are only accepted by name.
+var signalNumbers = map[string]string{
+	"1":  "HUP",
+	"2":  "INT",
+	"3":  "QUIT",
+	"4":  "ILL",
+	"6":  "ABRT",
+	"8":  "FPE",
+	"9":  "KILL",
+	"11": "SEGV",
+	"13": "PIPE",
+	"14": "ALRM",
+	"15": "TERM",
+}
+
+// normalizeSignal returns the signal name in upper case without the SIG prefix, so TERM, SIGTERM, sigterm and 15
+// are all evaluated as TERM.
+func normalizeSignal(signal string) string {
+	if name, ok := signalNumbers[signal]; ok {
+		return name
+	}
+	return strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+}
+
+// signalPresets are the signal lists that can be referenced in Signal.Allow and Signal.Deny by their name.
+var signalPresets = map[string][]string{
+	// @safe contains the signals used to interrupt or stop a program in an orderly way. Window size changes are
+	// passed as window-change requests, which are not signals.
+	"@safe": {"HUP", "INT", "TERM"},
+}
+
+// expandSignalPresets replaces the preset names in the list with the signals of the presets.
+func expandSignalPresets(list []string) []string {
+	var result []string
+	for _, entry := range list {
+		if preset, ok := signalPresets[entry]; ok {
+			result = append(result, preset...)
+		} else {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// signalList expands the presets in an Allow or Deny list and normalizes the entries matched exactly like the signals
+// sent by clients, so SIGKILL and 9 match KILL.
+func signalList(mode MatchMode, list []string) []string {
+	var result []string
+	for _, entry := range expandSignalPresets(list) {
+		if entryMode, pattern, prefixed := parseEntry(mode, entry); entryMode == MatchModeExact {
+			entry = normalizeSignal(pattern)
+			if prefixed {
+				entry = "exact:" + entry
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// validateSignalPresets rejects entries starting with @ that are not a known preset.
+func validateSignalPresets(v *validator, path string, list []string) {
+	for i, entry := range list {
+		if _, ok := signalPresets[entry]; strings.HasPrefix(entry, "@") && !ok {
+			v.fail(index(path, i), ValidationCodeInvalidPattern, "unknown signal preset: %s", entry)
+		}
+	}
+}
+
+func validateSignal(signal string) error {
+	for _, s := range signals {
+		if s == signal {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid signal: %s", signal)
+}
+
+// translateSignal returns the signal to deliver to the backend for the signal sent by the client.
+func (s *sessionHandler) translateSignal(signal string) string {
+	for from, to := range s.config.Signal.Translate {
+		if normalizeSignal(from) == signal {
+			return to
+		}
+	}
+	return signal
+}
+
+// terminationSignals are the signals escalated to KILL if the program doesn't exit within Signal.EscalateAfter.
+var terminationSignals = []string{"HUP", "INT", "QUIT", "TERM"}
+
+// escalate sends KILL to the program if it hasn't exited within Signal.EscalateAfter after a termination signal was
+// delivered.
+func (s *sessionHandler) escalate(mode ExecutionPolicy, requestID uint64, signal string) {
+	channel := s.channel
+	if s.config.Signal.EscalateAfter <= 0 || channel == nil || !s.contains(terminationSignals, signal) {
+		return
+	}
+	go func() {
+		select {
+		case <-channel.exited:
+			return
+		case <-time.After(s.config.Signal.EscalateAfter):
+		}
+		if err := s.audit(
+			mode,
+			requestID,
+			"signal",
+			map[string]string{"signal": signal, "delivered": "KILL", "escalated": "true"},
+		); err != nil {
+			return
+		}
+		_ = s.backend.OnSignal(requestID, "KILL")
+	}()
+}
@@ -0,0 +1,204 @@
+package security
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerssh/sshserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignalTranslation(t *testing.T) {
+	backend := &signalBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Signal: SignalConfig{
+				Mode: ExecutionPolicyEnable,
+				Deny: []string{"KILL"},
+				Translate: map[string]string{
+					"TERM": "INT",
+				},
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.config.Validate())
+
+	assert.NoError(t, session.OnSignal(1, "TERM"))
+	assert.NoError(t, session.OnSignal(2, "USR1"))
+	assert.Error(t, session.OnSignal(3, "KILL"))
+	assert.Equal(t, []string{"INT", "USR1"}, backend.getSignals())
+
+	assert.Error(t, Config{Signal: SignalConfig{Translate: map[string]string{"TERM": "SIGINT"}}}.Validate())
+}
+
+func TestSignalNormalization(t *testing.T) {
+	backend := &signalBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Signal: SignalConfig{
+				Mode:   ExecutionPolicyEnable,
+				Deny:   []string{"KILL"},
+				Strict: true,
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnSignal(1, "SIGTERM"))
+	assert.NoError(t, session.OnSignal(2, "15"))
+	assert.NoError(t, session.OnSignal(3, "hup"))
+	assert.Error(t, session.OnSignal(4, "SIGKILL"))
+	assert.Error(t, session.OnSignal(5, "9"))
+	assert.Error(t, session.OnSignal(6, "WINCH"))
+	assert.Equal(t, []string{"TERM", "TERM", "HUP"}, backend.getSignals())
+
+	evaluator, err := NewEvaluator(Config{Signal: SignalConfig{Strict: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionReasonUnknownSignal, evaluator.EvaluateSignal("SIGWINCH").Reason)
+}
+
+func TestSignalEntryNormalization(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{Signal: SignalConfig{Mode: ExecutionPolicyEnable, Deny: []string{"SIGKILL"}}})
+	assert.NoError(t, err)
+	for _, signal := range []string{"KILL", "SIGKILL", "sigkill", "9"} {
+		assert.Equal(t, DecisionReasonMatchedDenyList, evaluator.EvaluateSignal(signal).Reason, signal)
+	}
+	assert.True(t, evaluator.EvaluateSignal("TERM").Allowed)
+
+	evaluator, err = NewEvaluator(Config{
+		Signal: SignalConfig{
+			Mode:      ExecutionPolicyFilter,
+			MatchMode: MatchModeGlob,
+			Allow:     []string{"USR*", "exact:sigterm"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, evaluator.EvaluateSignal("SIGUSR1").Allowed)
+	assert.True(t, evaluator.EvaluateSignal("15").Allowed)
+	assert.False(t, evaluator.EvaluateSignal("INT").Allowed)
+
+	backend := &signalBackend{}
+	session := &sessionHandler{
+		config: Config{
+			Signal: SignalConfig{
+				Mode:      ExecutionPolicyEnable,
+				Translate: map[string]string{"SIGTERM": "INT"},
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnSignal(1, "TERM"))
+	assert.NoError(t, session.OnSignal(2, "HUP"))
+	assert.Equal(t, []string{"INT", "HUP"}, backend.getSignals())
+
+	assert.NoError(t, session.config.Validate())
+	assert.Error(t, SignalConfig{Translate: map[string]string{"TERM": "INT", "SIGTERM": "HUP"}}.Validate())
+	assert.Error(t, SignalConfig{Translate: map[string]string{"TERM": "SIGWINCH"}}.Validate())
+}
+
+func TestSignalMode(t *testing.T) {
+	session := &sessionHandler{
+		config: Config{
+			Shell: ShellConfig{
+				Mode: ExecutionPolicyEnable,
+			},
+			Signal: SignalConfig{
+				Mode: ExecutionPolicyDisable,
+			},
+		},
+		backend: &signalBackend{},
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.Error(t, session.OnSignal(1, "TERM"))
+
+	// Signals are governed by Signal.Mode, not by Shell.Mode.
+	backend := &signalBackend{}
+	session.backend = backend
+	session.config.Shell.Mode = ExecutionPolicyDisable
+	session.config.Signal.Mode = ExecutionPolicyEnable
+	assert.NoError(t, session.OnSignal(2, "TERM"))
+	assert.Equal(t, []string{"TERM"}, backend.getSignals())
+}
+
+func TestSignalEscalation(t *testing.T) {
+	backend := &signalBackend{}
+	connection := &sshConnectionHandler{
+		config: Config{
+			MaxSessions: -1,
+			Signal: SignalConfig{
+				EscalateAfter: 10 * time.Millisecond,
+			},
+		},
+		backend: &signalSSHBackend{backend: backend},
+		lock:    &sync.Mutex{},
+	}
+
+	exiting, err := connection.OnSessionChannel(1, []byte{}, &recordingSessionChannel{})
+	assert.Nil(t, err)
+	hanging, err := connection.OnSessionChannel(2, []byte{}, &recordingSessionChannel{})
+	assert.Nil(t, err)
+
+	assert.NoError(t, exiting.OnSignal(1, "TERM"))
+	exiting.(*sessionHandler).channel.ExitStatus(0)
+	assert.NoError(t, hanging.OnSignal(1, "USR1"))
+	assert.NoError(t, hanging.OnSignal(2, "TERM"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"TERM", "USR1", "TERM", "KILL"}, backend.getSignals())
+}
+
+type signalSSHBackend struct {
+	dummySSHBackend
+	backend *signalBackend
+}
+
+func (s *signalSSHBackend) OnSessionChannel(
+	_ uint64,
+	_ []byte,
+	_ sshserver.SessionChannel,
+) (channel sshserver.SessionChannelHandler, failureReason sshserver.ChannelRejection) {
+	return s.backend, nil
+}
+
+type signalBackend struct {
+	dummyBackend
+	lock    sync.Mutex
+	signals []string
+}
+
+func (s *signalBackend) OnSignal(_ uint64, signal string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.signals = append(s.signals, signal)
+	return nil
+}
+
+func (s *signalBackend) getSignals() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.signals
+}
+
+func TestSignalPresets(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		Signal: SignalConfig{Mode: ExecutionPolicyFilter, Allow: []string{"@safe"}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, evaluator.EvaluateSignal("TERM").Allowed)
+	assert.True(t, evaluator.EvaluateSignal("SIGINT").Allowed)
+	assert.True(t, evaluator.EvaluateSignal("HUP").Allowed)
+	assert.False(t, evaluator.EvaluateSignal("KILL").Allowed)
+
+	assert.Error(t, SignalConfig{Allow: []string{"@unsafe"}}.Validate())
+}
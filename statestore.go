@@ -0,0 +1,135 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StateStore persists the state shared between the connections of a handler created by NewHandlerWithStateStore, such
+// as the number of connections per IP address and the time users and public keys were first seen. Using a shared
+// store lets multiple instances enforce these limits together.
+//
+// This library only provides the in-memory store returned by NewMemoryStateStore, stores shared between instances
+// have to be implemented by the application. Implementations must be safe for concurrent use. The statestoretest
+// package contains a test suite implementations should pass.
+type StateStore interface {
+	// Increment adds delta to the integer counter stored at key, creating it with 0 if it doesn't exist, and returns
+	// the new value. If max is not negative and the new value would exceed max the counter is left unchanged and
+	// ok is false. Counters dropping to 0 or below are deleted.
+	Increment(key string, delta int64, max int64) (value int64, ok bool, err error)
+	// Get returns the value stored at key. ok is false if the key does not exist or has expired.
+	Get(key string) (value []byte, ok bool, err error)
+	// Set stores value at key. A ttl of 0 means the value does not expire.
+	Set(key string, value []byte, ttl time.Duration) error
+	// SetIfAbsent stores value at key unless the key already exists, and returns the value stored at key after the
+	// call. created is true if the value was stored by this call. A ttl of 0 means the value does not expire.
+	SetIfAbsent(key string, value []byte, ttl time.Duration) (stored []byte, created bool, err error)
+	// Delete removes key. Deleting a key that doesn't exist is not an error.
+	Delete(key string) error
+}
+
+// NewMemoryStateStore creates a StateStore keeping the state in memory. The state is lost on restart and not shared
+// between instances.
+//goland:noinspection GoUnusedExportedFunction
+func NewMemoryStateStore() StateStore {
+	return &memoryStateStore{
+		lock:    &sync.Mutex{},
+		entries: map[string]memoryEntry{},
+		now:     time.Now,
+	}
+}
+
+type memoryStateStore struct {
+	lock    *sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// get returns the entry at key, removing it if it has expired. The caller must hold the lock.
+func (m *memoryStateStore) get(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return entry, false
+	}
+	if !entry.expires.IsZero() && !m.now().Before(entry.expires) {
+		delete(m.entries, key)
+		return entry, false
+	}
+	return entry, true
+}
+
+func (m *memoryStateStore) set(key string, value []byte, ttl time.Duration) {
+	entry := memoryEntry{
+		value: append([]byte{}, value...),
+	}
+	if ttl > 0 {
+		entry.expires = m.now().Add(ttl)
+	}
+	m.entries[key] = entry
+}
+
+func (m *memoryStateStore) Increment(key string, delta int64, max int64) (int64, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var current int64
+	entry, ok := m.get(key)
+	if ok {
+		var err error
+		current, err = strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("value at %s is not a counter", key)
+		}
+	}
+	value := current + delta
+	if max > -1 && value > max {
+		return current, false, nil
+	}
+	if value <= 0 {
+		delete(m.entries, key)
+		return value, true, nil
+	}
+	entry.value = []byte(strconv.FormatInt(value, 10))
+	m.entries[key] = entry
+	return value, true, nil
+}
+
+func (m *memoryStateStore) Get(key string) ([]byte, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	entry, ok := m.get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]byte{}, entry.value...), true, nil
+}
+
+func (m *memoryStateStore) Set(key string, value []byte, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.set(key, value, ttl)
+	return nil
+}
+
+func (m *memoryStateStore) SetIfAbsent(key string, value []byte, ttl time.Duration) ([]byte, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if entry, ok := m.get(key); ok {
+		return append([]byte{}, entry.value...), false, nil
+	}
+	m.set(key, value, ttl)
+	return append([]byte{}, value...), true, nil
+}
+
+func (m *memoryStateStore) Delete(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.entries, key)
+	return nil
+}
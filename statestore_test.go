@@ -0,0 +1,12 @@
+package security_test
+
+import (
+	"testing"
+
+	"github.com/containerssh/security"
+	"github.com/containerssh/security/statestoretest"
+)
+
+func TestMemoryStateStore(t *testing.T) {
+	statestoretest.Run(t, security.NewMemoryStateStore)
+}
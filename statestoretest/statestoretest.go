@@ -0,0 +1,146 @@
+// Package statestoretest contains a test suite for implementations of security.StateStore.
+package statestoretest
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerssh/security"
+)
+
+// Run runs the test suite against the stores created by newStore. Each call to newStore must return an empty store.
+// The suite waits for values with a TTL to expire, so it takes a few seconds to run.
+func Run(t *testing.T, newStore func() security.StateStore) {
+	t.Run("increment", func(t *testing.T) {
+		testIncrement(t, newStore())
+	})
+	t.Run("incrementLimit", func(t *testing.T) {
+		testIncrementLimit(t, newStore())
+	})
+	t.Run("incrementConcurrent", func(t *testing.T) {
+		testIncrementConcurrent(t, newStore())
+	})
+	t.Run("setGetDelete", func(t *testing.T) {
+		testSetGetDelete(t, newStore())
+	})
+	t.Run("setIfAbsent", func(t *testing.T) {
+		testSetIfAbsent(t, newStore())
+	})
+	t.Run("ttl", func(t *testing.T) {
+		testTTL(t, newStore())
+	})
+}
+
+func testIncrement(t *testing.T, store security.StateStore) {
+	for i := int64(1); i <= 3; i++ {
+		value, ok, err := store.Increment("counter", 1, -1)
+		if err != nil || !ok || value != i {
+			t.Fatalf("unexpected increment result: %d, %t, %v (expected %d)", value, ok, err, i)
+		}
+	}
+	value, ok, err := store.Increment("counter", -3, -1)
+	if err != nil || !ok || value != 0 {
+		t.Fatalf("unexpected decrement result: %d, %t, %v", value, ok, err)
+	}
+	if _, ok, err := store.Get("counter"); err != nil || ok {
+		t.Fatalf("counter was not deleted after dropping to 0 (%v)", err)
+	}
+}
+
+func testIncrementLimit(t *testing.T, store security.StateStore) {
+	for i := 0; i < 2; i++ {
+		if _, ok, err := store.Increment("counter", 1, 2); err != nil || !ok {
+			t.Fatalf("increment below the limit failed (%v)", err)
+		}
+	}
+	value, ok, err := store.Increment("counter", 1, 2)
+	if err != nil {
+		t.Fatalf("increment above the limit returned an error (%v)", err)
+	}
+	if ok || value != 2 {
+		t.Fatalf("increment above the limit was not rejected: %d, %t", value, ok)
+	}
+}
+
+func testIncrementConcurrent(t *testing.T, store security.StateStore) {
+	const workers = 20
+	wg := &sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			_, _, _ = store.Increment("counter", 1, 10)
+		}()
+	}
+	wg.Wait()
+	value, ok, err := store.Get("counter")
+	if err != nil || !ok || string(value) != "10" {
+		t.Fatalf("unexpected counter after concurrent increments: %s, %t, %v", value, ok, err)
+	}
+}
+
+func testSetGetDelete(t *testing.T, store security.StateStore) {
+	if _, ok, err := store.Get("key"); err != nil || ok {
+		t.Fatalf("missing key returned a value (%v)", err)
+	}
+	if err := store.Set("key", []byte("value"), 0); err != nil {
+		t.Fatalf("set failed (%v)", err)
+	}
+	value, ok, err := store.Get("key")
+	if err != nil || !ok || !bytes.Equal(value, []byte("value")) {
+		t.Fatalf("unexpected get result: %s, %t, %v", value, ok, err)
+	}
+	if err := store.Delete("key"); err != nil {
+		t.Fatalf("delete failed (%v)", err)
+	}
+	if _, ok, err := store.Get("key"); err != nil || ok {
+		t.Fatalf("deleted key returned a value (%v)", err)
+	}
+	if err := store.Delete("key"); err != nil {
+		t.Fatalf("deleting a missing key failed (%v)", err)
+	}
+	if err := store.Set("key", []byte("value"), 0); err != nil {
+		t.Fatalf("set failed (%v)", err)
+	}
+	if _, _, err := store.Increment("key", 1, -1); err == nil {
+		t.Fatalf("incrementing a non-counter value did not fail")
+	}
+}
+
+func testSetIfAbsent(t *testing.T, store security.StateStore) {
+	stored, created, err := store.SetIfAbsent("key", []byte("first"), 0)
+	if err != nil || !created || !bytes.Equal(stored, []byte("first")) {
+		t.Fatalf("unexpected result for new key: %s, %t, %v", stored, created, err)
+	}
+	stored, created, err = store.SetIfAbsent("key", []byte("second"), 0)
+	if err != nil || created || !bytes.Equal(stored, []byte("first")) {
+		t.Fatalf("unexpected result for existing key: %s, %t, %v", stored, created, err)
+	}
+}
+
+func testTTL(t *testing.T, store security.StateStore) {
+	ttl := time.Second
+	if err := store.Set("set", []byte("value"), ttl); err != nil {
+		t.Fatalf("set failed (%v)", err)
+	}
+	if _, _, err := store.SetIfAbsent("setIfAbsent", []byte("value"), ttl); err != nil {
+		t.Fatalf("setIfAbsent failed (%v)", err)
+	}
+	for _, key := range []string{"set", "setIfAbsent"} {
+		if _, ok, err := store.Get(key); err != nil || !ok {
+			t.Fatalf("%s value expired too early (%v)", key, err)
+		}
+	}
+	time.Sleep(2 * ttl)
+	for _, key := range []string{"set", "setIfAbsent"} {
+		if _, ok, err := store.Get(key); err != nil || ok {
+			t.Fatalf("%s value did not expire (%v)", key, err)
+		}
+	}
+	_, created, err := store.SetIfAbsent("setIfAbsent", []byte("value"), 0)
+	if err != nil || !created {
+		t.Fatalf("expired key was not replaced (%v)", err)
+	}
+}
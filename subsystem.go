@@ -0,0 +1,100 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// subsystemPattern is a parsed entry of a subsystem allow or deny list. Supported forms are:
+//
+// - name matches the subsystem exactly, e.g. sftp or vendor-subsystem@example.com.
+// - namespace/* matches all subsystems in the namespace, e.g. internal/* matches internal/backup.
+// - * matches all subsystems.
+// - name@3 matches version 3 of the subsystem, name@3+ matches version 3 or newer.
+type subsystemPattern struct {
+	name      string
+	namespace bool
+	version   int
+	orNewer   bool
+}
+
+func parseSubsystemPattern(pattern string) (subsystemPattern, error) {
+	if pattern == "" {
+		return subsystemPattern{}, fmt.Errorf("empty subsystem pattern")
+	}
+	if pattern == "*" {
+		return subsystemPattern{namespace: true, version: -1}, nil
+	}
+	result := subsystemPattern{name: pattern, version: -1}
+	if name, version, ok := splitSubsystemVersion(strings.TrimSuffix(pattern, "+")); ok {
+		result.name = name
+		result.version = version
+		result.orNewer = strings.HasSuffix(pattern, "+")
+	}
+	if strings.HasSuffix(result.name, "/*") {
+		if result.version != -1 {
+			return subsystemPattern{}, fmt.Errorf("versions cannot be combined with namespaces: %s", pattern)
+		}
+		result.name = strings.TrimSuffix(result.name, "*")
+		result.namespace = true
+	}
+	if result.name == "" || result.name == "/" || strings.Contains(result.name, "*") {
+		return subsystemPattern{}, fmt.Errorf("invalid subsystem pattern: %s", pattern)
+	}
+	return result, nil
+}
+
+// splitSubsystemVersion splits a subsystem name in the form of name@version if version is numeric. Other @ suffixes
+// are vendor domains and are considered part of the name.
+func splitSubsystemVersion(subsystem string) (string, int, bool) {
+	i := strings.LastIndex(subsystem, "@")
+	if i < 1 {
+		return subsystem, -1, false
+	}
+	version, err := strconv.Atoi(subsystem[i+1:])
+	if err != nil || version < 0 || strings.HasPrefix(subsystem[i+1:], "+") {
+		return subsystem, -1, false
+	}
+	return subsystem[:i], version, true
+}
+
+func (p subsystemPattern) matches(subsystem string) bool {
+	if p.namespace {
+		return strings.HasPrefix(subsystem, p.name)
+	}
+	if p.version == -1 {
+		return subsystem == p.name
+	}
+	name, version, ok := splitSubsystemVersion(subsystem)
+	if !ok || name != p.name {
+		return false
+	}
+	if p.orNewer {
+		return version >= p.version
+	}
+	return version == p.version
+}
+
+// matchSubsystem returns true if any of the patterns matches the subsystem. Invalid patterns never match.
+func (p *patternSet) matchSubsystem(mode MatchMode, patterns []string, subsystem string) bool {
+	if mode != "" && mode != MatchModeExact {
+		return p.matchList(mode, patterns, subsystem)
+	}
+	for _, pattern := range patterns {
+		if entryMode, entryPattern, ok := parseEntry(mode, pattern); ok {
+			if p.matchPattern(entryMode, entryPattern, subsystem) {
+				return true
+			}
+			continue
+		}
+		parsed, err := parseSubsystemPattern(pattern)
+		if err != nil {
+			continue
+		}
+		if parsed.matches(subsystem) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubsystemPatterns(t *testing.T) {
+	for _, testCase := range []struct {
+		pattern   string
+		subsystem string
+		matches   bool
+	}{
+		{"sftp", "sftp", true},
+		{"sftp", "sftp@3", false},
+		{"sftp", "sftp-server", false},
+		{"*", "anything/at/all", true},
+		{"internal/*", "internal/backup", true},
+		{"internal/*", "internal/backup/full", true},
+		{"internal/*", "internal", false},
+		{"internal/*", "internals/backup", false},
+		{"sftp@3", "sftp@3", true},
+		{"sftp@3", "sftp@4", false},
+		{"sftp@3+", "sftp@3", true},
+		{"sftp@3+", "sftp@12", true},
+		{"sftp@3+", "sftp@2", false},
+		{"sftp@3+", "sftp", false},
+		{"netconf@example.com", "netconf@example.com", true},
+		{"netconf@example.com", "netconf", false},
+	} {
+		parsed, err := parseSubsystemPattern(testCase.pattern)
+		assert.NoError(t, err, testCase.pattern)
+		assert.Equal(t, testCase.matches, parsed.matches(testCase.subsystem), "%s %s", testCase.pattern, testCase.subsystem)
+	}
+
+	for _, pattern := range []string{"", "/*", "sf*tp", "internal/*@3+", "*/foo"} {
+		_, err := parseSubsystemPattern(pattern)
+		assert.Error(t, err, pattern)
+	}
+
+	assert.Error(t, Config{Subsystem: SubsystemConfig{Allow: []string{"sf*"}}}.Validate())
+}
@@ -0,0 +1,119 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Capabilities contains the effective execution policy of each request type after applying the defaults.
+// ForceCommand is the global forced command, ShellForceCommand, CommandForceCommand and SubsystemForceCommand are the
+// forced commands effectively replacing the programs of shell, exec and subsystem requests, taking the forced
+// commands of the sections into account.
+type Capabilities struct {
+	Shell                 ExecutionPolicy `json:"shell"`
+	Command               ExecutionPolicy `json:"command"`
+	Subsystem             ExecutionPolicy `json:"subsystem"`
+	Env                   ExecutionPolicy `json:"env"`
+	TTY                   ExecutionPolicy `json:"tty"`
+	Signal                ExecutionPolicy `json:"signal"`
+	ForceCommand          string          `json:"forceCommand,omitempty"`
+	ShellForceCommand     string          `json:"shellForceCommand,omitempty"`
+	CommandForceCommand   string          `json:"commandForceCommand,omitempty"`
+	SubsystemForceCommand string          `json:"subsystemForceCommand,omitempty"`
+}
+
+// EffectiveCapabilities returns the effective execution policy of each request type.
+func (c Config) EffectiveCapabilities() Capabilities {
+	return Capabilities{
+		Shell:                 c.effectivePolicy(c.Shell.Mode, c.Defaults.SessionRequests),
+		Command:               c.effectivePolicy(c.Command.Mode, c.Defaults.SessionRequests),
+		Subsystem:             c.effectivePolicy(c.Subsystem.Mode, c.Defaults.SessionRequests),
+		Env:                   c.effectivePolicy(c.Env.Mode, c.Defaults.SessionRequests),
+		TTY:                   c.effectivePolicy(c.TTY.Mode, c.Defaults.SessionRequests),
+		Signal:                c.effectivePolicy(c.Signal.Mode, c.Defaults.SessionRequests),
+		ForceCommand:          c.forcedCommand(),
+		ShellForceCommand:     c.forcedFor("shell").line(),
+		CommandForceCommand:   c.forcedFor("exec").line(),
+		SubsystemForceCommand: c.forcedFor("subsystem").line(),
+	}
+}
+
+// effectivePolicy returns primary if configured, otherwise the default of its category, DefaultMode or, if neither is
+// configured, ExecutionPolicyEnable.
+func (c Config) effectivePolicy(primary ExecutionPolicy, categoryDefault ExecutionPolicy) ExecutionPolicy {
+	if primary != ExecutionPolicyUnconfigured {
+		return primary
+	}
+	if categoryDefault != ExecutionPolicyUnconfigured {
+		return categoryDefault
+	}
+	if c.DefaultMode != ExecutionPolicyUnconfigured {
+		return c.DefaultMode
+	}
+	return ExecutionPolicyEnable
+}
+
+// Fingerprint returns a short hash identifying the configuration. Identical configurations have the same fingerprint.
+func (c Config) Fingerprint() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+var capabilityDescriptions = map[ExecutionPolicy]string{
+	ExecutionPolicyEnable:  "allowed",
+	ExecutionPolicyAudit:   "allowed, audited",
+	ExecutionPolicyPrompt:  "allowed after confirmation",
+	ExecutionPolicyFilter:  "restricted to an allow list",
+	ExecutionPolicyDisable: "not allowed",
+}
+
+// formatSummary returns the security summary displayed to interactive users.
+func formatSummary(config Config) string {
+	capabilities := config.EffectiveCapabilities()
+	lines := []string{
+		fmt.Sprintf("Security policy %s", config.Fingerprint()),
+		fmt.Sprintf("  Shell:                  %s", capabilityDescriptions[capabilities.Shell]),
+		fmt.Sprintf("  Commands:               %s", capabilityDescriptions[capabilities.Command]),
+		fmt.Sprintf("  Subsystems (e.g. SFTP): %s", capabilityDescriptions[capabilities.Subsystem]),
+		fmt.Sprintf("  Environment variables:  %s", capabilityDescriptions[capabilities.Env]),
+		fmt.Sprintf("  Terminal:               %s", capabilityDescriptions[capabilities.TTY]),
+		fmt.Sprintf("  Signals:                %s", capabilityDescriptions[capabilities.Signal]),
+	}
+	switch {
+	case capabilities.ShellForceCommand != "" && capabilities.CommandForceCommand != "" &&
+		capabilities.SubsystemForceCommand != "":
+		lines = append(lines, "  All programs are replaced by a fixed command.")
+	default:
+		if capabilities.ShellForceCommand != "" {
+			lines = append(lines, "  Shells are replaced by a fixed command.")
+		}
+		if capabilities.CommandForceCommand != "" {
+			lines = append(lines, "  Commands are replaced by a fixed command.")
+		}
+		if capabilities.SubsystemForceCommand != "" {
+			lines = append(lines, "  Subsystems are replaced by a fixed command.")
+		}
+	}
+	if config.Summary.Notice != "" {
+		lines = append(lines, "", config.Summary.Notice)
+	}
+	if config.Summary.Contact != "" {
+		lines = append(lines, "", fmt.Sprintf("Questions about this policy: %s", config.Summary.Contact))
+	}
+	return strings.Join(lines, "\r\n") + "\r\n\r\n"
+}
+
+// writeSummary writes the security summary to the standard output of interactive sessions if enabled.
+func (s *sessionHandler) writeSummary() {
+	if !s.config.Summary.Enable || !s.pty || s.channel == nil {
+		return
+	}
+	_, _ = s.channel.Stdout().Write([]byte(formatSummary(s.config)))
+}
@@ -0,0 +1,81 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	config := Config{
+		DefaultMode: ExecutionPolicyDisable,
+		Shell: ShellConfig{
+			Mode: ExecutionPolicyEnable,
+		},
+		TTY: TTYConfig{
+			Mode: ExecutionPolicyEnable,
+		},
+		Summary: SummaryConfig{
+			Enable:  true,
+			Notice:  "This session is recorded.",
+			Contact: "security@example.com",
+		},
+	}
+	assert.Equal(t, Capabilities{
+		Shell:     ExecutionPolicyEnable,
+		Command:   ExecutionPolicyDisable,
+		Subsystem: ExecutionPolicyDisable,
+		Env:       ExecutionPolicyDisable,
+		TTY:       ExecutionPolicyEnable,
+		Signal:    ExecutionPolicyDisable,
+	}, config.EffectiveCapabilities())
+	assert.Len(t, config.Fingerprint(), 16)
+	assert.Equal(t, config.Fingerprint(), config.Fingerprint())
+	assert.NotEqual(t, config.Fingerprint(), Config{}.Fingerprint())
+
+	channel := &recordingSessionChannel{}
+	session := &sessionHandler{
+		config:  config,
+		backend: &dummyBackend{},
+		channel: newBackendChannel(channel),
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.OnShell(1))
+	assert.Empty(t, channel.stdout.String())
+
+	assert.NoError(t, session.OnPtyRequest(2, "xterm", 80, 25, 0, 0, []byte{}))
+	assert.NoError(t, session.OnShell(3))
+	summary := channel.stdout.String()
+	assert.Contains(t, summary, "Security policy "+config.Fingerprint())
+	assert.Contains(t, summary, "Commands:               not allowed")
+	assert.Contains(t, summary, "This session is recorded.")
+	assert.Contains(t, summary, "security@example.com")
+	assert.NotContains(t, summary, "fixed command")
+}
+
+func TestSummaryForceCommands(t *testing.T) {
+	config := Config{
+		Shell: ShellConfig{
+			ForceCommandArgs: []string{"/bin/menu", "--user", "{{ .Username }}"},
+		},
+		Subsystem: SubsystemConfig{
+			ForceCommand: "/usr/lib/sftp-server",
+		},
+	}
+	capabilities := config.EffectiveCapabilities()
+	assert.Empty(t, capabilities.ForceCommand)
+	assert.Equal(t, "'/bin/menu' '--user' '{{ .Username }}'", capabilities.ShellForceCommand)
+	assert.Empty(t, capabilities.CommandForceCommand)
+	assert.Equal(t, "/usr/lib/sftp-server", capabilities.SubsystemForceCommand)
+	summary := formatSummary(config)
+	assert.Contains(t, summary, "Shells are replaced by a fixed command.")
+	assert.Contains(t, summary, "Subsystems are replaced by a fixed command.")
+	assert.NotContains(t, summary, "Commands are replaced")
+
+	config.ForceCommand = "/bin/menu"
+	assert.Equal(t, "/bin/menu", config.EffectiveCapabilities().CommandForceCommand)
+	assert.Contains(t, formatSummary(config), "All programs are replaced by a fixed command.")
+}
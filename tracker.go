@@ -0,0 +1,167 @@
+package security
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sync"
+	"text/template"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultTerminationNotice = "Your session has been terminated: {{ .Reason }}"
+
+// SessionTracker keeps track of the open connections of a handler created by NewHandler and allows external tooling
+// to terminate them.
+type SessionTracker interface {
+	// TerminateSession sends the termination notice to all sessions of the connection with the specified connection
+	// ID, records a terminate request with the reason in the audit log of each session backend implementing
+	// AuditHandler, closes the sessions, and rejects any further sessions on the connection.
+	TerminateSession(connectionID string, reason TerminationReason) error
+	// TerminatePrincipal terminates all connections of the specified user and returns the number of connections
+	// terminated.
+	TerminatePrincipal(username string, reason TerminationReason) int
+}
+
+// TerminationReason is a machine-readable code describing why a connection was terminated, e.g. incident. It must
+// consist of lowercase letters, digits and dashes. Any code may be used, the constants cover common cases.
+type TerminationReason string
+
+const (
+	// TerminationReasonIncident means the connection was terminated in response to a security incident.
+	TerminationReasonIncident TerminationReason = "incident"
+	// TerminationReasonOffboarded means the user no longer has access.
+	TerminationReasonOffboarded TerminationReason = "offboarded"
+	// TerminationReasonPolicyChange means the connection was terminated to put a new policy into effect.
+	TerminationReasonPolicyChange TerminationReason = "policy-change"
+)
+
+var terminationReasonFormat = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Validate checks the format of the reason code.
+func (r TerminationReason) Validate() error {
+	if !terminationReasonFormat.MatchString(string(r)) {
+		return fmt.Errorf("invalid termination reason: %q", r)
+	}
+	return nil
+}
+
+// terminationNoticeData is the data structure passed to the TerminationNotice template.
+type terminationNoticeData struct {
+	Reason string
+}
+
+func parseTerminationNotice(notice string) (*template.Template, error) {
+	if notice == "" {
+		notice = defaultTerminationNotice
+	}
+	return template.New("terminationNotice").Option("missingkey=error").Parse(notice)
+}
+
+type sessionTracker struct {
+	config      Config
+	lock        *sync.Mutex
+	connections map[string]*sshConnectionHandler
+}
+
+func newSessionTracker(config Config) *sessionTracker {
+	return &sessionTracker{
+		config:      config,
+		lock:        &sync.Mutex{},
+		connections: map[string]*sshConnectionHandler{},
+	}
+}
+
+func (t *sessionTracker) register(connectionID string, connection *sshConnectionHandler) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.connections[connectionID] = connection
+}
+
+func (t *sessionTracker) unregister(connectionID string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.connections, connectionID)
+}
+
+func (t *sessionTracker) notice(reason string) (string, error) {
+	t.lock.Lock()
+	notice := t.config.TerminationNotice
+	t.lock.Unlock()
+	return formatTerminationNotice(notice, reason)
+}
+
+// reconfigure replaces the configuration used for termination notices.
+func (t *sessionTracker) reconfigure(config Config) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.config = config
+}
+
+func formatTerminationNotice(notice string, reason string) (string, error) {
+	tpl, err := parseTerminationNotice(notice)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, terminationNoticeData{Reason: reason}); err != nil {
+		return "", err
+	}
+	return buf.String() + "\r\n", nil
+}
+
+func (t *sessionTracker) TerminateSession(connectionID string, reason TerminationReason) error {
+	if err := reason.Validate(); err != nil {
+		return err
+	}
+	t.lock.Lock()
+	connection, ok := t.connections[connectionID]
+	t.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("connection not found: %s", connectionID)
+	}
+	notice, err := t.notice(string(reason))
+	if err != nil {
+		return fmt.Errorf("failed to render termination notice (%w)", err)
+	}
+	connection.terminate(notice, reason)
+	return nil
+}
+
+func (t *sessionTracker) TerminatePrincipal(username string, reason TerminationReason) int {
+	t.lock.Lock()
+	var connectionIDs []string
+	for connectionID, connection := range t.connections {
+		if connection.username == username {
+			connectionIDs = append(connectionIDs, connectionID)
+		}
+	}
+	t.lock.Unlock()
+	terminated := 0
+	for _, connectionID := range connectionIDs {
+		if err := t.TerminateSession(connectionID, reason); err == nil {
+			terminated++
+		}
+	}
+	return terminated
+}
+
+// ErrSessionTerminated indicates that the connection has been terminated and no new sessions can be opened.
+type ErrSessionTerminated struct {
+}
+
+// Error contains the error for the logs.
+func (e *ErrSessionTerminated) Error() string {
+	return "connection terminated"
+}
+
+// Message contains a message intended for the user.
+func (e *ErrSessionTerminated) Message() string {
+	return "connection terminated"
+}
+
+// Reason contains the rejection code.
+func (e *ErrSessionTerminated) Reason() ssh.RejectionReason {
+	return ssh.Prohibited
+}
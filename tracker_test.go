@@ -0,0 +1,147 @@
+package security
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/containerssh/sshserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerminateSession(t *testing.T) {
+	h, err := NewHandler(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: -1,
+		TerminationNotice:   "Terminated: {{ .Reason }}",
+	}, &dummyHandler{})
+	assert.NoError(t, err)
+
+	networkConnection, err := h.OnNetworkConnection(net.TCPAddr{IP: net.ParseIP("192.0.2.1")}, "conn1")
+	assert.NoError(t, err)
+	sshConnection, err := networkConnection.OnHandshakeSuccess("foo")
+	assert.NoError(t, err)
+	channel1 := &recordingSessionChannel{}
+	_, err = sshConnection.OnSessionChannel(1, []byte{}, channel1)
+	assert.NoError(t, err)
+	channel2 := &recordingSessionChannel{}
+	session2, err := sshConnection.OnSessionChannel(2, []byte{}, channel2)
+	assert.NoError(t, err)
+	session2.OnClose()
+
+	assert.Error(t, h.TerminateSession("conn2", "incident"))
+	assert.NoError(t, h.TerminateSession("conn1", "incident"))
+	assert.Equal(t, "Terminated: incident\r\n", channel1.stderr.String())
+	assert.True(t, channel1.closed)
+	assert.False(t, channel2.closed)
+
+	_, err = sshConnection.OnSessionChannel(3, []byte{}, &recordingSessionChannel{})
+	assert.Error(t, err)
+
+	networkConnection.OnDisconnect()
+	assert.Error(t, h.TerminateSession("conn1", "incident"))
+}
+
+func TestTerminatePrincipal(t *testing.T) {
+	h, err := NewHandler(Config{
+		MaxSessions:         -1,
+		MaxConnectionsPerIP: -1,
+	}, &dummyHandler{})
+	assert.NoError(t, err)
+
+	var channels []*recordingSessionChannel
+	for i, username := range []string{"foo", "foo", "bar"} {
+		networkConnection, err := h.OnNetworkConnection(
+			net.TCPAddr{IP: net.ParseIP("192.0.2.1")},
+			string(rune('a'+i)),
+		)
+		assert.NoError(t, err)
+		sshConnection, err := networkConnection.OnHandshakeSuccess(username)
+		assert.NoError(t, err)
+		channel := &recordingSessionChannel{}
+		_, err = sshConnection.OnSessionChannel(1, []byte{}, channel)
+		assert.NoError(t, err)
+		channels = append(channels, channel)
+	}
+
+	assert.Equal(t, 2, h.TerminatePrincipal("foo", "offboarded"))
+	assert.Equal(t, "Your session has been terminated: offboarded\r\n", channels[0].stderr.String())
+	assert.True(t, channels[0].closed)
+	assert.True(t, channels[1].closed)
+	assert.False(t, channels[2].closed)
+}
+
+func TestTerminateSessionAudit(t *testing.T) {
+	session := &auditBackend{}
+	connection := &sshConnectionHandler{
+		config:   Config{MaxSessions: -1},
+		backend:  &auditSSHBackend{session: session},
+		username: "foo",
+		lock:     &sync.Mutex{},
+	}
+	_, err := connection.OnSessionChannel(1, []byte{}, &recordingSessionChannel{})
+	assert.NoError(t, err)
+	tracker := newSessionTracker(Config{})
+	tracker.register("conn1", connection)
+
+	assert.Error(t, tracker.TerminateSession("conn1", "Security incident"))
+	assert.Empty(t, session.requests)
+	assert.NoError(t, tracker.TerminateSession("conn1", TerminationReasonIncident))
+	assert.Equal(t, []AuditedRequest{
+		{
+			SchemaVersion: AuditSchemaVersion,
+			Username:      "foo",
+			RequestType:   "terminate",
+			Payload:       map[string]string{"reason": "incident"},
+		},
+	}, session.requests)
+}
+
+type auditSSHBackend struct {
+	dummySSHBackend
+	session *auditBackend
+}
+
+func (a *auditSSHBackend) OnSessionChannel(
+	_ uint64,
+	_ []byte,
+	_ sshserver.SessionChannel,
+) (channel sshserver.SessionChannelHandler, failureReason sshserver.ChannelRejection) {
+	return a.session, nil
+}
+
+type recordingSessionChannel struct {
+	stdin  bytes.Buffer
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	closed bool
+}
+
+func (r *recordingSessionChannel) Stdin() io.Reader {
+	return &r.stdin
+}
+
+func (r *recordingSessionChannel) Stdout() io.Writer {
+	return &r.stdout
+}
+
+func (r *recordingSessionChannel) Stderr() io.Writer {
+	return &r.stderr
+}
+
+func (r *recordingSessionChannel) ExitStatus(_ uint32) {
+}
+
+func (r *recordingSessionChannel) ExitSignal(_ string, _ bool, _ string, _ string) {
+}
+
+func (r *recordingSessionChannel) CloseWrite() error {
+	return nil
+}
+
+func (r *recordingSessionChannel) Close() error {
+	r.closed = true
+	return nil
+}
@@ -0,0 +1,183 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The request model consists of the request types and payload fields passed to DecisionHooks. It is independent of
+// the transport, so servers accepting sessions over other transports than classic SSH, e.g. SSH3 over HTTP/3 or SSH
+// tunneled through WebSockets, can map their requests onto it and evaluate them using Evaluate:
+//
+//   - env: name, value
+//   - pty: term
+//   - exec: command
+//   - shell
+//   - subsystem: subsystem
+//   - signal: signal, without the SIG prefix
+//   - x11-req: authProtocol, singleConnection
+//   - break: length, in milliseconds
+//   - direct-tcpip: host, port
+//   - tcpip-forward: address, port
+//   - direct-streamlocal@openssh.com and streamlocal-forward@openssh.com: socketPath
+//
+// DecodeSSHRequest is the reference adapter for transports carrying requests in the SSH wire format.
+
+// Evaluate evaluates a request in the transport-independent request model. If the request contains a username the
+// request is evaluated as if by the evaluator returned by WithUsername. An error is returned if the request type is
+// not supported or the payload is malformed.
+func (e *Evaluator) Evaluate(request DecisionRequest) (Decision, error) {
+	evaluator := e
+	if request.Username != "" && request.Username != e.username {
+		evaluator = e.WithUsername(request.Username)
+	}
+	payload := request.Payload
+	switch request.RequestType {
+	case "env":
+		return evaluator.EvaluateEnv(payload["name"], payload["value"]), nil
+	case "pty":
+		return evaluator.EvaluatePTY(payload["term"]), nil
+	case "exec":
+		return evaluator.EvaluateExec(payload["command"]), nil
+	case "shell":
+		return evaluator.EvaluateShell(), nil
+	case "subsystem":
+		return evaluator.EvaluateSubsystem(payload["subsystem"]), nil
+	case "signal":
+		return evaluator.EvaluateSignal(payload["signal"]), nil
+	case "x11-req":
+		singleConnection := false
+		if value := payload["singleConnection"]; value != "" {
+			var err error
+			if singleConnection, err = strconv.ParseBool(value); err != nil {
+				return Decision{}, fmt.Errorf("invalid singleConnection in x11-req request: %s", value)
+			}
+		}
+		return evaluator.EvaluateX11(payload["authProtocol"], singleConnection), nil
+	case "break":
+		length, err := strconv.ParseUint(payload["length"], 10, 32)
+		if err != nil {
+			return Decision{}, fmt.Errorf("invalid length in break request: %s", payload["length"])
+		}
+		return evaluator.EvaluateBreak(uint32(length)), nil
+	case "direct-tcpip":
+		port, err := parseRequestPort(request)
+		if err != nil {
+			return Decision{}, err
+		}
+		return evaluator.EvaluateForwarding(payload["host"], port), nil
+	case "tcpip-forward":
+		port, err := parseRequestPort(request)
+		if err != nil {
+			return Decision{}, err
+		}
+		return evaluator.EvaluateReverseForwarding(payload["address"], port), nil
+	case "direct-streamlocal@openssh.com":
+		fallthrough
+	case "streamlocal-forward@openssh.com":
+		return evaluator.EvaluateStreamLocal(request.RequestType, payload["socketPath"]), nil
+	default:
+		return Decision{}, fmt.Errorf("unsupported request type: %s", request.RequestType)
+	}
+}
+
+func parseRequestPort(request DecisionRequest) (uint32, error) {
+	port, err := strconv.ParseUint(request.Payload["port"], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port in %s request: %s", request.RequestType, request.Payload["port"])
+	}
+	return uint32(port), nil
+}
+
+type envRequestPayload struct {
+	Name  string
+	Value string
+}
+
+type ptyRequestPayload struct {
+	Term     string
+	Columns  uint32
+	Rows     uint32
+	Width    uint32
+	Height   uint32
+	ModeList string
+}
+
+type execRequestPayload struct {
+	Command string
+}
+
+type subsystemRequestPayload struct {
+	Subsystem string
+}
+
+type signalRequestPayload struct {
+	Signal string
+}
+
+// DecodeSSHRequest maps a request in the SSH wire format onto the transport-independent request model. The request
+// type is the type of a channel request, global request or channel as defined in RFC 4254, the payload is its
+// type-specific data. The pty-req channel request is mapped onto the pty request type.
+//goland:noinspection GoUnusedExportedFunction
+func DecodeSSHRequest(requestType string, payload []byte) (DecisionRequest, error) {
+	request := DecisionRequest{RequestType: requestType, Payload: map[string]string{}}
+	var err error
+	switch requestType {
+	case "env":
+		decoded := envRequestPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["name"] = decoded.Name
+		request.Payload["value"] = decoded.Value
+	case "pty-req":
+		decoded := ptyRequestPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.RequestType = "pty"
+		request.Payload["term"] = decoded.Term
+	case "exec":
+		decoded := execRequestPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["command"] = decoded.Command
+	case "shell":
+	case "subsystem":
+		decoded := subsystemRequestPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["subsystem"] = decoded.Subsystem
+	case "signal":
+		decoded := signalRequestPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["signal"] = decoded.Signal
+	case "x11-req":
+		decoded := x11RequestPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["authProtocol"] = decoded.AuthProtocol
+		request.Payload["singleConnection"] = strconv.FormatBool(decoded.SingleConnection)
+	case "break":
+		decoded := breakRequestPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["length"] = strconv.FormatUint(uint64(decoded.BreakLength), 10)
+	case "direct-tcpip":
+		decoded := directTCPIPPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["host"] = decoded.Host
+		request.Payload["port"] = strconv.FormatUint(uint64(decoded.Port), 10)
+	case "tcpip-forward":
+		decoded := tcpipForwardPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["address"] = decoded.Address
+		request.Payload["port"] = strconv.FormatUint(uint64(decoded.Port), 10)
+	case "direct-streamlocal@openssh.com":
+		fallthrough
+	case "streamlocal-forward@openssh.com":
+		decoded := streamLocalPayload{}
+		err = ssh.Unmarshal(payload, &decoded)
+		request.Payload["socketPath"] = decoded.SocketPath
+	default:
+		return DecisionRequest{}, fmt.Errorf("unsupported request type: %s", requestType)
+	}
+	if err != nil {
+		return DecisionRequest{}, fmt.Errorf("failed to decode %s request (%w)", requestType, err)
+	}
+	return request, nil
+}
@@ -0,0 +1,64 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEvaluateRequest(t *testing.T) {
+	evaluator, err := NewEvaluator(Config{
+		DefaultMode: ExecutionPolicyDisable,
+		Command: CommandConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"/bin/ls"},
+		},
+		Forwarding: ForwardingConfig{
+			Mode:  ExecutionPolicyFilter,
+			Allow: []string{"127.0.0.1:8080"},
+		},
+		Users: map[string]Config{
+			"admin": {Shell: ShellConfig{Mode: ExecutionPolicyEnable}},
+		},
+	})
+	assert.NoError(t, err)
+
+	request, err := DecodeSSHRequest("exec", ssh.Marshal(execRequestPayload{Command: "/bin/ls"}))
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionRequest{RequestType: "exec", Payload: map[string]string{"command": "/bin/ls"}}, request)
+	decision, err := evaluator.Evaluate(request)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	request, err = DecodeSSHRequest("direct-tcpip", ssh.Marshal(directTCPIPPayload{Host: "127.0.0.1", Port: 22}))
+	assert.NoError(t, err)
+	decision, err = evaluator.Evaluate(request)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	request.Payload["port"] = "8080"
+	decision, err = evaluator.Evaluate(request)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	request, err = DecodeSSHRequest("shell", nil)
+	assert.NoError(t, err)
+	decision, err = evaluator.Evaluate(request)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	request.Username = "admin"
+	decision, err = evaluator.Evaluate(request)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	request, err = DecodeSSHRequest("pty-req", ssh.Marshal(ptyRequestPayload{Term: "xterm"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "pty", request.RequestType)
+
+	_, err = DecodeSSHRequest("env", []byte{0, 0})
+	assert.Error(t, err)
+	_, err = DecodeSSHRequest("keepalive@openssh.com", nil)
+	assert.Error(t, err)
+	_, err = evaluator.Evaluate(DecisionRequest{RequestType: "tcpip-forward", Payload: map[string]string{"port": "x"}})
+	assert.Error(t, err)
+}
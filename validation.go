@@ -0,0 +1,114 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Validation error codes.
+const (
+	// ValidationCodeInvalidMode indicates an unknown execution policy or action.
+	ValidationCodeInvalidMode = "invalid_mode"
+	// ValidationCodeUnsupportedMode indicates an execution policy that is not supported in this section.
+	ValidationCodeUnsupportedMode = "unsupported_mode"
+	// ValidationCodeOutOfRange indicates a numeric setting or duration outside its permitted range.
+	ValidationCodeOutOfRange = "out_of_range"
+	// ValidationCodeInvalidPattern indicates an allow or deny list entry that cannot be parsed.
+	ValidationCodeInvalidPattern = "invalid_pattern"
+	// ValidationCodeInvalidTemplate indicates a template that cannot be parsed.
+	ValidationCodeInvalidTemplate = "invalid_template"
+	// ValidationCodeInvalidFormat indicates a value or map key in the wrong format, e.g. a date or key fingerprint.
+	ValidationCodeInvalidFormat = "invalid_format"
+	// ValidationCodeUnsupported indicates a setting that cannot be enforced with the SSH server in use.
+	ValidationCodeUnsupported = "unsupported"
+)
+
+// ValidationError describes a single problem in the configuration.
+type ValidationError struct {
+	// Path is the JSON/YAML path of the offending field, e.g. command.allow[3].
+	Path string `json:"path"`
+	// Code is a machine-readable identifier of the problem, see the ValidationCode constants.
+	Code string `json:"code"`
+	// Message is a human-readable description of the problem.
+	Message string `json:"message"`
+}
+
+// Error contains the path and description of the problem.
+func (v *ValidationError) Error() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// ValidationErrors contains all problems found in the configuration. It is returned by the Validate functions.
+type ValidationErrors []*ValidationError
+
+// Error contains all problems separated by semicolons.
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, err := range v {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validator collects validation errors.
+type validator struct {
+	errors ValidationErrors
+	// patterns receives the compiled patterns of the configuration if set.
+	patterns *patternSet
+}
+
+// check records err, if any, for path.
+func (v *validator) check(path string, code string, err error) {
+	if err != nil {
+		v.fail(path, code, "%s", err.Error())
+	}
+}
+
+func (v *validator) fail(path string, code string, format string, args ...interface{}) {
+	v.errors = append(v.errors, &ValidationError{
+		Path:    path,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// result returns the collected errors, or nil if there were none.
+func (v *validator) result() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
+// field returns the path of a field within the object at path.
+func field(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// index returns the path of a list item.
+func index(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+// key returns the path of a map item.
+func key(path string, k string) string {
+	return path + "[" + strconv.Quote(k) + "]"
+}
+
+// validateMode validates an execution policy. If allowPrompt is false ExecutionPolicyPrompt is rejected.
+func validateMode(v *validator, path string, mode ExecutionPolicy, allowPrompt bool) {
+	if err := mode.Validate(); err != nil {
+		v.check(path, ValidationCodeInvalidMode, err)
+		return
+	}
+	if !allowPrompt {
+		v.check(path, ValidationCodeUnsupportedMode, validateNoPrompt(mode))
+	}
+}
@@ -0,0 +1,72 @@
+package security
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationErrors(t *testing.T) {
+	err := Config{
+		DefaultMode: "foo",
+		Subsystem: SubsystemConfig{
+			Allow: []string{"sftp", "sf*"},
+		},
+		MaxSessions: -2,
+		Quarantine: QuarantineConfig{
+			Policy: &Config{
+				Env: EnvConfig{Mode: ExecutionPolicyPrompt},
+			},
+		},
+	}.Validate()
+	assert.Error(t, err)
+
+	var validationErrors ValidationErrors
+	assert.True(t, errors.As(err, &validationErrors))
+	paths := map[string]string{}
+	for _, validationError := range validationErrors {
+		paths[validationError.Path] = validationError.Code
+	}
+	assert.Equal(t, map[string]string{
+		"defaultMode":                ValidationCodeInvalidMode,
+		"subsystem.allow[1]":         ValidationCodeInvalidPattern,
+		"maxSessions":                ValidationCodeOutOfRange,
+		"quarantine.policy.env.mode": ValidationCodeUnsupportedMode,
+	}, paths)
+
+	err = Config{Channels: map[string]ChannelConfig{"x11": {Max: -2}}}.Validate()
+	assert.EqualError(t, err, `channels["x11"].max: invalid max setting: -2`)
+
+	err = Config{Env: EnvConfig{MatchMode: MatchModeRegex, Deny: []string{"100%d("}}}.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid pattern 100%d(")
+	assert.NotContains(t, err.Error(), "MISSING")
+
+	assert.NoError(t, Config{}.Validate())
+}
+
+func TestServerSupport(t *testing.T) {
+	for path, config := range map[string]Config{
+		"x11.mode":                   {X11: X11Config{Mode: ExecutionPolicyEnable}},
+		"break.mode":                 {Break: BreakConfig{Mode: ExecutionPolicyFilter}},
+		"forwarding.mode":            {Forwarding: ForwardingConfig{Mode: ExecutionPolicyFilter}},
+		"reverseForwarding.mode":     {ReverseForwarding: ReverseForwardingConfig{Mode: ExecutionPolicyEnable}},
+		"streamLocalForwarding.mode": {StreamLocalForwarding: StreamLocalConfig{Mode: ExecutionPolicyAudit}},
+		`users["foo"].forwarding.mode`: {
+			Users: map[string]Config{"foo": {Forwarding: ForwardingConfig{Mode: ExecutionPolicyEnable}}},
+		},
+	} {
+		_, err := New(config, &dummyNetworkBackend{})
+		var validationErrors ValidationErrors
+		if assert.True(t, errors.As(err, &validationErrors), path) {
+			assert.Equal(t, path, validationErrors[0].Path)
+			assert.Equal(t, ValidationCodeUnsupported, validationErrors[0].Code)
+		}
+		_, err = NewEvaluator(config)
+		assert.NoError(t, err, path)
+	}
+
+	_, err := New(Config{Forwarding: ForwardingConfig{Mode: ExecutionPolicyDisable}}, &dummyNetworkBackend{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,71 @@
+package security
+
+import (
+	"fmt"
+)
+
+// SpawnVerifier is implemented by the session channel passed to session backends. After starting a program the
+// backend can report the program and environment it actually used. If they diverge from the request approved by the
+// policy the divergence is reported to the backend's AuditHandler, if any, the channel is closed and an error is
+// returned.
+type SpawnVerifier interface {
+	// VerifySpawn verifies the started program. The program is the command passed to OnExecRequest, the subsystem
+	// name passed to OnSubsystem, or an empty string for OnShell. The env contains the full environment of the program.
+	VerifySpawn(program string, env map[string]string) error
+}
+
+// setEnv passes an environment variable to the backend and records it for spawn verification.
+func (s *sessionHandler) setEnv(requestID uint64, name string, value string) error {
+	if err := s.backend.OnEnvRequest(requestID, name, value); err != nil {
+		return err
+	}
+	if s.approvedEnv == nil {
+		s.approvedEnv = map[string]string{}
+	}
+	s.approvedEnv[name] = value
+	return nil
+}
+
+// approveProgram records the program passed to the backend for spawn verification.
+func (s *sessionHandler) approveProgram(program string) {
+	s.approvedProgram = program
+	s.programApproved = true
+}
+
+func (s *sessionHandler) verifySpawn(program string, env map[string]string) error {
+	if err := s.checkSpawn(program, env); err != nil {
+		if auditHandler, ok := s.backend.(AuditHandler); ok {
+			_ = auditHandler.OnAuditedRequest(AuditedRequest{
+				SchemaVersion: AuditSchemaVersion,
+				Username:      s.sshConnection.username,
+				RequestType:   "spawn-verification-failed",
+				Payload:       map[string]string{"program": program, "reason": err.Error()},
+			})
+		}
+		if s.channel != nil {
+			_ = s.channel.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *sessionHandler) checkSpawn(program string, env map[string]string) error {
+	if !s.programApproved {
+		return fmt.Errorf("no program was approved")
+	}
+	if program != s.approvedProgram {
+		return fmt.Errorf("program does not match the approved program")
+	}
+	for name, value := range s.approvedEnv {
+		if actual, ok := env[name]; !ok || actual != value {
+			return fmt.Errorf("environment variable %s does not match the approved value", name)
+		}
+	}
+	for name := range env {
+		if s.config.patterns.matchList(s.config.Env.MatchMode, s.config.Env.Deny, name) {
+			return fmt.Errorf("denied environment variable %s is set", name)
+		}
+	}
+	return nil
+}
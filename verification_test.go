@@ -0,0 +1,72 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/containerssh/sshserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySpawn(t *testing.T) {
+	backend := &auditBackend{}
+	sshBackend := &verificationSSHBackend{backend: backend}
+	connection := &sshConnectionHandler{
+		config: Config{
+			MaxSessions:  -1,
+			ForceCommand: "/usr/bin/backup",
+			Env: EnvConfig{
+				Deny: []string{"LD_PRELOAD"},
+			},
+		},
+		username: "foo",
+		backend:  sshBackend,
+		lock:     &sync.Mutex{},
+	}
+	channel := &recordingSessionChannel{}
+	session, err := connection.OnSessionChannel(1, []byte{}, channel)
+	assert.Nil(t, err)
+	verifier := sshBackend.channel.(SpawnVerifier)
+
+	assert.NoError(t, session.OnEnvRequest(1, "LANG", "C"))
+	assert.NoError(t, session.OnExecRequest(2, "rm -rf /"))
+	assert.NoError(t, verifier.VerifySpawn("/usr/bin/backup", map[string]string{
+		"LANG":                 "C",
+		"SSH_ORIGINAL_COMMAND": "rm -rf /",
+		"HOME":                 "/home/foo",
+	}))
+	assert.Empty(t, backend.requests)
+	assert.False(t, channel.closed)
+
+	assert.Error(t, verifier.VerifySpawn("rm -rf /", map[string]string{
+		"LANG":                 "C",
+		"SSH_ORIGINAL_COMMAND": "rm -rf /",
+	}))
+	assert.Error(t, verifier.VerifySpawn("/usr/bin/backup", map[string]string{
+		"LANG":                 "C",
+		"SSH_ORIGINAL_COMMAND": "rm -rf /",
+		"LD_PRELOAD":           "/tmp/evil.so",
+	}))
+	assert.Len(t, backend.requests, 2)
+	assert.Equal(t, "spawn-verification-failed", backend.requests[0].RequestType)
+	assert.True(t, channel.closed)
+
+	_, err = connection.OnSessionChannel(2, []byte{}, &recordingSessionChannel{})
+	assert.Nil(t, err)
+	assert.Error(t, sshBackend.channel.(SpawnVerifier).VerifySpawn("/usr/bin/backup", map[string]string{}))
+}
+
+type verificationSSHBackend struct {
+	dummySSHBackend
+	backend *auditBackend
+	channel sshserver.SessionChannel
+}
+
+func (v *verificationSSHBackend) OnSessionChannel(
+	_ uint64,
+	_ []byte,
+	session sshserver.SessionChannel,
+) (channel sshserver.SessionChannelHandler, failureReason sshserver.ChannelRejection) {
+	v.channel = session
+	return v.backend, nil
+}
@@ -0,0 +1,117 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// X11Trust configures whether forwarded X11 clients are trusted.
+type X11Trust string
+
+const (
+	// X11TrustTrusted gives forwarded X11 clients full access to the display of the user.
+	X11TrustTrusted X11Trust = "trusted"
+
+	// X11TrustUntrusted restricts forwarded X11 clients using the X11 SECURITY extension, preventing them from, for
+	// example, capturing the keystrokes or the screen contents of other clients.
+	X11TrustUntrusted X11Trust = "untrusted"
+)
+
+// Validate validates the X11 trust setting.
+func (x X11Trust) Validate() error {
+	switch x {
+	case "":
+	case X11TrustTrusted:
+	case X11TrustUntrusted:
+	default:
+		return fmt.Errorf("invalid X11 trust setting: %s", x)
+	}
+	return nil
+}
+
+// X11PolicyHandler is an optional interface for session channel backends. Backends implementing it receive the X11
+// trust setting before the X11 forwarding request is passed to them and are responsible for applying it. The SSH
+// server rejects x11-req requests towards the client, so the setting only takes effect with servers that support X11
+// forwarding.
+type X11PolicyHandler interface {
+	// OnX11Policy receives the trust setting for the X11 forwarding request. If an error is returned the request is
+	// rejected.
+	OnX11Policy(requestID uint64, trust X11Trust) error
+}
+
+// x11RequestPayload is the payload of an x11-req request as described in RFC 4254 section 6.3.1.
+type x11RequestPayload struct {
+	SingleConnection bool
+	AuthProtocol     string
+	AuthCookie       string
+	ScreenNumber     uint32
+}
+
+// EvaluateX11 evaluates an X11 forwarding request.
+func (e *Evaluator) EvaluateX11(authProtocol string, singleConnection bool) Decision {
+	payload := map[string]string{
+		"authProtocol":     authProtocol,
+		"singleConnection": strconv.FormatBool(singleConnection),
+	}
+	return e.decide("x11-req", payload, e.config.X11.Mode, func(mode ExecutionPolicy) Decision {
+		return e.evaluateX11(mode, authProtocol, singleConnection)
+	})
+}
+
+func (e *Evaluator) evaluateX11(mode ExecutionPolicy, authProtocol string, singleConnection bool) Decision {
+	if mode != ExecutionPolicyDisable && e.config.X11.SingleConnection && !singleConnection {
+		return deny(mode, DecisionReasonSingleConnectionRequired, "only a single X11 connection may be forwarded")
+	}
+	switch mode {
+	case ExecutionPolicyDisable:
+		return deny(mode, DecisionReasonModeDisabled, "X11 forwarding is disabled")
+	case ExecutionPolicyFilter:
+		for _, allowed := range e.config.X11.Allow {
+			if allowed == authProtocol {
+				return allow(mode, DecisionReasonInAllowList, "the authentication protocol is on the allow list")
+			}
+		}
+		return deny(mode, DecisionReasonNotInAllowList, "the authentication protocol is not on the allow list")
+	case ExecutionPolicyEnable:
+		fallthrough
+	case ExecutionPolicyAudit:
+		fallthrough
+	default:
+		return allow(mode, DecisionReasonModeEnabled, "X11 forwarding is enabled")
+	}
+}
+
+// allowX11 decides whether an X11 forwarding request is passed to the backend. Requests with a malformed payload are
+// rejected. The request is audited if either requestMode, the policy from Requests, or X11.Mode is
+// ExecutionPolicyAudit. The authentication cookie is never included in audit records.
+func (s *sessionHandler) allowX11(requestID uint64, requestMode ExecutionPolicy, payload []byte) bool {
+	request := x11RequestPayload{}
+	if err := ssh.Unmarshal(payload, &request); err != nil {
+		return false
+	}
+	decision := s.evaluator().EvaluateX11(request.AuthProtocol, request.SingleConnection)
+	if !decision.Allowed {
+		return false
+	}
+	auditMode := decision.Mode
+	if requestMode == ExecutionPolicyAudit {
+		auditMode = ExecutionPolicyAudit
+	}
+	if err := s.audit(auditMode, requestID, "x11-req", map[string]string{
+		"authProtocol":     request.AuthProtocol,
+		"singleConnection": strconv.FormatBool(request.SingleConnection),
+		"screenNumber":     strconv.FormatUint(uint64(request.ScreenNumber), 10),
+	}); err != nil {
+		return false
+	}
+	if s.config.X11.Trust == "" {
+		return true
+	}
+	x11Handler, ok := s.backend.(X11PolicyHandler)
+	if !ok {
+		return false
+	}
+	return x11Handler.OnX11Policy(requestID, s.config.X11.Trust) == nil
+}
@@ -0,0 +1,75 @@
+package security
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestX11(t *testing.T) {
+	backend := &x11Backend{}
+	session := &sessionHandler{
+		config: Config{
+			X11: X11Config{
+				Mode:             ExecutionPolicyFilter,
+				Allow:            []string{"MIT-MAGIC-COOKIE-1"},
+				SingleConnection: true,
+				Trust:            X11TrustUntrusted,
+			},
+		},
+		backend: backend,
+		sshConnection: &sshConnectionHandler{
+			lock: &sync.Mutex{},
+		},
+	}
+	assert.NoError(t, session.config.Validate())
+
+	request := func(requestID uint64, authProtocol string, singleConnection bool) {
+		session.OnUnsupportedChannelRequest(requestID, "x11-req", ssh.Marshal(x11RequestPayload{
+			SingleConnection: singleConnection,
+			AuthProtocol:     authProtocol,
+			AuthCookie:       "0123456789abcdef",
+		}))
+	}
+	request(1, "MIT-MAGIC-COOKIE-1", true)
+	request(2, "MIT-MAGIC-COOKIE-1", false)
+	request(3, "XDM-AUTHORIZATION-1", true)
+	session.OnUnsupportedChannelRequest(4, "x11-req", []byte{})
+	assert.Equal(t, []string{"x11-req"}, backend.requests)
+	assert.Equal(t, []X11Trust{X11TrustUntrusted}, backend.trust)
+
+	session.backend = &requestBackend{}
+	request(5, "MIT-MAGIC-COOKIE-1", true)
+	assert.Empty(t, session.backend.(*requestBackend).requests)
+
+	auditor := &auditBackend{}
+	session.backend = auditor
+	session.config.X11 = X11Config{Mode: ExecutionPolicyAudit}
+	request(6, "MIT-MAGIC-COOKIE-1", true)
+	assert.Equal(t, []AuditedRequest{
+		{
+			SchemaVersion: AuditSchemaVersion,
+			RequestID:     6,
+			RequestType:   "x11-req",
+			Payload: map[string]string{
+				"authProtocol":     "MIT-MAGIC-COOKIE-1",
+				"singleConnection": "true",
+				"screenNumber":     "0",
+			},
+		},
+	}, auditor.requests)
+
+	assert.Error(t, X11Config{Trust: "maybe"}.Validate())
+}
+
+type x11Backend struct {
+	requestBackend
+	trust []X11Trust
+}
+
+func (x *x11Backend) OnX11Policy(_ uint64, trust X11Trust) error {
+	x.trust = append(x.trust, trust)
+	return nil
+}